@@ -0,0 +1,192 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"kafka-logger/config"
+	"kafka-logger/consumer"
+	"kafka-logger/control"
+	"kafka-logger/producer"
+	"kafka-logger/service"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+)
+
+var errUnknownCommand = errors.New("unknown command")
+
+// runCommand runs a CLI subcommand such as "set-level". It returns
+// errUnknownCommand if args do not name one.
+func runCommand(cfg *config.Config, args []string, output io.Writer) error {
+	switch args[0] {
+	case "set-level":
+		return setLevelCommand(cfg, args[1:], output, nil)
+	case "dlq":
+		return deadLetterCommand(cfg, args[1:], output, nil, nil)
+	default:
+		return fmt.Errorf("%w %q", errUnknownCommand, args[0])
+	}
+}
+
+// setLevelCommand publishes a level override to the control topic. writer is
+// only set by tests; otherwise a producer for the control topic is created.
+func setLevelCommand(cfg *config.Config, args []string, output io.Writer, writer producer.MessageWriter) error {
+	flags := flag.NewFlagSet("set-level", flag.ContinueOnError)
+	flags.SetOutput(output)
+	serviceName := flags.String("service", cfg.Logging.ServiceName, "service whose level to change")
+	instance := flags.String("instance", "", "only change this instance (default: all instances)")
+	level := flags.String("level", "", "new minimum level: DEBUG, INFO, WARN or ERROR")
+	clearOverride := flags.Bool("clear", false, "remove the override instead of setting one")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	if cfg.Kafka.ControlTopic == "" {
+		return fmt.Errorf("no control topic configured")
+	}
+
+	if writer == nil {
+		initControlTopic(cfg.Kafka.Brokers, cfg.Kafka.ControlTopic)
+		w := producer.NewProducer(cfg.Kafka.Brokers, cfg.Kafka.ControlTopic)
+		defer w.Close()
+		writer = w
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	key := control.OverrideKey(*serviceName, *instance)
+	if *clearOverride {
+		if err := control.ClearOverride(ctx, writer, *serviceName, *instance); err != nil {
+			return fmt.Errorf("failed to clear override: %w", err)
+		}
+		fmt.Fprintf(output, "Cleared level override for %s\n", key)
+		return nil
+	}
+
+	parsed, err := service.ParseLogLevel(*level)
+	if err != nil {
+		return err
+	}
+
+	err = control.PublishOverride(ctx, writer, control.LevelOverride{
+		Service:  *serviceName,
+		Instance: *instance,
+		Level:    parsed,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to publish override: %w", err)
+	}
+
+	fmt.Fprintf(output, "Set level for %s to %s\n", key, parsed)
+	return nil
+}
+
+// maxShownValue bounds how much of a message value deadLetterCommand prints.
+const maxShownValue = 200
+
+// deadLetterCommand lists the messages in the dead-letter topic and, with
+// -redrive, publishes them back to their source topic. It reads as its own
+// consumer group, committing only re-driven messages, so a listing shows
+// what is still waiting to be re-driven. reader and writer are only set by
+// tests.
+func deadLetterCommand(cfg *config.Config, args []string, output io.Writer, reader consumer.MessageReader, writer producer.MessageWriter) error {
+	flags := flag.NewFlagSet("dlq", flag.ContinueOnError)
+	flags.SetOutput(output)
+	group := flags.String("group", cfg.Consumer.GroupName+"-dlq", "consumer group that tracks re-driven messages")
+	limit := flags.Int("limit", 100, "maximum number of messages to show or re-drive")
+	reason := flags.String("reason", "", "only show messages that failed for this reason: decode or write")
+	redrive := flags.Bool("redrive", false, "publish the messages back to their source topic")
+	wait := flags.Duration("wait", 10*time.Second, "stop after no message arrives for this long")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	if cfg.Kafka.DeadLetterTopic == "" {
+		return fmt.Errorf("no dead-letter topic configured")
+	}
+	if *redrive && *reason != "" {
+		// Committing a re-driven message also commits the skipped ones
+		// before it, which would then never be re-driven.
+		return fmt.Errorf("-reason cannot be combined with -redrive")
+	}
+
+	if reader == nil {
+		r := consumer.NewConsumer(cfg.Kafka.Brokers, cfg.Kafka.DeadLetterTopic, *group)
+		defer r.Close()
+		reader = r
+	}
+	if writer == nil && *redrive {
+		w := producer.NewProducer(cfg.Kafka.Brokers, "")
+		defer w.Close()
+		writer = w
+	}
+
+	shown, skipped := 0, 0
+	for shown < *limit {
+		ctx, cancel := context.WithTimeout(context.Background(), *wait)
+		message, err := reader.FetchMessage(ctx)
+		cancel()
+		if errors.Is(err, io.EOF) || errors.Is(err, context.DeadlineExceeded) {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read dead-letter topic: %w", err)
+		}
+
+		dl, err := consumer.ParseDeadLetter(message)
+		if err != nil {
+			fmt.Fprintf(output, "Skipping offset %d of partition %d: %v\n", message.Offset, message.Partition, err)
+			skipped++
+			continue
+		}
+		if *reason != "" && dl.Reason != *reason {
+			continue
+		}
+		printDeadLetter(output, dl)
+		shown++
+
+		if *redrive {
+			if err := redriveDeadLetter(reader, writer, message, dl); err != nil {
+				return err
+			}
+		}
+	}
+
+	verb := "Found"
+	if *redrive {
+		verb = "Re-drove"
+	}
+	fmt.Fprintf(output, "%s %d dead-letter messages", verb, shown)
+	if skipped > 0 {
+		fmt.Fprintf(output, ", skipped %d unreadable", skipped)
+	}
+	fmt.Fprintln(output)
+	return nil
+}
+
+func printDeadLetter(output io.Writer, dl consumer.DeadLetter) {
+	value := string(dl.Message.Value)
+	if len(value) > maxShownValue {
+		value = value[:maxShownValue] + "..."
+	}
+	fmt.Fprintf(output, "%s/%d@%d reason=%s failed_at=%s key=%q error=%q\n    %s\n",
+		dl.Topic, dl.Partition, dl.Offset, dl.Reason, dl.FailedAt.Format(time.RFC3339), dl.Message.Key, dl.Error, value)
+}
+
+// redriveDeadLetter publishes the original message to its source topic and
+// then commits it in the dead-letter topic.
+func redriveDeadLetter(reader consumer.MessageReader, writer producer.MessageWriter, message kafka.Message, dl consumer.DeadLetter) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := writer.WriteMessages(ctx, dl.Message); err != nil {
+		return fmt.Errorf("failed to re-drive %s/%d@%d: %w", dl.Topic, dl.Partition, dl.Offset, err)
+	}
+	if err := reader.CommitMessages(ctx, message); err != nil {
+		return fmt.Errorf("failed to commit re-driven message: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,159 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"kafka-logger/config"
+	"kafka-logger/consumer"
+	"kafka-logger/control"
+	"kafka-logger/mocks"
+	"kafka-logger/service"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+)
+
+func TestRunCommand(t *testing.T) {
+	var out bytes.Buffer
+	err := runCommand(config.DefaultConfig(), []string{"bogus"}, &out)
+	if !errors.Is(err, errUnknownCommand) {
+		t.Errorf("Expected errUnknownCommand, got: %v", err)
+	}
+}
+
+func TestSetLevelCommand(t *testing.T) {
+	t.Run("Publishes override", func(t *testing.T) {
+		writer := &mocks.MockMessageWriter{}
+		var out bytes.Buffer
+
+		args := []string{"-service", "billing", "-instance", "pod-1", "-level", "warn"}
+		if err := setLevelCommand(config.DefaultConfig(), args, &out, writer); err != nil {
+			t.Fatalf("Expected no error, got: %v", err)
+		}
+
+		if len(writer.Messages) != 1 {
+			t.Fatalf("Expected 1 message, got %d", len(writer.Messages))
+		}
+
+		var override control.LevelOverride
+		if err := json.Unmarshal(writer.Messages[0].Value, &override); err != nil {
+			t.Fatalf("Failed to unmarshal override: %v", err)
+		}
+		if override.Service != "billing" || override.Instance != "pod-1" || override.Level != service.WARN {
+			t.Errorf("Unexpected override %+v", override)
+		}
+		if !strings.Contains(out.String(), "billing/pod-1") {
+			t.Errorf("Expected confirmation in output, got: %s", out.String())
+		}
+	})
+
+	t.Run("Clear", func(t *testing.T) {
+		writer := &mocks.MockMessageWriter{}
+		var out bytes.Buffer
+
+		if err := setLevelCommand(config.DefaultConfig(), []string{"-clear"}, &out, writer); err != nil {
+			t.Fatalf("Expected no error, got: %v", err)
+		}
+
+		if len(writer.Messages) != 1 || writer.Messages[0].Value != nil {
+			t.Errorf("Expected a tombstone, got %v", writer.Messages)
+		}
+		if string(writer.Messages[0].Key) != "demo-service" {
+			t.Errorf("Expected default service key, got '%s'", string(writer.Messages[0].Key))
+		}
+	})
+
+	t.Run("Invalid level", func(t *testing.T) {
+		writer := &mocks.MockMessageWriter{}
+		var out bytes.Buffer
+
+		if err := setLevelCommand(config.DefaultConfig(), []string{"-level", "loud"}, &out, writer); err == nil {
+			t.Error("Expected error for invalid level")
+		}
+		if len(writer.Messages) != 0 {
+			t.Errorf("Expected nothing to be published, got %d messages", len(writer.Messages))
+		}
+	})
+}
+
+func deadLetters() []kafka.Message {
+	failedAt := time.Date(2024, 1, 15, 10, 30, 45, 0, time.UTC)
+	return []kafka.Message{
+		consumer.DeadLetterMessage(kafka.Message{Topic: "logs-topic", Partition: 1, Offset: 7, Key: []byte("billing"), Value: []byte("{broken")},
+			consumer.ReasonDecode, errors.New("unexpected EOF"), failedAt),
+		{Value: []byte("not a dead letter")},
+		consumer.DeadLetterMessage(kafka.Message{Topic: "logs-topic", Partition: 0, Offset: 3, Value: []byte(`{"message":"ok"}`)},
+			consumer.ReasonWrite, errors.New("disk full"), failedAt),
+	}
+}
+
+func TestDeadLetterCommand(t *testing.T) {
+	t.Run("Lists without committing", func(t *testing.T) {
+		reader := &mocks.MockMessageReader{Messages: deadLetters()}
+		var out bytes.Buffer
+
+		if err := deadLetterCommand(config.DefaultConfig(), nil, &out, reader, nil); err != nil {
+			t.Fatalf("Expected no error, got: %v", err)
+		}
+		output := out.String()
+		for _, expected := range []string{
+			`logs-topic/1@7 reason=decode failed_at=2024-01-15T10:30:45Z key="billing" error="unexpected EOF"`,
+			"logs-topic/0@3 reason=write",
+			"Skipping offset 0 of partition 0",
+			"Found 2 dead-letter messages, skipped 1 unreadable",
+		} {
+			if !strings.Contains(output, expected) {
+				t.Errorf("Expected %q in output, got:\n%s", expected, output)
+			}
+		}
+		if len(reader.Committed) != 0 {
+			t.Errorf("Expected nothing committed, got %d", len(reader.Committed))
+		}
+	})
+
+	t.Run("Filters by reason", func(t *testing.T) {
+		reader := &mocks.MockMessageReader{Messages: deadLetters()}
+		var out bytes.Buffer
+
+		if err := deadLetterCommand(config.DefaultConfig(), []string{"-reason", "write"}, &out, reader, nil); err != nil {
+			t.Fatalf("Expected no error, got: %v", err)
+		}
+		if strings.Contains(out.String(), "reason=decode") || !strings.Contains(out.String(), "Found 1 dead-letter messages") {
+			t.Errorf("Expected only the write failure, got:\n%s", out.String())
+		}
+	})
+
+	t.Run("Re-drives to the source topic", func(t *testing.T) {
+		reader := &mocks.MockMessageReader{Messages: deadLetters()}
+		writer := &mocks.MockMessageWriter{}
+		var out bytes.Buffer
+
+		if err := deadLetterCommand(config.DefaultConfig(), []string{"-redrive", "-limit", "1"}, &out, reader, writer); err != nil {
+			t.Fatalf("Expected no error, got: %v", err)
+		}
+		if len(writer.Messages) != 1 {
+			t.Fatalf("Expected 1 re-driven message, got %d", len(writer.Messages))
+		}
+		redriven := writer.Messages[0]
+		if redriven.Topic != "logs-topic" || string(redriven.Value) != "{broken" || len(redriven.Headers) != 0 {
+			t.Errorf("Expected the original message, got %+v", redriven)
+		}
+		if len(reader.Committed) != 1 || reader.Committed[0].Offset != reader.Messages[0].Offset {
+			t.Errorf("Expected the re-driven message to be committed, got %v", reader.Committed)
+		}
+		if !strings.Contains(out.String(), "Re-drove 1 dead-letter messages") {
+			t.Errorf("Expected a summary, got:\n%s", out.String())
+		}
+	})
+
+	t.Run("Reason cannot be combined with redrive", func(t *testing.T) {
+		var out bytes.Buffer
+		err := deadLetterCommand(config.DefaultConfig(), []string{"-redrive", "-reason", "write"}, &out, &mocks.MockMessageReader{}, &mocks.MockMessageWriter{})
+		if err == nil {
+			t.Error("Expected an error")
+		}
+	})
+}
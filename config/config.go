@@ -3,6 +3,7 @@ package config
 import (
 	"fmt"
 	"os"
+	"time"
 
 	"gopkg.in/yaml.v3"
 )
@@ -14,19 +15,116 @@ type Config struct {
 }
 
 type KafkaConfig struct {
-	Brokers    []string `yaml:"brokers"`
-	Topic      string   `yaml:"topic"`
-	Partitions int      `yaml:"partitions"`
+	Brokers      []string `yaml:"brokers"`
+	Topic        string   `yaml:"topic"`
+	Partitions   int      `yaml:"partitions"`
+	ControlTopic string   `yaml:"control_topic"`
+	// SchemaRegistryURL enables the schema registry wire format when set.
+	SchemaRegistryURL string         `yaml:"schema_registry_url"`
+	Balancer          string         `yaml:"balancer"`
+	Producer          ProducerConfig `yaml:"producer"`
+	// DeadLetterTopic receives messages the consumer cannot decode or
+	// write. Empty disables it.
+	DeadLetterTopic string `yaml:"dead_letter_topic"`
+}
+
+// ProducerConfig sets delivery guarantees for the logger's writer. Delivery
+// is "fire-and-forget", "leader-ack" or "all-isr".
+type ProducerConfig struct {
+	Delivery     string        `yaml:"delivery"`
+	BatchSize    int           `yaml:"batch_size"`
+	BatchTimeout time.Duration `yaml:"batch_timeout"`
+	Compression  string        `yaml:"compression"`
+	MaxAttempts  int           `yaml:"max_attempts"`
+	Async        bool          `yaml:"async"`
 }
 
 type LogConfig struct {
 	ServiceName string `yaml:"service_name"`
+	InstanceID  string `yaml:"instance_id"`
 	FilePath    string `yaml:"file_path"`
+	Level       string `yaml:"level"`
+	Encoding    string `yaml:"encoding"`
+	// PartitionKey is "service", "none", "field:<name>" or "hash:<a>,<b>".
+	PartitionKey string          `yaml:"partition_key"`
+	Redaction    RedactionConfig `yaml:"redaction"`
+	// FlightRecorderSize enables flight recorder mode with this many DEBUG
+	// events kept per scope. Zero disables it.
+	FlightRecorderSize int              `yaml:"flight_recorder_size"`
+	Enrichment         EnrichmentConfig `yaml:"enrichment"`
+	Sampling           SamplingConfig   `yaml:"sampling"`
+}
+
+// SamplingConfig limits noisy events. Per level and message, the first
+// First events in each Interval are published, then every Thereafter-th.
+// RateLimit caps the events per second, with bursts up to Burst. Counts of
+// suppressed events are logged every SummaryInterval. Zero First and
+// RateLimit turn sampling off.
+type SamplingConfig struct {
+	First           int           `yaml:"first"`
+	Thereafter      int           `yaml:"thereafter"`
+	Interval        time.Duration `yaml:"interval"`
+	RateLimit       float64       `yaml:"rate_limit"`
+	Burst           int           `yaml:"burst"`
+	SummaryInterval time.Duration `yaml:"summary_interval"`
+}
+
+// EnrichmentConfig chooses the metadata added to every event. Events that
+// already have a field keep their own value.
+type EnrichmentConfig struct {
+	// Static fields, such as the environment name.
+	Static map[string]string `yaml:"static"`
+	// Host adds "host"; Process adds "pid" and "process"; Build adds
+	// "version", "git_sha" and "go_version" from the binary's build info.
+	Host    bool `yaml:"host"`
+	Process bool `yaml:"process"`
+	Build   bool `yaml:"build"`
+	// Container adds the Kubernetes pod, namespace and node from the
+	// conventional downward API variables. Env and Files map further fields
+	// to environment variables and downward API files.
+	Container bool              `yaml:"container"`
+	Env       map[string]string `yaml:"env"`
+	Files     map[string]string `yaml:"files"`
+}
+
+// RedactionConfig lists the rules applied to event fields before they are
+// published. The HMAC key for the "hash" action is read from the environment
+// variable named by HMACKeyEnv, so it does not have to live in this file;
+// HMACKey is used when that variable is unset or empty.
+type RedactionConfig struct {
+	HMACKey    string          `yaml:"hmac_key"`
+	HMACKeyEnv string          `yaml:"hmac_key_env"`
+	Rules      []RedactionRule `yaml:"rules"`
+}
+
+// RedactionRule matches fields by name (Field, an exact name or glob, or
+// FieldRegex) or by value (Value, a built-in pattern such as "credit_card" or
+// "jwt", or ValueRegex). Action is "drop", "mask" (the default) or "hash".
+type RedactionRule struct {
+	Field      string `yaml:"field"`
+	FieldRegex string `yaml:"field_regex"`
+	Value      string `yaml:"value"`
+	ValueRegex string `yaml:"value_regex"`
+	Action     string `yaml:"action"`
 }
 
 type ConsumerConfig struct {
 	GroupName    string `yaml:"group_name"`
 	NumConsumers int    `yaml:"num_consumers"`
+	// DedupWindow is the number of recent event IDs remembered to drop
+	// redelivered events. ReorderWindow is how many sequence numbers a
+	// producer may move past missing events before they are reported; events
+	// can arrive out of order, so keep it well above zero.
+	DedupWindow   int    `yaml:"dedup_window"`
+	ReorderWindow uint64 `yaml:"reorder_window"`
+	// Offsets are committed after events are written to files, once
+	// CommitBatchSize events are written or CommitInterval has passed.
+	CommitBatchSize int           `yaml:"commit_batch_size"`
+	CommitInterval  time.Duration `yaml:"commit_interval"`
+	// Transient errors are retried, and failed consumers restarted, after a
+	// jittered delay doubling from RetryInitial up to RetryMax.
+	RetryInitial time.Duration `yaml:"retry_initial"`
+	RetryMax     time.Duration `yaml:"retry_max"`
 }
 
 func LoadConfig(configPath string) (*Config, error) {
@@ -46,17 +144,36 @@ func LoadConfig(configPath string) (*Config, error) {
 func DefaultConfig() *Config {
 	return &Config{
 		Kafka: KafkaConfig{
-			Brokers:    []string{"localhost:9092"},
-			Topic:      "logs-topic",
-			Partitions: 3,
+			Brokers:         []string{"localhost:9092"},
+			Topic:           "logs-topic",
+			Partitions:      3,
+			ControlTopic:    "logs-control",
+			DeadLetterTopic: "logs-dlq",
+			Balancer:        "least-bytes",
+			Producer: ProducerConfig{
+				Delivery:     "leader-ack",
+				BatchSize:    100,
+				BatchTimeout: 10 * time.Millisecond,
+				Compression:  "snappy",
+				MaxAttempts:  10,
+			},
 		},
 		Logging: LogConfig{
-			ServiceName: "demo-service",
-			FilePath:    "./logs",
+			ServiceName:  "demo-service",
+			FilePath:     "./logs",
+			Level:        "DEBUG",
+			Encoding:     "json",
+			PartitionKey: "service",
 		},
 		Consumer: ConsumerConfig{
-			GroupName:    "logger-group",
-			NumConsumers: 3,
+			GroupName:       "logger-group",
+			NumConsumers:    3,
+			DedupWindow:     10000,
+			ReorderWindow:   1000,
+			CommitBatchSize: 100,
+			CommitInterval:  time.Second,
+			RetryInitial:    100 * time.Millisecond,
+			RetryMax:        30 * time.Second,
 		},
 	}
 }
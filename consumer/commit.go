@@ -0,0 +1,124 @@
+package consumer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+)
+
+const (
+	defaultCommitBatchSize = 100
+	defaultCommitInterval  = time.Second
+	// commitTimeout bounds the final commit made when consumption stops,
+	// which runs after the consumer's context may have been canceled.
+	commitTimeout = 5 * time.Second
+)
+
+// WithCommitBatching sets how offsets are committed by
+// ConsumeLogEventsToFiles: once size messages have been handled, or interval
+// after the oldest uncommitted one, whichever comes first. Larger batches
+// mean fewer commits but more events redelivered after a crash. Defaults to
+// 100 messages and one second.
+func WithCommitBatching(size int, interval time.Duration) ConsumeOption {
+	return func(o *consumeOptions) {
+		o.commitBatchSize = size
+		o.commitInterval = interval
+	}
+}
+
+// committer commits the offsets of messages once they have been handled.
+// Messages are handled in order, so committing a batch never skips one that
+// is still being written.
+type committer struct {
+	reader    MessageReader
+	batchSize int
+	interval  time.Duration
+	retry     func(ctx context.Context, op func() error) error
+
+	pending []kafka.Message
+	// due is when the pending messages must be committed.
+	due time.Time
+}
+
+func (o consumeOptions) newCommitter(reader MessageReader) *committer {
+	c := &committer{reader: reader, batchSize: o.commitBatchSize, interval: o.commitInterval, retry: o.retry}
+	if c.batchSize <= 0 {
+		c.batchSize = defaultCommitBatchSize
+	}
+	if c.interval <= 0 {
+		c.interval = defaultCommitInterval
+	}
+	return c
+}
+
+// fetch returns the next message, retrying transient errors. While
+// messages are waiting to be committed it fetches with a deadline, so they
+// are committed on time even when no new messages arrive.
+func (c *committer) fetch(ctx context.Context) (kafka.Message, error) {
+	for {
+		var message kafka.Message
+		due := false
+		err := c.retry(ctx, func() error {
+			fetchCtx, cancel := ctx, context.CancelFunc(func() {})
+			if len(c.pending) > 0 {
+				fetchCtx, cancel = context.WithDeadline(ctx, c.due)
+			}
+			defer cancel()
+
+			var err error
+			message, err = c.reader.FetchMessage(fetchCtx)
+			if err != nil && ctx.Err() == nil && fetchCtx.Err() != nil {
+				due = true
+				return nil
+			}
+			return err
+		})
+		if err != nil || !due {
+			return message, err
+		}
+		if err := c.commit(ctx); err != nil {
+			return kafka.Message{}, err
+		}
+	}
+}
+
+// done marks message as handled and commits the batch if it is full or due.
+func (c *committer) done(ctx context.Context, message kafka.Message) error {
+	if len(c.pending) == 0 {
+		c.due = time.Now().Add(c.interval)
+	}
+	c.pending = append(c.pending, message)
+	if len(c.pending) >= c.batchSize || !time.Now().Before(c.due) {
+		return c.commit(ctx)
+	}
+	return nil
+}
+
+func (c *committer) commit(ctx context.Context) error {
+	if len(c.pending) == 0 {
+		return nil
+	}
+	err := c.retry(ctx, func() error {
+		return c.reader.CommitMessages(ctx, c.pending...)
+	})
+	if err != nil {
+		return fmt.Errorf("failed to commit offsets: %w", err)
+	}
+	c.pending = c.pending[:0]
+	return nil
+}
+
+// close commits the messages handled before consumption stopped with err,
+// even if ctx has been canceled, and returns err joined with any commit
+// error.
+func (c *committer) close(ctx context.Context, err error) error {
+	commitCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), commitTimeout)
+	defer cancel()
+	if commitErr := c.commit(commitCtx); commitErr != nil {
+		return errors.Join(err, commitErr)
+	}
+	return err
+}
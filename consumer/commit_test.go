@@ -0,0 +1,183 @@
+package consumer
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"kafka-logger/filewriter"
+	"kafka-logger/mocks"
+	"kafka-logger/service"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+)
+
+func offsetMessages(t *testing.T, count int) []kafka.Message {
+	t.Helper()
+	var messages []kafka.Message
+	for i := range count {
+		value, err := json.Marshal(service.LogEvent{Level: service.INFO, Message: fmt.Sprintf("event %d", i), Service: "svc"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		messages = append(messages, kafka.Message{Offset: int64(i), Value: value})
+	}
+	return messages
+}
+
+func committedOffsets(reader *mocks.MockMessageReader) []int64 {
+	var offsets []int64
+	for _, msg := range reader.Committed {
+		offsets = append(offsets, msg.Offset)
+	}
+	return offsets
+}
+
+// failingLogWriter fails every write after the first ok writes.
+type failingLogWriter struct {
+	*mocks.MockLogFileWriter
+	ok int
+}
+
+func (w *failingLogWriter) WriteLog(level, message string) error {
+	if w.ok == 0 {
+		return errors.New("disk full")
+	}
+	w.ok--
+	return w.MockLogFileWriter.WriteLog(level, message)
+}
+
+// idleReader blocks once its messages run out, like a reader waiting for
+// new messages.
+type idleReader struct {
+	*mocks.MockMessageReader
+}
+
+func (r idleReader) FetchMessage(ctx context.Context) (kafka.Message, error) {
+	if r.Index >= len(r.Messages) {
+		<-ctx.Done()
+		return kafka.Message{}, ctx.Err()
+	}
+	return r.MockMessageReader.FetchMessage(ctx)
+}
+
+func TestCommitOffsets(t *testing.T) {
+	t.Parallel()
+
+	t.Run("Commits in batches after writing", func(t *testing.T) {
+		t.Parallel()
+		mockReader := &mocks.MockMessageReader{Messages: offsetMessages(t, 5)}
+		mockWriter := mocks.NewMockLogFileWriter()
+
+		err := ConsumeLogEventsToFiles(context.Background(), mockReader, mockWriter, WithCommitBatching(2, time.Hour))
+		if err != io.EOF {
+			t.Errorf("Expected EOF, got: %v", err)
+		}
+		if got := committedOffsets(mockReader); fmt.Sprint(got) != "[0 1 2 3 4]" {
+			t.Errorf("Expected every offset committed, got %v", got)
+		}
+		if mockReader.Commits != 3 {
+			t.Errorf("Expected 2 full batches and a final commit, got %d commits", mockReader.Commits)
+		}
+	})
+
+	t.Run("Unwritten events are not committed", func(t *testing.T) {
+		t.Parallel()
+		mockReader := &mocks.MockMessageReader{Messages: offsetMessages(t, 5)}
+		logWriter := &failingLogWriter{MockLogFileWriter: mocks.NewMockLogFileWriter(), ok: 2}
+
+		err := ConsumeLogEventsToFiles(context.Background(), mockReader, logWriter)
+		if err == nil || err.Error() != "failed to write log: disk full" {
+			t.Errorf("Expected the write error, got: %v", err)
+		}
+		if got := committedOffsets(mockReader); fmt.Sprint(got) != "[0 1]" {
+			t.Errorf("Expected only written offsets committed, got %v", got)
+		}
+	})
+
+	t.Run("Commits on the interval while idle", func(t *testing.T) {
+		t.Parallel()
+		mockReader := &mocks.MockMessageReader{Messages: offsetMessages(t, 2)}
+		mockWriter := mocks.NewMockLogFileWriter()
+
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		done := make(chan error)
+		go func() {
+			done <- ConsumeLogEventsToFiles(ctx, idleReader{mockReader}, mockWriter, WithCommitBatching(100, 10*time.Millisecond))
+		}()
+
+		deadline := time.Now().Add(time.Second)
+		for {
+			if len(mockReader.CommittedMessages()) == 2 {
+				break
+			}
+			if time.Now().After(deadline) {
+				t.Fatal("Timed out waiting for the interval commit")
+			}
+			time.Sleep(time.Millisecond)
+		}
+		cancel()
+		if err := <-done; err != context.Canceled {
+			t.Errorf("Expected context canceled, got: %v", err)
+		}
+	})
+
+	t.Run("Commit errors are returned", func(t *testing.T) {
+		t.Parallel()
+		commitErr := errors.New("coordinator not available")
+		mockReader := &mocks.MockMessageReader{Messages: offsetMessages(t, 1), CommitErr: commitErr}
+
+		err := ConsumeLogEventsToFiles(context.Background(), mockReader, mocks.NewMockLogFileWriter())
+		if !errors.Is(err, commitErr) || !errors.Is(err, io.EOF) {
+			t.Errorf("Expected the read and commit errors, got: %v", err)
+		}
+	})
+
+	t.Run("Restart after a lost commit repeats no lines", func(t *testing.T) {
+		t.Parallel()
+		dir := t.TempDir()
+		messages := offsetMessages(t, 5)
+
+		// The first run writes three events but its commit is lost.
+		first := filewriter.NewLogFileWriter(dir)
+		err := ConsumeLogEventsToFiles(context.Background(), &mocks.MockMessageReader{Messages: messages[:3]}, first)
+		if err != io.EOF {
+			t.Errorf("Expected EOF, got: %v", err)
+		}
+		first.Close()
+
+		// The second run is redelivered everything from the start.
+		second := filewriter.NewLogFileWriter(dir)
+		defer second.Close()
+		mockReader := &mocks.MockMessageReader{Messages: messages}
+		err = ConsumeLogEventsToFiles(context.Background(), mockReader, second)
+		if err != io.EOF {
+			t.Errorf("Expected EOF, got: %v", err)
+		}
+		if got := len(mockReader.Committed); got != 5 {
+			t.Errorf("Expected skipped messages to be committed too, got %d", got)
+		}
+
+		files, _ := filepath.Glob(filepath.Join(dir, "INFO_*.log"))
+		if len(files) != 1 {
+			t.Fatalf("Expected one INFO file, got %v", files)
+		}
+		data, _ := os.ReadFile(files[0])
+		lines := strings.Split(strings.TrimSuffix(string(data), "\n"), "\n")
+		if len(lines) != 5 {
+			t.Fatalf("Expected 5 lines, got %d:\n%s", len(lines), data)
+		}
+		for i, line := range lines {
+			if !strings.HasSuffix(line, fmt.Sprintf("event %d", i)) {
+				t.Errorf("Expected line %d to be event %d, got %q", i, i, line)
+			}
+		}
+	})
+}
@@ -2,18 +2,24 @@ package consumer
 
 import (
 	"context"
-	"encoding/json"
 	"fmt"
 	"io"
 	"kafka-logger/filewriter"
+	"kafka-logger/producer"
 	"kafka-logger/service"
+	"strings"
 	"time"
 
 	"github.com/segmentio/kafka-go"
 )
 
+// MessageReader reads messages from Kafka. ReadMessage commits the offset
+// as it returns, in a consumer group; FetchMessage leaves that to
+// CommitMessages, so a message can be committed once it has been handled.
 type MessageReader interface {
 	ReadMessage(ctx context.Context) (kafka.Message, error)
+	FetchMessage(ctx context.Context) (kafka.Message, error)
+	CommitMessages(ctx context.Context, msgs ...kafka.Message) error
 	Close() error
 }
 
@@ -26,6 +32,59 @@ func NewConsumer(brokers []string, topic, groupID string) MessageReader {
 	return reader
 }
 
+// NewPartitionConsumer reads a single partition from the first offset without
+// a consumer group, so every caller sees the whole partition.
+func NewPartitionConsumer(brokers []string, topic string, partition int) MessageReader {
+	reader := kafka.NewReader(kafka.ReaderConfig{
+		Brokers:   brokers,
+		Topic:     topic,
+		Partition: partition,
+	})
+	return reader
+}
+
+// ConsumeOption configures how log events are read from Kafka.
+type ConsumeOption func(*consumeOptions)
+
+type consumeOptions struct {
+	registry *service.RegistryClient
+	filters  []MessageFilter
+	tracker  *Tracker
+
+	errorGroups *ErrorGroups
+
+	commitBatchSize int
+	commitInterval  time.Duration
+
+	backoff Backoff
+	onRetry func(err error, delay time.Duration)
+
+	deadLetters producer.MessageWriter
+}
+
+// WithSchemaRegistry resolves the schema ID of wire format messages against
+// the registry before decoding them.
+func WithSchemaRegistry(client *service.RegistryClient) ConsumeOption {
+	return func(o *consumeOptions) {
+		o.registry = client
+	}
+}
+
+func newConsumeOptions(opts []ConsumeOption) consumeOptions {
+	var o consumeOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
+}
+
+func (o consumeOptions) decode(ctx context.Context, message kafka.Message) (service.LogEvent, error) {
+	if o.registry != nil {
+		return o.registry.DecodeMessage(ctx, message)
+	}
+	return service.DecodeMessage(message)
+}
+
 func ConsumeRawMessages(ctx context.Context, reader MessageReader, writer io.Writer) error {
 	for {
 		select {
@@ -41,7 +100,8 @@ func ConsumeRawMessages(ctx context.Context, reader MessageReader, writer io.Wri
 	}
 }
 
-func ConsumeLogEvents(ctx context.Context, reader MessageReader, writer io.Writer) error {
+func ConsumeLogEvents(ctx context.Context, reader MessageReader, writer io.Writer, opts ...ConsumeOption) error {
+	o := newConsumeOptions(opts)
 	for {
 		select {
 		case <-ctx.Done():
@@ -52,60 +112,196 @@ func ConsumeLogEvents(ctx context.Context, reader MessageReader, writer io.Write
 				return err
 			}
 
-			var logEvent service.LogEvent
-			if err := json.Unmarshal(message.Value, &logEvent); err != nil {
-				fmt.Fprintf(writer, "Error parsing log event: %v, Raw message: %s\n", err, string(message.Value))
+			if !o.accept(message) {
 				continue
 			}
 
-			timestamp := logEvent.Timestamp.Format(time.RFC3339)
+			logEvent, err := o.decode(ctx, message)
+			if err != nil {
+				fmt.Fprintf(writer, "Error parsing log event: %v, Raw message: %s\n", err, string(message.Value))
+				continue
+			}
 
-			if len(logEvent.Fields) > 0 {
-				fieldsStr := ""
-				for key, value := range logEvent.Fields {
-					fieldsStr += fmt.Sprintf(" %s=%v", key, value)
-				}
-				fmt.Fprintf(writer, "%s [%s] %s: %s%s\n", timestamp, logEvent.Level, logEvent.Service, logEvent.Message, fieldsStr)
-			} else {
-				fmt.Fprintf(writer, "%s [%s] %s: %s\n", timestamp, logEvent.Level, logEvent.Service, logEvent.Message)
+			duplicate, warnings := o.track(logEvent)
+			for _, warning := range warnings {
+				fmt.Fprintln(writer, formatLogEvent(warning))
+			}
+			if duplicate {
+				continue
 			}
+			if o.errorGroups != nil {
+				o.errorGroups.Add(logEvent)
+			}
+
+			fmt.Fprintln(writer, formatLogEvent(logEvent))
 		}
 	}
 }
 
-func ConsumeLogEventsToFiles(ctx context.Context, reader MessageReader, logWriter filewriter.LogWriter) error {
+// ConsumeLogEventsToFiles writes every event to logWriter. Offsets are
+// committed only after the event has been written, in batches set by
+// WithCommitBatching, so events are delivered at least once: a crash can
+// repeat events written since the last commit but never loses one.
+//
+// Transient read and commit errors are retried as set by WithRetry; other
+// errors stop consumption and are returned.
+//
+// If logWriter is a filewriter.CheckpointWriter, such as a LogFileWriter,
+// each line is recorded with its message's position and messages already
+// written are skipped, so a restart repeats no lines either.
+func ConsumeLogEventsToFiles(ctx context.Context, reader MessageReader, logWriter filewriter.LogWriter, opts ...ConsumeOption) (err error) {
+	o := newConsumeOptions(opts)
+	commits := o.newCommitter(reader)
+	defer func() {
+		err = commits.close(ctx, err)
+	}()
+
 	for {
 		select {
 		case <-ctx.Done():
 			return ctx.Err()
 		default:
-			message, err := reader.ReadMessage(ctx)
+			message, err := commits.fetch(ctx)
 			if err != nil {
 				return err
 			}
-
-			var logEvent service.LogEvent
-			if err := json.Unmarshal(message.Value, &logEvent); err != nil {
-				logWriter.WriteLog("ERROR", fmt.Sprintf("Error parsing log event: %v, Raw message: %s", err, string(message.Value)))
-				continue
+			if err := o.writeMessage(ctx, message, logWriter); err != nil {
+				return err
 			}
-
-			timestamp := logEvent.Timestamp.Format(time.RFC3339)
-
-			var logMessage string
-			if len(logEvent.Fields) > 0 {
-				fieldsStr := ""
-				for key, value := range logEvent.Fields {
-					fieldsStr += fmt.Sprintf(" %s=%v", key, value)
-				}
-				logMessage = fmt.Sprintf("%s [%s] %s: %s%s", timestamp, logEvent.Level, logEvent.Service, logEvent.Message, fieldsStr)
-			} else {
-				logMessage = fmt.Sprintf("%s [%s] %s: %s", timestamp, logEvent.Level, logEvent.Service, logEvent.Message)
+			if err := commits.done(ctx, message); err != nil {
+				return err
 			}
+		}
+	}
+}
 
-			if err := logWriter.WriteLog(string(logEvent.Level), logMessage); err != nil {
-				return fmt.Errorf("failed to write log: %w", err)
-			}
+// writeMessage writes the event in message, and any gap warnings it
+// causes, to logWriter. Filtered, duplicate and already written messages
+// are skipped.
+func (o consumeOptions) writeMessage(ctx context.Context, message kafka.Message, logWriter filewriter.LogWriter) error {
+	checkpoints, _ := logWriter.(filewriter.CheckpointWriter)
+	if checkpoints != nil {
+		last, ok, err := checkpoints.Written(message.Topic, message.Partition)
+		if err != nil {
+			return err
+		}
+		if ok && message.Offset <= last {
+			return nil
+		}
+	}
+	// writeLine records the message's position with the line that stands
+	// for it; warnings are written without one.
+	writeLine := func(level, line string, final bool) error {
+		var err error
+		if final && checkpoints != nil {
+			err = checkpoints.WriteLogAt(level, line, filewriter.Position{Topic: message.Topic, Partition: message.Partition, Offset: message.Offset})
+		} else {
+			err = logWriter.WriteLog(level, line)
+		}
+		if err != nil {
+			return fmt.Errorf("failed to write log: %w", err)
+		}
+		return nil
+	}
+
+	if !o.accept(message) {
+		return nil
+	}
+
+	logEvent, err := o.decode(ctx, message)
+	if err != nil {
+		if o.deadLetters != nil {
+			return o.sendDeadLetter(ctx, message, ReasonDecode, err)
 		}
+		return writeLine("ERROR", fmt.Sprintf("Error parsing log event: %v, Raw message: %s", err, string(message.Value)), true)
+	}
+
+	duplicate, warnings := o.track(logEvent)
+	for _, warning := range warnings {
+		if err := writeLine(string(warning.Level), formatLogEvent(warning), false); err != nil {
+			return err
+		}
+	}
+	if duplicate {
+		return nil
+	}
+	if o.errorGroups != nil {
+		o.errorGroups.Add(logEvent)
+	}
+	if o.deadLetters == nil {
+		return writeLine(string(logEvent.Level), formatLogEvent(logEvent), true)
+	}
+	err = o.writeWithRetry(ctx, func() error {
+		return writeLine(string(logEvent.Level), formatLogEvent(logEvent), true)
+	})
+	if err != nil && ctx.Err() == nil {
+		return o.sendDeadLetter(ctx, message, ReasonWrite, err)
+	}
+	return err
+}
+
+// writeWithRetry makes up to defaultWriteAttempts attempts at write, waiting
+// with backoff in between, so a message is only dead-lettered once a full
+// disk or a rotated directory has had a chance to recover.
+func (o consumeOptions) writeWithRetry(ctx context.Context, write func() error) error {
+	var err error
+	for attempt := range defaultWriteAttempts {
+		if attempt > 0 && !sleep(ctx, o.backoff.Delay(attempt-1)) {
+			break
+		}
+		if err = write(); err == nil {
+			return nil
+		}
+	}
+	return err
+}
+
+// continuationIndent starts every line after the first of a formatted event,
+// so tools that read one event per line can fold them back together.
+const continuationIndent = "    "
+
+// formatLogEvent renders an event as a single line, followed by indented
+// continuation lines for the caller, error chain and stack trace when
+// present. Events with an error end the first line with its root cause
+// type, so they can be grouped with line-oriented tools.
+func formatLogEvent(logEvent service.LogEvent) string {
+	timestamp := logEvent.Timestamp.Format(time.RFC3339)
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "%s [%s] %s: %s", timestamp, logEvent.Level, logEvent.Service, logEvent.Message)
+	for key, value := range logEvent.Fields {
+		fmt.Fprintf(&sb, " %s=%v", key, value)
+	}
+	if rootCause := RootCauseType(logEvent); rootCause != "" {
+		fmt.Fprintf(&sb, " root_cause=%s", rootCause)
+	}
+
+	if logEvent.Caller != nil {
+		sb.WriteString("\n" + continuationIndent + "at " + logEvent.Caller.String())
+	}
+	if logEvent.Error != nil {
+		writeErrorInfo(&sb, logEvent.Error, "error", 1)
+	}
+	writeStack(&sb, logEvent.Stack, 2)
+	return sb.String()
+}
+
+// writeErrorInfo writes one line per error in the chain, each cause indented
+// under the error that wraps it, with any stack the error carried below it.
+func writeErrorInfo(sb *strings.Builder, info *service.ErrorInfo, label string, depth int) {
+	fmt.Fprintf(sb, "\n%s%s %s: %s", strings.Repeat(continuationIndent, depth), label, info.Type, info.Message)
+	writeStack(sb, info.Stack, depth+1)
+	for _, cause := range info.Causes {
+		writeErrorInfo(sb, cause, "caused by", depth+1)
+	}
+}
+
+func writeStack(sb *strings.Builder, stack string, depth int) {
+	if stack == "" {
+		return
+	}
+	indent := strings.Repeat(continuationIndent, depth)
+	for _, line := range strings.Split(strings.TrimRight(stack, "\n"), "\n") {
+		sb.WriteString("\n" + indent + strings.ReplaceAll(line, "\t", continuationIndent))
 	}
 }
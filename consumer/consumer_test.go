@@ -26,6 +26,18 @@ func TestNewConsumer(t *testing.T) {
 	}
 }
 
+func TestNewPartitionConsumer(t *testing.T) {
+	brokers := []string{"localhost:9092"}
+	topic := "test-topic"
+
+	consumer := NewPartitionConsumer(brokers, topic, 0)
+	defer consumer.Close()
+
+	if consumer == nil {
+		t.Error("Consumer should not be nil")
+	}
+}
+
 func TestConsumePlainMessages(t *testing.T) {
 	t.Parallel()
 
@@ -487,6 +499,164 @@ func TestConsumeLogEventsToFiles(t *testing.T) {
 		assertBasicLogOutput(t, logEntry, mockTimestamp, testLogLevel, testServiceKey, testMessage)
 	})
 
+	t.Run("Caller and stack as continuation lines", func(t *testing.T) {
+		t.Parallel()
+		mockTimestamp := time.Date(2024, 1, 15, 10, 30, 45, 0, time.UTC)
+		testServiceKey := "test-service"
+
+		logEvent := service.LogEvent{
+			Timestamp: mockTimestamp,
+			Level:     service.ERROR,
+			Message:   "payment failed",
+			Service:   testServiceKey,
+			Caller:    &service.Caller{File: "/src/pay.go", Line: 12, Function: "main.pay"},
+			Stack:     "main.pay\n\t/src/pay.go:12\nmain.main\n\t/src/main.go:5\n",
+		}
+		data, err := json.Marshal(logEvent)
+		if err != nil {
+			t.Fatalf("Failed to marshal log event: %v", err)
+		}
+
+		mockReader := &mocks.MockMessageReader{
+			Messages: []kafka.Message{{Key: []byte(testServiceKey), Value: data}},
+		}
+		mockWriter := mocks.NewMockLogFileWriter()
+
+		err = ConsumeLogEventsToFiles(context.Background(), mockReader, mockWriter)
+		if err != io.EOF {
+			t.Errorf("Expected EOF, got: %v", err)
+		}
+
+		logs := mockWriter.Logs[string(service.ERROR)]
+		if len(logs) != 1 {
+			t.Fatalf("Expected 1 ERROR entry, got %d", len(logs))
+		}
+
+		expected := mockTimestamp.Format(time.RFC3339) + " [ERROR] test-service: payment failed\n" +
+			"    at main.pay (/src/pay.go:12)\n" +
+			"        main.pay\n" +
+			"            /src/pay.go:12\n" +
+			"        main.main\n" +
+			"            /src/main.go:5"
+		if logs[0] != expected {
+			t.Errorf("Expected:\n%s\ngot:\n%s", expected, logs[0])
+		}
+	})
+
+	t.Run("Large integers keep their precision", func(t *testing.T) {
+		t.Parallel()
+		value := `{"timestamp":"2024-01-15T10:30:45Z","level":"INFO","message":"ids","service":"test-service","fields":{"user_id":9007199254740993}}`
+		mockReader := &mocks.MockMessageReader{
+			Messages: []kafka.Message{{Key: []byte("test-service"), Value: []byte(value)}},
+		}
+		mockWriter := mocks.NewMockLogFileWriter()
+
+		err := ConsumeLogEventsToFiles(context.Background(), mockReader, mockWriter)
+		if err != io.EOF {
+			t.Errorf("Expected EOF, got: %v", err)
+		}
+
+		logs := mockWriter.Logs[string(service.INFO)]
+		if len(logs) != 1 || !strings.Contains(logs[0], "user_id=9007199254740993") {
+			t.Errorf("Expected the exact user_id, got %v", logs)
+		}
+	})
+
+	t.Run("Mixed encodings", func(t *testing.T) {
+		t.Parallel()
+		mockTimestamp := time.Date(2024, 1, 15, 10, 30, 45, 0, time.UTC)
+		testServiceKey := "test-service"
+
+		jsonEvent := service.LogEvent{Timestamp: mockTimestamp, Level: service.WARN, Message: "json event", Service: testServiceKey}
+		msgpackEvent := service.LogEvent{Timestamp: mockTimestamp, Level: service.WARN, Message: "msgpack event", Service: testServiceKey}
+
+		jsonData, err := json.Marshal(jsonEvent)
+		if err != nil {
+			t.Fatalf("Failed to marshal log event: %v", err)
+		}
+		msgpackData, err := service.MsgpackCodec{}.Encode(msgpackEvent)
+		if err != nil {
+			t.Fatalf("Failed to encode log event: %v", err)
+		}
+
+		mockReader := &mocks.MockMessageReader{
+			Messages: []kafka.Message{
+				{Key: []byte(testServiceKey), Value: jsonData},
+				{
+					Key:     []byte(testServiceKey),
+					Value:   msgpackData,
+					Headers: []kafka.Header{{Key: service.ContentTypeHeader, Value: []byte(service.ContentTypeMsgpack)}},
+				},
+			},
+		}
+
+		mockWriter := mocks.NewMockLogFileWriter()
+
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		defer cancel()
+
+		err = ConsumeLogEventsToFiles(ctx, mockReader, mockWriter)
+		if err != io.EOF && err != context.DeadlineExceeded {
+			t.Errorf("Expected EOF or context timeout, got: %v", err)
+		}
+
+		logs := mockWriter.Logs[string(service.WARN)]
+		if len(logs) != 2 {
+			t.Fatalf("Expected 2 log entries, got %d", len(logs))
+		}
+		assertBasicLogOutput(t, logs[0], mockTimestamp, service.WARN, testServiceKey, "json event")
+		assertBasicLogOutput(t, logs[1], mockTimestamp, service.WARN, testServiceKey, "msgpack event")
+	})
+
+	t.Run("Schema registry wire format", func(t *testing.T) {
+		t.Parallel()
+		registry := mocks.NewSchemaRegistry()
+		defer registry.Close()
+
+		mockTimestamp := time.Date(2024, 1, 15, 10, 30, 45, 0, time.UTC)
+		testServiceKey := "test-service"
+		client := service.NewRegistryClient(registry.URL, nil)
+
+		schemaID, err := client.Register(context.Background(), service.TopicSubject("logs-topic"), service.Schema{
+			Schema:     service.LogEventSchema,
+			SchemaType: service.SchemaTypeJSON,
+		})
+		if err != nil {
+			t.Fatalf("Failed to register schema: %v", err)
+		}
+
+		data, err := json.Marshal(service.LogEvent{Timestamp: mockTimestamp, Level: service.ERROR, Message: "registered", Service: testServiceKey})
+		if err != nil {
+			t.Fatalf("Failed to marshal log event: %v", err)
+		}
+
+		mockReader := &mocks.MockMessageReader{
+			Messages: []kafka.Message{
+				{Key: []byte(testServiceKey), Value: service.EncodeWireFormat(schemaID, data)},
+				{Key: []byte(testServiceKey), Value: service.EncodeWireFormat(schemaID+1, data)},
+			},
+		}
+
+		mockWriter := mocks.NewMockLogFileWriter()
+
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+
+		err = ConsumeLogEventsToFiles(ctx, mockReader, mockWriter, WithSchemaRegistry(service.NewRegistryClient(registry.URL, nil)))
+		if err != io.EOF && err != context.DeadlineExceeded {
+			t.Errorf("Expected EOF or context timeout, got: %v", err)
+		}
+
+		logs := mockWriter.Logs[string(service.ERROR)]
+		if len(logs) != 2 {
+			t.Fatalf("Expected 2 ERROR entries, got %d", len(logs))
+		}
+		assertBasicLogOutput(t, logs[0], mockTimestamp, service.ERROR, testServiceKey, "registered")
+		if !strings.Contains(logs[1], "Error parsing log event") || !strings.Contains(logs[1], "schema not found") {
+			t.Errorf("Expected unknown schema error, got: %s", logs[1])
+		}
+	})
+
 	t.Run("Invalid JSON", func(t *testing.T) {
 		t.Parallel()
 		testServiceKey := "test-service"
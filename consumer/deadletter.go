@@ -0,0 +1,127 @@
+package consumer
+
+import (
+	"context"
+	"fmt"
+	"kafka-logger/producer"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+)
+
+// Headers added to messages sent to the dead-letter topic. The original
+// key, value and headers are kept.
+const (
+	DeadLetterReasonHeader    = "dlq-reason"
+	DeadLetterErrorHeader     = "dlq-error"
+	DeadLetterTopicHeader     = "dlq-topic"
+	DeadLetterPartitionHeader = "dlq-partition"
+	DeadLetterOffsetHeader    = "dlq-offset"
+	DeadLetterTimeHeader      = "dlq-failed-at"
+)
+
+// deadLetterHeaderPrefix is shared by the headers above, so they can be
+// removed before a message is re-driven.
+const deadLetterHeaderPrefix = "dlq-"
+
+// Reasons a message is dead-lettered.
+const (
+	ReasonDecode = "decode"
+	ReasonWrite  = "write"
+)
+
+// defaultWriteAttempts is how many times a line is written before its
+// message is dead-lettered.
+const defaultWriteAttempts = 3
+
+// WithDeadLetter sends messages that cannot be decoded, or whose line cannot
+// be written after retries, to writer instead of stopping or writing an
+// error line. They are then committed like any other message. If writer
+// fails too, consumption stops with the error so the message is not lost.
+func WithDeadLetter(writer producer.MessageWriter) ConsumeOption {
+	return func(o *consumeOptions) {
+		o.deadLetters = writer
+	}
+}
+
+// sendDeadLetter sends message to the dead-letter topic with headers that
+// describe the failure.
+func (o consumeOptions) sendDeadLetter(ctx context.Context, message kafka.Message, reason string, cause error) error {
+	dead := DeadLetterMessage(message, reason, cause, time.Now())
+	err := o.retry(ctx, func() error {
+		return o.deadLetters.WriteMessages(ctx, dead)
+	})
+	if err != nil {
+		return fmt.Errorf("failed to dead-letter message %s/%d@%d: %w", message.Topic, message.Partition, message.Offset, err)
+	}
+	return nil
+}
+
+// DeadLetterMessage returns the message to send to the dead-letter topic for
+// message. The source topic, partition and offset move to headers, since
+// the writer chooses the destination.
+func DeadLetterMessage(message kafka.Message, reason string, cause error, failedAt time.Time) kafka.Message {
+	headers := make([]kafka.Header, 0, len(message.Headers)+6)
+	for _, header := range message.Headers {
+		if !strings.HasPrefix(header.Key, deadLetterHeaderPrefix) {
+			headers = append(headers, header)
+		}
+	}
+	headers = append(headers,
+		kafka.Header{Key: DeadLetterReasonHeader, Value: []byte(reason)},
+		kafka.Header{Key: DeadLetterErrorHeader, Value: []byte(cause.Error())},
+		kafka.Header{Key: DeadLetterTopicHeader, Value: []byte(message.Topic)},
+		kafka.Header{Key: DeadLetterPartitionHeader, Value: []byte(strconv.Itoa(message.Partition))},
+		kafka.Header{Key: DeadLetterOffsetHeader, Value: []byte(strconv.FormatInt(message.Offset, 10))},
+		kafka.Header{Key: DeadLetterTimeHeader, Value: []byte(failedAt.UTC().Format(time.RFC3339))},
+	)
+	return kafka.Message{Key: message.Key, Value: message.Value, Headers: headers, Time: message.Time}
+}
+
+// DeadLetter is a message read back from the dead-letter topic.
+type DeadLetter struct {
+	Reason    string
+	Error     string
+	Topic     string
+	Partition int
+	Offset    int64
+	FailedAt  time.Time
+	// Message is the original message, without the dead-letter headers,
+	// addressed to its source topic.
+	Message kafka.Message
+}
+
+// ParseDeadLetter reads the failure details from a dead-letter message.
+func ParseDeadLetter(message kafka.Message) (DeadLetter, error) {
+	dl := DeadLetter{Message: kafka.Message{Key: message.Key, Value: message.Value, Time: message.Time}}
+	for _, header := range message.Headers {
+		value := string(header.Value)
+		var err error
+		switch header.Key {
+		case DeadLetterReasonHeader:
+			dl.Reason = value
+		case DeadLetterErrorHeader:
+			dl.Error = value
+		case DeadLetterTopicHeader:
+			dl.Topic = value
+		case DeadLetterPartitionHeader:
+			dl.Partition, err = strconv.Atoi(value)
+		case DeadLetterOffsetHeader:
+			dl.Offset, err = strconv.ParseInt(value, 10, 64)
+		case DeadLetterTimeHeader:
+			dl.FailedAt, err = time.Parse(time.RFC3339, value)
+		default:
+			dl.Message.Headers = append(dl.Message.Headers, header)
+		}
+		if err != nil {
+			return DeadLetter{}, fmt.Errorf("invalid %s header: %w", header.Key, err)
+		}
+	}
+	if dl.Reason == "" || dl.Topic == "" {
+		return DeadLetter{}, fmt.Errorf("not a dead-letter message: missing %s or %s header", DeadLetterReasonHeader, DeadLetterTopicHeader)
+	}
+	dl.Message.Topic = dl.Topic
+	return dl, nil
+}
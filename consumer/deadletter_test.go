@@ -0,0 +1,134 @@
+package consumer
+
+import (
+	"context"
+	"errors"
+	"io"
+	"kafka-logger/mocks"
+	"kafka-logger/service"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+)
+
+func TestDeadLetter(t *testing.T) {
+	t.Parallel()
+
+	t.Run("Undecodable messages are dead-lettered", func(t *testing.T) {
+		t.Parallel()
+		messages := offsetMessages(t, 2)
+		bad := kafka.Message{
+			Topic:     "logs",
+			Partition: 2,
+			Offset:    41,
+			Key:       []byte("svc"),
+			Value:     []byte("{not json"),
+			Headers:   []kafka.Header{{Key: "service", Value: []byte("svc")}},
+		}
+		mockReader := &mocks.MockMessageReader{Messages: []kafka.Message{messages[0], bad, messages[1]}}
+		mockWriter := mocks.NewMockLogFileWriter()
+		deadLetters := &mocks.MockMessageWriter{}
+
+		err := ConsumeLogEventsToFiles(context.Background(), mockReader, mockWriter, WithDeadLetter(deadLetters))
+		if err != io.EOF {
+			t.Errorf("Expected EOF, got: %v", err)
+		}
+		if len(mockWriter.Logs["ERROR"]) != 0 || len(mockWriter.Logs["INFO"]) != 2 {
+			t.Errorf("Expected only the good events in files, got %v", mockWriter.Logs)
+		}
+		if len(mockReader.Committed) != 3 {
+			t.Errorf("Expected the dead-lettered message to be committed, got %d commits", len(mockReader.Committed))
+		}
+
+		if len(deadLetters.Messages) != 1 {
+			t.Fatalf("Expected 1 dead letter, got %d", len(deadLetters.Messages))
+		}
+		dead := deadLetters.Messages[0]
+		if dead.Topic != "" || string(dead.Value) != "{not json" || string(dead.Key) != "svc" {
+			t.Errorf("Expected the original key and value without a topic, got %+v", dead)
+		}
+		for key, expected := range map[string]string{
+			"service":                 "svc",
+			DeadLetterReasonHeader:    ReasonDecode,
+			DeadLetterTopicHeader:     "logs",
+			DeadLetterPartitionHeader: "2",
+			DeadLetterOffsetHeader:    "41",
+		} {
+			if got, _ := service.HeaderValue(dead.Headers, key); got != expected {
+				t.Errorf("Expected header %s=%q, got %q", key, expected, got)
+			}
+		}
+		if _, ok := service.HeaderValue(dead.Headers, DeadLetterErrorHeader); !ok {
+			t.Errorf("Expected the decode error in a header")
+		}
+	})
+
+	t.Run("Unwritable messages are dead-lettered after retries", func(t *testing.T) {
+		t.Parallel()
+		mockReader := &mocks.MockMessageReader{Messages: offsetMessages(t, 2)}
+		logWriter := &failingLogWriter{MockLogFileWriter: mocks.NewMockLogFileWriter(), ok: 1}
+		deadLetters := &mocks.MockMessageWriter{}
+
+		err := ConsumeLogEventsToFiles(context.Background(), mockReader, logWriter,
+			WithDeadLetter(deadLetters), WithRetry(Backoff{Initial: time.Millisecond, Max: time.Millisecond}, nil))
+		if err != io.EOF {
+			t.Errorf("Expected EOF, got: %v", err)
+		}
+		if len(deadLetters.Messages) != 1 {
+			t.Fatalf("Expected 1 dead letter, got %d", len(deadLetters.Messages))
+		}
+		dead := deadLetters.Messages[0]
+		reason, _ := service.HeaderValue(dead.Headers, DeadLetterReasonHeader)
+		offset, _ := service.HeaderValue(dead.Headers, DeadLetterOffsetHeader)
+		if reason != ReasonWrite || offset != "1" {
+			t.Errorf("Unexpected dead letter headers %v", dead.Headers)
+		}
+		if cause, _ := service.HeaderValue(dead.Headers, DeadLetterErrorHeader); !strings.Contains(cause, "disk full") {
+			t.Errorf("Expected the write error in a header, got %q", cause)
+		}
+	})
+
+	t.Run("Consumption stops if the dead-letter write fails", func(t *testing.T) {
+		t.Parallel()
+		mockReader := &mocks.MockMessageReader{Messages: []kafka.Message{{Value: []byte("garbage")}}}
+		deadLetters := &mocks.MockMessageWriter{WriteErr: errors.New("topic authorization failed")}
+
+		err := ConsumeLogEventsToFiles(context.Background(), mockReader, mocks.NewMockLogFileWriter(), WithDeadLetter(deadLetters))
+		if err == nil || !strings.Contains(err.Error(), "failed to dead-letter message") {
+			t.Errorf("Expected the dead-letter error, got: %v", err)
+		}
+		if len(mockReader.Committed) != 0 {
+			t.Errorf("Expected nothing committed, got %d", len(mockReader.Committed))
+		}
+	})
+
+	t.Run("Parse round trip", func(t *testing.T) {
+		t.Parallel()
+		failedAt := time.Date(2024, 1, 15, 10, 30, 45, 0, time.UTC)
+		original := kafka.Message{
+			Topic:     "logs",
+			Partition: 1,
+			Offset:    7,
+			Key:       []byte("svc"),
+			Value:     []byte("value"),
+			Headers:   []kafka.Header{{Key: "level", Value: []byte("INFO")}},
+		}
+
+		dl, err := ParseDeadLetter(DeadLetterMessage(original, ReasonWrite, errors.New("disk full"), failedAt))
+		if err != nil {
+			t.Fatalf("ParseDeadLetter failed: %v", err)
+		}
+		if dl.Reason != ReasonWrite || dl.Error != "disk full" || dl.Topic != "logs" || dl.Partition != 1 || dl.Offset != 7 || !dl.FailedAt.Equal(failedAt) {
+			t.Errorf("Unexpected dead letter %+v", dl)
+		}
+		if dl.Message.Topic != "logs" || len(dl.Message.Headers) != 1 || string(dl.Message.Value) != "value" {
+			t.Errorf("Expected the original message for re-driving, got %+v", dl.Message)
+		}
+
+		if _, err := ParseDeadLetter(original); err == nil {
+			t.Errorf("Expected an error for a message without dead-letter headers")
+		}
+	})
+}
@@ -0,0 +1,79 @@
+package consumer
+
+import (
+	"kafka-logger/service"
+	"sort"
+	"sync"
+)
+
+// RootCauseType returns the type of the innermost error of the event's
+// structured error, such as "*fs.PathError", or "" if it has none. Events
+// whose errors were wrapped differently on the way up still share it.
+func RootCauseType(event service.LogEvent) string {
+	if root := event.Error.RootCause(); root != nil {
+		return root.Type
+	}
+	return ""
+}
+
+// ErrorGroup is the number of events seen for one root cause type, with the
+// most recent one as an example.
+type ErrorGroup struct {
+	RootCause string
+	Count     uint64
+	Latest    service.LogEvent
+}
+
+// ErrorGroups counts consumed events with a structured error by root cause
+// type. It is safe to share between consumers.
+type ErrorGroups struct {
+	mu     sync.Mutex
+	groups map[string]*ErrorGroup
+}
+
+func NewErrorGroups() *ErrorGroups {
+	return &ErrorGroups{groups: make(map[string]*ErrorGroup)}
+}
+
+// WithErrorGroups adds every consumed event with a structured error to
+// groups.
+func WithErrorGroups(groups *ErrorGroups) ConsumeOption {
+	return func(o *consumeOptions) {
+		o.errorGroups = groups
+	}
+}
+
+// Add counts event if it has a structured error.
+func (g *ErrorGroups) Add(event service.LogEvent) {
+	rootCause := RootCauseType(event)
+	if rootCause == "" {
+		return
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	group, ok := g.groups[rootCause]
+	if !ok {
+		group = &ErrorGroup{RootCause: rootCause}
+		g.groups[rootCause] = group
+	}
+	group.Count++
+	group.Latest = event
+}
+
+// Groups returns the groups, most frequent first.
+func (g *ErrorGroups) Groups() []ErrorGroup {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	groups := make([]ErrorGroup, 0, len(g.groups))
+	for _, group := range g.groups {
+		groups = append(groups, *group)
+	}
+	sort.Slice(groups, func(i, j int) bool {
+		if groups[i].Count != groups[j].Count {
+			return groups[i].Count > groups[j].Count
+		}
+		return groups[i].RootCause < groups[j].RootCause
+	})
+	return groups
+}
@@ -0,0 +1,89 @@
+package consumer
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"kafka-logger/mocks"
+	"kafka-logger/service"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+)
+
+func errorEvent(message string, info *service.ErrorInfo) service.LogEvent {
+	return service.LogEvent{
+		Timestamp: time.Date(2024, 1, 15, 10, 30, 45, 0, time.UTC),
+		Level:     service.ERROR,
+		Message:   message,
+		Service:   "api",
+		Error:     info,
+	}
+}
+
+func TestFormatErrorInfo(t *testing.T) {
+	event := errorEvent("save failed", &service.ErrorInfo{
+		Message: "save order: open /data/o-1: permission denied",
+		Type:    "*fmt.wrapError",
+		Causes: []*service.ErrorInfo{{
+			Message: "open /data/o-1: permission denied",
+			Type:    "*fs.PathError",
+			Stack:   "main.save\n\t/src/app/save.go:12\n",
+			Causes:  []*service.ErrorInfo{{Message: "permission denied", Type: "syscall.Errno"}},
+		}},
+	})
+
+	expected := strings.Join([]string{
+		"2024-01-15T10:30:45Z [ERROR] api: save failed root_cause=syscall.Errno",
+		"    error *fmt.wrapError: save order: open /data/o-1: permission denied",
+		"        caused by *fs.PathError: open /data/o-1: permission denied",
+		"            main.save",
+		"                /src/app/save.go:12",
+		"            caused by syscall.Errno: permission denied",
+	}, "\n")
+	if got := formatLogEvent(event); got != expected {
+		t.Errorf("Expected:\n%s\ngot:\n%s", expected, got)
+	}
+}
+
+func TestErrorGroups(t *testing.T) {
+	t.Parallel()
+
+	errno := &service.ErrorInfo{Message: "connection refused", Type: "syscall.Errno"}
+	events := []service.LogEvent{
+		errorEvent("dial failed", errno),
+		errorEvent("query failed", &service.ErrorInfo{Message: "query: connection refused", Type: "*fmt.wrapError", Causes: []*service.ErrorInfo{errno}}),
+		errorEvent("timed out", &service.ErrorInfo{Message: "context deadline exceeded", Type: "context.deadlineExceededError"}),
+		{Level: service.INFO, Message: "no error"},
+	}
+	var messages []kafka.Message
+	for _, event := range events {
+		value, err := json.Marshal(event)
+		if err != nil {
+			t.Fatal(err)
+		}
+		messages = append(messages, kafka.Message{Value: value})
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	groups := NewErrorGroups()
+	err := ConsumeLogEventsToFiles(ctx, &mocks.MockMessageReader{Messages: messages}, mocks.NewMockLogFileWriter(), WithErrorGroups(groups))
+	if err != io.EOF {
+		t.Errorf("Expected EOF, got: %v", err)
+	}
+
+	got := groups.Groups()
+	if len(got) != 2 {
+		t.Fatalf("Expected 2 groups, got %+v", got)
+	}
+	if got[0].RootCause != "syscall.Errno" || got[0].Count != 2 || got[0].Latest.Message != "query failed" {
+		t.Errorf("Unexpected first group %+v", got[0])
+	}
+	if got[1].RootCause != "context.deadlineExceededError" || got[1].Count != 1 {
+		t.Errorf("Unexpected second group %+v", got[1])
+	}
+}
@@ -0,0 +1,89 @@
+package consumer
+
+import (
+	"context"
+	"kafka-logger/service"
+	"slices"
+
+	"github.com/segmentio/kafka-go"
+)
+
+// MessageFilter decides from header metadata alone whether a message is
+// worth decoding. hasMeta is false for messages written without headers.
+type MessageFilter func(meta service.EventMetadata, hasMeta bool) bool
+
+// WithFilter skips messages rejected by filter before they are decoded.
+func WithFilter(filter MessageFilter) ConsumeOption {
+	return func(o *consumeOptions) {
+		o.filters = append(o.filters, filter)
+	}
+}
+
+// MinLevel accepts events at min or above. Messages without headers are
+// accepted so they are not lost; their level is only known after decoding.
+func MinLevel(min service.LogLevel) MessageFilter {
+	return func(meta service.EventMetadata, hasMeta bool) bool {
+		return !hasMeta || meta.Level.AtLeast(min)
+	}
+}
+
+// FromServices accepts events published by one of the named services.
+// Messages without headers are accepted, as with MinLevel.
+func FromServices(names ...string) MessageFilter {
+	return func(meta service.EventMetadata, hasMeta bool) bool {
+		return !hasMeta || slices.Contains(names, meta.Service)
+	}
+}
+
+func (o consumeOptions) accept(message kafka.Message) bool {
+	if len(o.filters) == 0 {
+		return true
+	}
+	meta, hasMeta := service.MetadataFromMessage(message)
+	for _, filter := range o.filters {
+		if !filter(meta, hasMeta) {
+			return false
+		}
+	}
+	return true
+}
+
+// MessageHandler processes a raw message, typically by decoding it or
+// forwarding it elsewhere.
+type MessageHandler func(ctx context.Context, message kafka.Message) error
+
+// RouteMessages hands each message to the handler chosen by route from its
+// header metadata, without decoding the value. Messages for which route
+// returns nil are skipped. A handler error stops consumption.
+func RouteMessages(ctx context.Context, reader MessageReader, route func(meta service.EventMetadata, hasMeta bool) MessageHandler) error {
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		default:
+			message, err := reader.ReadMessage(ctx)
+			if err != nil {
+				return err
+			}
+
+			handler := route(service.MetadataFromMessage(message))
+			if handler == nil {
+				continue
+			}
+			if err := handler(ctx, message); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// ByLevel routes on the level header. Messages without headers, or with a
+// level that has no handler, go to fallback, which may be nil.
+func ByLevel(handlers map[service.LogLevel]MessageHandler, fallback MessageHandler) func(service.EventMetadata, bool) MessageHandler {
+	return func(meta service.EventMetadata, hasMeta bool) MessageHandler {
+		if handler, ok := handlers[meta.Level]; ok && hasMeta {
+			return handler
+		}
+		return fallback
+	}
+}
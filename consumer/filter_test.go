@@ -0,0 +1,110 @@
+package consumer
+
+import (
+	"context"
+	"io"
+	"kafka-logger/mocks"
+	"kafka-logger/service"
+	"testing"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+)
+
+func headerMessage(level service.LogLevel, serviceName, value string) kafka.Message {
+	return kafka.Message{
+		Key:   []byte(serviceName),
+		Value: []byte(value),
+		Headers: []kafka.Header{
+			{Key: service.LevelHeader, Value: []byte(level)},
+			{Key: service.ServiceHeader, Value: []byte(serviceName)},
+		},
+	}
+}
+
+func TestConsumeWithFilter(t *testing.T) {
+	t.Parallel()
+	mockTimestamp := time.Date(2024, 1, 15, 10, 30, 45, 0, time.UTC)
+
+	// Values of filtered messages are not valid JSON, so decoding them would
+	// show up as a parse error.
+	mockReader := &mocks.MockMessageReader{
+		Messages: []kafka.Message{
+			headerMessage(service.DEBUG, "api", "not decoded"),
+			headerMessage(service.ERROR, "worker", "not decoded"),
+			headerMessage(service.ERROR, "api", `{"timestamp":"2024-01-15T10:30:45Z","level":"ERROR","message":"kept","service":"api"}`),
+			{Value: []byte(`{"timestamp":"2024-01-15T10:30:45Z","level":"WARN","message":"legacy","service":"api"}`)},
+		},
+	}
+	mockWriter := mocks.NewMockLogFileWriter()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	err := ConsumeLogEventsToFiles(ctx, mockReader, mockWriter,
+		WithFilter(MinLevel(service.WARN)),
+		WithFilter(FromServices("api")))
+	if err != io.EOF {
+		t.Errorf("Expected EOF, got: %v", err)
+	}
+
+	errorLogs := mockWriter.Logs[string(service.ERROR)]
+	if len(errorLogs) != 1 {
+		t.Fatalf("Expected 1 ERROR entry, got %d: %v", len(errorLogs), errorLogs)
+	}
+	assertBasicLogOutput(t, errorLogs[0], mockTimestamp, service.ERROR, "api", "kept")
+
+	if len(mockWriter.Logs[string(service.WARN)]) != 1 {
+		t.Errorf("Expected the message without headers to be decoded, got %v", mockWriter.Logs)
+	}
+	if len(mockWriter.Logs[string(service.DEBUG)]) != 0 {
+		t.Errorf("Expected DEBUG to be filtered, got %v", mockWriter.Logs[string(service.DEBUG)])
+	}
+}
+
+func TestRouteMessages(t *testing.T) {
+	t.Parallel()
+	mockReader := &mocks.MockMessageReader{
+		Messages: []kafka.Message{
+			headerMessage(service.ERROR, "api", "e1"),
+			headerMessage(service.INFO, "api", "i1"),
+			headerMessage(service.DEBUG, "api", "d1"),
+			{Value: []byte("legacy")},
+			headerMessage(service.ERROR, "api", "e2"),
+		},
+	}
+
+	routed := make(map[string][]string)
+	collect := func(name string) MessageHandler {
+		return func(ctx context.Context, message kafka.Message) error {
+			routed[name] = append(routed[name], string(message.Value))
+			return nil
+		}
+	}
+
+	err := RouteMessages(context.Background(), mockReader, ByLevel(map[service.LogLevel]MessageHandler{
+		service.ERROR: collect("errors"),
+		service.INFO:  collect("info"),
+	}, collect("other")))
+	if err != io.EOF {
+		t.Errorf("Expected EOF, got: %v", err)
+	}
+
+	expected := map[string][]string{
+		"errors": {"e1", "e2"},
+		"info":   {"i1"},
+		"other":  {"d1", "legacy"},
+	}
+	for name, values := range expected {
+		if len(routed[name]) != len(values) {
+			t.Errorf("Expected %s to receive %v, got %v", name, values, routed[name])
+			continue
+		}
+		for i := range values {
+			if routed[name][i] != values[i] {
+				t.Errorf("Expected %s to receive %v, got %v", name, values, routed[name])
+				break
+			}
+		}
+	}
+}
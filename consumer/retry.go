@@ -0,0 +1,111 @@
+package consumer
+
+import (
+	"context"
+	"errors"
+	"io"
+	"math/rand/v2"
+	"net"
+	"syscall"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+)
+
+// IsTransient reports whether err is worth retrying on the same reader: a
+// network failure, a broker that is moving leadership or a consumer group
+// that is rebalancing. A closed reader, a canceled context, a failed file
+// write and unrecognized errors are not.
+func IsTransient(err error) bool {
+	switch {
+	case err == nil, errors.Is(err, context.Canceled), errors.Is(err, io.EOF):
+		return false
+	case errors.Is(err, io.ErrUnexpectedEOF),
+		errors.Is(err, syscall.ECONNREFUSED),
+		errors.Is(err, syscall.ECONNRESET),
+		errors.Is(err, syscall.EPIPE):
+		return true
+	}
+
+	var kafkaErr kafka.Error
+	if errors.As(err, &kafkaErr) {
+		switch kafkaErr {
+		case kafka.RebalanceInProgress, kafka.IllegalGeneration, kafka.UnknownMemberId:
+			// The group moved on; the reader rejoins on the next fetch.
+			return true
+		}
+		return kafkaErr.Temporary()
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr)
+}
+
+// Backoff computes jittered exponential delays: Initial doubled per
+// attempt, up to Max. Each delay is between half and all of that, so
+// consumers that failed together do not retry together.
+type Backoff struct {
+	Initial time.Duration
+	Max     time.Duration
+}
+
+// DefaultBackoff is used when a Backoff field is zero.
+var DefaultBackoff = Backoff{Initial: 100 * time.Millisecond, Max: 30 * time.Second}
+
+// Delay returns the delay before retry attempt, counted from 0.
+func (b Backoff) Delay(attempt int) time.Duration {
+	if b.Initial <= 0 {
+		b.Initial = DefaultBackoff.Initial
+	}
+	if b.Max <= 0 {
+		b.Max = DefaultBackoff.Max
+	}
+
+	d := b.Max
+	if attempt < 32 {
+		if shifted := b.Initial << attempt; shifted > 0 && shifted < b.Max {
+			d = shifted
+		}
+	}
+	return d/2 + rand.N(d/2+1)
+}
+
+// WithRetry sets how ConsumeLogEventsToFiles retries transient read and
+// commit errors; other errors are returned. onRetry, if not nil, is called
+// with each error before waiting. Without this option, DefaultBackoff is
+// used.
+func WithRetry(backoff Backoff, onRetry func(err error, delay time.Duration)) ConsumeOption {
+	return func(o *consumeOptions) {
+		o.backoff = backoff
+		o.onRetry = onRetry
+	}
+}
+
+// retry calls op until it succeeds, fails with an error that is not
+// transient, or ctx is done.
+func (o consumeOptions) retry(ctx context.Context, op func() error) error {
+	for attempt := 0; ; attempt++ {
+		err := op()
+		if err == nil || ctx.Err() != nil || !IsTransient(err) {
+			return err
+		}
+		delay := o.backoff.Delay(attempt)
+		if o.onRetry != nil {
+			o.onRetry(err, delay)
+		}
+		if !sleep(ctx, delay) {
+			return ctx.Err()
+		}
+	}
+}
+
+// sleep waits for d and reports whether it did so before ctx was done.
+func sleep(ctx context.Context, d time.Duration) bool {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
@@ -0,0 +1,115 @@
+package consumer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"kafka-logger/mocks"
+	"net"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+)
+
+func TestIsTransient(t *testing.T) {
+	tests := []struct {
+		err       error
+		transient bool
+	}{
+		{kafka.LeaderNotAvailable, true},
+		{fmt.Errorf("fetch: %w", kafka.NotLeaderForPartition), true},
+		{kafka.RebalanceInProgress, true},
+		{kafka.TopicAuthorizationFailed, false},
+		{&net.OpError{Op: "dial", Net: "tcp", Err: syscall.ECONNREFUSED}, true},
+		{syscall.ECONNRESET, true},
+		{io.ErrUnexpectedEOF, true},
+		{io.EOF, false},
+		{context.Canceled, false},
+		{errors.New("failed to write log: disk full"), false},
+		{nil, false},
+	}
+	for _, tt := range tests {
+		if got := IsTransient(tt.err); got != tt.transient {
+			t.Errorf("IsTransient(%v) = %v, expected %v", tt.err, got, tt.transient)
+		}
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	b := Backoff{Initial: 10 * time.Millisecond, Max: 100 * time.Millisecond}
+	for attempt, ceiling := range []time.Duration{10, 20, 40, 80, 100, 100} {
+		ceiling *= time.Millisecond
+		for range 20 {
+			if d := b.Delay(attempt); d < ceiling/2 || d > ceiling {
+				t.Errorf("Delay(%d) = %v, expected between %v and %v", attempt, d, ceiling/2, ceiling)
+			}
+		}
+	}
+	if d := (Backoff{}).Delay(1000); d > DefaultBackoff.Max {
+		t.Errorf("Expected the default maximum, got %v", d)
+	}
+}
+
+// flakyReader fails its first fetches and commits with transient errors.
+type flakyReader struct {
+	*mocks.MockMessageReader
+	fetchFailures  int
+	commitFailures int
+}
+
+func (r *flakyReader) FetchMessage(ctx context.Context) (kafka.Message, error) {
+	if r.fetchFailures > 0 {
+		r.fetchFailures--
+		return kafka.Message{}, kafka.LeaderNotAvailable
+	}
+	return r.MockMessageReader.FetchMessage(ctx)
+}
+
+func (r *flakyReader) CommitMessages(ctx context.Context, msgs ...kafka.Message) error {
+	if r.commitFailures > 0 {
+		r.commitFailures--
+		return kafka.RebalanceInProgress
+	}
+	return r.MockMessageReader.CommitMessages(ctx, msgs...)
+}
+
+func TestConsumeRetries(t *testing.T) {
+	t.Parallel()
+
+	t.Run("Transient errors are retried", func(t *testing.T) {
+		t.Parallel()
+		mockReader := &mocks.MockMessageReader{Messages: offsetMessages(t, 2)}
+		reader := &flakyReader{MockMessageReader: mockReader, fetchFailures: 2, commitFailures: 1}
+		mockWriter := mocks.NewMockLogFileWriter()
+
+		var retried []error
+		err := ConsumeLogEventsToFiles(context.Background(), reader, mockWriter,
+			WithRetry(Backoff{Initial: time.Millisecond, Max: time.Millisecond}, func(err error, delay time.Duration) {
+				retried = append(retried, err)
+			}))
+		if err != io.EOF {
+			t.Errorf("Expected EOF, got: %v", err)
+		}
+		if got := len(mockWriter.Logs["INFO"]); got != 2 {
+			t.Errorf("Expected 2 events written, got %d", got)
+		}
+		if len(retried) != 3 || len(mockReader.Committed) != 2 {
+			t.Errorf("Expected 3 retries and 2 commits, got %v and %d", retried, len(mockReader.Committed))
+		}
+	})
+
+	t.Run("Retries stop when the context is done", func(t *testing.T) {
+		t.Parallel()
+		reader := &flakyReader{MockMessageReader: &mocks.MockMessageReader{}, fetchFailures: 1000}
+
+		ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+		defer cancel()
+		err := ConsumeLogEventsToFiles(ctx, reader, mocks.NewMockLogFileWriter(), WithRetry(Backoff{Initial: time.Millisecond}, nil))
+		if err != context.DeadlineExceeded {
+			t.Errorf("Expected the context error, got: %v", err)
+		}
+	})
+}
@@ -0,0 +1,136 @@
+package consumer
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"sync"
+	"time"
+)
+
+// errConsumerStopped stands in for the error of a consumer that returned
+// nil before its context was done.
+var errConsumerStopped = errors.New("consumer stopped")
+
+// SupervisorConfig configures a Supervisor.
+type SupervisorConfig struct {
+	// Backoff sets the delay before a failed consumer is restarted and
+	// between retries of transient errors. Consumers that ran for longer
+	// than Backoff.Max before failing restart at the initial delay.
+	Backoff Backoff
+	// OnRestart, if not nil, is called before each restart with the error
+	// the consumer returned.
+	OnRestart func(id int, err error, delay time.Duration)
+	// OnRetry, if not nil, is called before each retry of a transient
+	// error by a consumer using RetryOption.
+	OnRetry func(id int, err error, delay time.Duration)
+}
+
+// ConsumerStatus describes one supervised consumer.
+type ConsumerStatus struct {
+	ID      int
+	Running bool
+	// Restarts counts the times the consumer was restarted after returning
+	// an error; Retries counts transient errors retried without a restart.
+	Restarts int
+	Retries  int
+	// LastError is the latest error, restarted or retried, and LastErrorAt
+	// when it happened.
+	LastError   error
+	LastErrorAt time.Time
+}
+
+// Supervisor runs consumers and restarts them when they fail, so a consumer
+// goroutine is never lost to an error.
+type Supervisor struct {
+	cfg SupervisorConfig
+
+	mu       sync.Mutex
+	statuses map[int]*ConsumerStatus
+}
+
+func NewSupervisor(cfg SupervisorConfig) *Supervisor {
+	return &Supervisor{cfg: cfg, statuses: make(map[int]*ConsumerStatus)}
+}
+
+// Run calls consume until ctx is done, restarting it with backoff whenever
+// it returns. consume should create its own reader, so a restart starts
+// from a fresh connection.
+func (s *Supervisor) Run(ctx context.Context, id int, consume func(ctx context.Context) error) {
+	for attempt := 0; ; attempt++ {
+		s.update(id, func(status *ConsumerStatus) { status.Running = true })
+		started := time.Now()
+		err := consume(ctx)
+		s.update(id, func(status *ConsumerStatus) { status.Running = false })
+		if ctx.Err() != nil {
+			return
+		}
+
+		if err == nil {
+			err = errConsumerStopped
+		}
+		if time.Since(started) > s.backoffMax() {
+			attempt = 0
+		}
+		delay := s.cfg.Backoff.Delay(attempt)
+		s.update(id, func(status *ConsumerStatus) {
+			status.Restarts++
+			status.LastError = err
+			status.LastErrorAt = time.Now()
+		})
+		if s.cfg.OnRestart != nil {
+			s.cfg.OnRestart(id, err, delay)
+		}
+		if !sleep(ctx, delay) {
+			return
+		}
+	}
+}
+
+func (s *Supervisor) backoffMax() time.Duration {
+	if s.cfg.Backoff.Max > 0 {
+		return s.cfg.Backoff.Max
+	}
+	return DefaultBackoff.Max
+}
+
+// RetryOption returns the WithRetry option for consumer id, which uses the
+// supervisor's backoff and records retried errors in the consumer's status.
+func (s *Supervisor) RetryOption(id int) ConsumeOption {
+	return WithRetry(s.cfg.Backoff, func(err error, delay time.Duration) {
+		s.update(id, func(status *ConsumerStatus) {
+			status.Retries++
+			status.LastError = err
+			status.LastErrorAt = time.Now()
+		})
+		if s.cfg.OnRetry != nil {
+			s.cfg.OnRetry(id, err, delay)
+		}
+	})
+}
+
+// Statuses returns the status of every consumer run so far, by ID.
+func (s *Supervisor) Statuses() []ConsumerStatus {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	statuses := make([]ConsumerStatus, 0, len(s.statuses))
+	for _, status := range s.statuses {
+		statuses = append(statuses, *status)
+	}
+	sort.Slice(statuses, func(i, j int) bool {
+		return statuses[i].ID < statuses[j].ID
+	})
+	return statuses
+}
+
+func (s *Supervisor) update(id int, change func(*ConsumerStatus)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	status := s.statuses[id]
+	if status == nil {
+		status = &ConsumerStatus{ID: id}
+		s.statuses[id] = status
+	}
+	change(status)
+}
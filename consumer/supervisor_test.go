@@ -0,0 +1,90 @@
+package consumer
+
+import (
+	"context"
+	"errors"
+	"kafka-logger/mocks"
+	"testing"
+	"time"
+)
+
+func TestSupervisor(t *testing.T) {
+	t.Parallel()
+
+	t.Run("Restarts failed consumers", func(t *testing.T) {
+		t.Parallel()
+		var restarts []error
+		supervisor := NewSupervisor(SupervisorConfig{
+			Backoff: Backoff{Initial: time.Millisecond, Max: time.Millisecond},
+			OnRestart: func(id int, err error, delay time.Duration) {
+				restarts = append(restarts, err)
+			},
+		})
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		runs := 0
+		supervisor.Run(ctx, 7, func(ctx context.Context) error {
+			runs++
+			switch runs {
+			case 1:
+				return errors.New("failed to write log: disk full")
+			case 2:
+				return nil
+			default:
+				cancel()
+				return ctx.Err()
+			}
+		})
+
+		if runs != 3 || len(restarts) != 2 || restarts[1] != errConsumerStopped {
+			t.Errorf("Expected 3 runs and 2 restarts, got %d and %v", runs, restarts)
+		}
+		statuses := supervisor.Statuses()
+		if len(statuses) != 1 {
+			t.Fatalf("Expected one status, got %+v", statuses)
+		}
+		status := statuses[0]
+		if status.ID != 7 || status.Running || status.Restarts != 2 || status.LastError != errConsumerStopped || status.LastErrorAt.IsZero() {
+			t.Errorf("Unexpected status %+v", status)
+		}
+	})
+
+	t.Run("Stops when the context is done", func(t *testing.T) {
+		t.Parallel()
+		supervisor := NewSupervisor(SupervisorConfig{Backoff: Backoff{Initial: time.Hour}})
+
+		ctx, cancel := context.WithCancel(context.Background())
+		done := make(chan struct{})
+		go func() {
+			supervisor.Run(ctx, 0, func(ctx context.Context) error {
+				return errors.New("boom")
+			})
+			close(done)
+		}()
+		time.Sleep(5 * time.Millisecond)
+		cancel()
+
+		select {
+		case <-done:
+		case <-time.After(time.Second):
+			t.Fatal("Expected Run to return during the restart delay")
+		}
+		if got := supervisor.Statuses()[0].Restarts; got != 1 {
+			t.Errorf("Expected 1 restart, got %d", got)
+		}
+	})
+
+	t.Run("Retries are recorded", func(t *testing.T) {
+		t.Parallel()
+		supervisor := NewSupervisor(SupervisorConfig{Backoff: Backoff{Initial: time.Millisecond, Max: time.Millisecond}})
+		reader := &flakyReader{MockMessageReader: &mocks.MockMessageReader{Messages: offsetMessages(t, 1)}, fetchFailures: 2}
+
+		ConsumeLogEventsToFiles(context.Background(), reader, mocks.NewMockLogFileWriter(), supervisor.RetryOption(3))
+
+		status := supervisor.Statuses()[0]
+		if status.ID != 3 || status.Retries != 2 || status.Restarts != 0 || status.LastError == nil {
+			t.Errorf("Unexpected status %+v", status)
+		}
+	})
+}
@@ -0,0 +1,248 @@
+package consumer
+
+import (
+	"fmt"
+	"kafka-logger/service"
+	"slices"
+	"sync"
+	"time"
+)
+
+const (
+	defaultDedupWindow = 10000
+	defaultIdleTimeout = time.Hour
+)
+
+// TrackerConfig configures duplicate and gap detection.
+type TrackerConfig struct {
+	// DedupWindow is the number of recent event IDs remembered. A redelivered
+	// event is only recognized while its ID is in the window. Defaults to
+	// 10000.
+	DedupWindow int
+	// ReorderWindow is how far, in sequence numbers, a producer may move past
+	// missing events before they are reported. A producer's events are not
+	// guaranteed to arrive in order: sequence numbers are assigned before
+	// concurrent writes, and balancers such as least-bytes spread one
+	// producer's events over partitions regardless of the key. Set this to
+	// at least the number of events that can be in flight at once. Zero
+	// reports gaps at once, which only suits a single partition written
+	// from one goroutine.
+	ReorderWindow uint64
+	// IdleTimeout is how long a producer is remembered after its last event.
+	// Gaps still within the reorder window are reported when it is forgotten.
+	// Defaults to an hour.
+	IdleTimeout time.Duration
+}
+
+// Gap is a range of sequence numbers, From to To inclusive, that a producer
+// published but the consumer never saw.
+type Gap struct {
+	ProducerID string
+	Service    string
+	From, To   uint64
+}
+
+// Missing returns the number of events in the gap.
+func (g Gap) Missing() uint64 {
+	return g.To - g.From + 1
+}
+
+// TrackerStats are running totals kept by a Tracker.
+type TrackerStats struct {
+	// Duplicates is the number of redelivered events that were dropped.
+	Duplicates uint64
+	// Gaps and Missing count the reported gaps and the events in them.
+	Gaps    uint64
+	Missing uint64
+	// Late is the number of events that arrived after their gap had been
+	// reported.
+	Late uint64
+}
+
+// Tracker drops redelivered events by event ID and detects events lost
+// between a producer and the consumer from the per-producer sequence
+// numbers. One Tracker can be shared by consumers reading different
+// partitions of a topic, and should be, since a producer's events may be
+// spread over them.
+type Tracker struct {
+	cfg TrackerConfig
+	now func() time.Time
+
+	mu        sync.Mutex
+	seen      map[string]struct{}
+	recent    []string
+	next      int
+	producers map[string]*producerState
+	lastPrune time.Time
+	stats     TrackerStats
+}
+
+type producerState struct {
+	service  string
+	highest  uint64
+	lastSeen time.Time
+	// pending holds missing ranges, in order, that are still within the
+	// reorder window.
+	pending []Gap
+}
+
+func NewTracker(cfg TrackerConfig) *Tracker {
+	if cfg.DedupWindow <= 0 {
+		cfg.DedupWindow = defaultDedupWindow
+	}
+	if cfg.IdleTimeout <= 0 {
+		cfg.IdleTimeout = defaultIdleTimeout
+	}
+	return &Tracker{
+		cfg:       cfg,
+		now:       time.Now,
+		seen:      make(map[string]struct{}, cfg.DedupWindow),
+		recent:    make([]string, cfg.DedupWindow),
+		producers: make(map[string]*producerState),
+	}
+}
+
+// WithTracker drops duplicate events and writes a WARN event for every gap
+// the tracker reports.
+func WithTracker(tracker *Tracker) ConsumeOption {
+	return func(o *consumeOptions) {
+		o.tracker = tracker
+	}
+}
+
+// Observe records event. duplicate is true if an event with the same ID was
+// seen recently; the caller should skip it. gaps are the missing ranges that
+// are due to be reported. Events without an ID or producer, as written
+// before these were introduced, are passed through.
+func (t *Tracker) Observe(event service.LogEvent) (duplicate bool, gaps []Gap) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if event.ID != "" {
+		if _, ok := t.seen[event.ID]; ok {
+			t.stats.Duplicates++
+			return true, nil
+		}
+		t.remember(event.ID)
+	}
+
+	now := t.now()
+	if event.ProducerID != "" && event.Sequence > 0 {
+		state, ok := t.producers[event.ProducerID]
+		if !ok {
+			// The consumer may have started in the middle of the producer's
+			// output, so the first event seen sets the baseline.
+			state = &producerState{service: event.Service, highest: event.Sequence}
+			t.producers[event.ProducerID] = state
+		} else {
+			t.sequence(event.ProducerID, state, event.Sequence)
+		}
+		state.lastSeen = now
+		gaps = t.due(event.ProducerID, state, false)
+	}
+
+	if now.Sub(t.lastPrune) >= t.cfg.IdleTimeout {
+		t.lastPrune = now
+		for id, state := range t.producers {
+			if now.Sub(state.lastSeen) >= t.cfg.IdleTimeout {
+				gaps = append(gaps, t.due(id, state, true)...)
+				delete(t.producers, id)
+			}
+		}
+	}
+	return false, gaps
+}
+
+// Stats returns a snapshot of the tracker's counters.
+func (t *Tracker) Stats() TrackerStats {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.stats
+}
+
+func (t *Tracker) remember(id string) {
+	if evicted := t.recent[t.next]; evicted != "" {
+		delete(t.seen, evicted)
+	}
+	t.recent[t.next] = id
+	t.seen[id] = struct{}{}
+	t.next = (t.next + 1) % len(t.recent)
+}
+
+func (t *Tracker) sequence(producerID string, state *producerState, seq uint64) {
+	if seq > state.highest {
+		if seq > state.highest+1 {
+			state.pending = append(state.pending, Gap{
+				ProducerID: producerID,
+				Service:    state.service,
+				From:       state.highest + 1,
+				To:         seq - 1,
+			})
+		}
+		state.highest = seq
+		return
+	}
+
+	// An event from before the highest sequence seen either fills a pending
+	// gap or arrives after its gap was reported.
+	for i, gap := range state.pending {
+		if seq < gap.From || seq > gap.To {
+			continue
+		}
+		var split []Gap
+		if seq > gap.From {
+			split = append(split, Gap{ProducerID: producerID, Service: gap.Service, From: gap.From, To: seq - 1})
+		}
+		if seq < gap.To {
+			split = append(split, Gap{ProducerID: producerID, Service: gap.Service, From: seq + 1, To: gap.To})
+		}
+		state.pending = slices.Replace(state.pending, i, i+1, split...)
+		return
+	}
+	t.stats.Late++
+}
+
+// due removes and returns the pending gaps the producer has moved far enough
+// past, or all of them if flush is set.
+func (t *Tracker) due(producerID string, state *producerState, flush bool) []Gap {
+	var gaps []Gap
+	for len(state.pending) > 0 {
+		gap := state.pending[0]
+		if !flush && state.highest-gap.To <= t.cfg.ReorderWindow {
+			break
+		}
+		state.pending = state.pending[1:]
+		t.stats.Gaps++
+		t.stats.Missing += gap.Missing()
+		gaps = append(gaps, gap)
+	}
+	return gaps
+}
+
+// gapEvent is the warning written to the consumer's output for a gap.
+func gapEvent(gap Gap) service.LogEvent {
+	return service.LogEvent{
+		Timestamp: time.Now().UTC(),
+		Level:     service.WARN,
+		Message:   fmt.Sprintf("%d log events missing", gap.Missing()),
+		Service:   gap.Service,
+		Fields: map[string]any{
+			"producer_id":   gap.ProducerID,
+			"sequence_from": gap.From,
+			"sequence_to":   gap.To,
+		},
+	}
+}
+
+// track runs event through the tracker, if any. It reports whether the event
+// should be skipped as a duplicate and returns warning events for new gaps.
+func (o consumeOptions) track(event service.LogEvent) (duplicate bool, warnings []service.LogEvent) {
+	if o.tracker == nil {
+		return false, nil
+	}
+	duplicate, gaps := o.tracker.Observe(event)
+	for _, gap := range gaps {
+		warnings = append(warnings, gapEvent(gap))
+	}
+	return duplicate, warnings
+}
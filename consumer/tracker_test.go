@@ -0,0 +1,162 @@
+package consumer
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"kafka-logger/mocks"
+	"kafka-logger/service"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+)
+
+func sequenced(producerID string, seq uint64) service.LogEvent {
+	return service.LogEvent{
+		Level:      service.INFO,
+		Service:    "api",
+		Message:    "event",
+		ID:         producerID + "-" + strings.Repeat("x", int(seq)),
+		ProducerID: producerID,
+		Sequence:   seq,
+	}
+}
+
+func observeAll(t *testing.T, tracker *Tracker, events ...service.LogEvent) []Gap {
+	t.Helper()
+	var gaps []Gap
+	for _, event := range events {
+		duplicate, reported := tracker.Observe(event)
+		if duplicate {
+			t.Fatalf("Unexpected duplicate %s", event.ID)
+		}
+		gaps = append(gaps, reported...)
+	}
+	return gaps
+}
+
+func TestTracker(t *testing.T) {
+	t.Run("Duplicates within the window", func(t *testing.T) {
+		tracker := NewTracker(TrackerConfig{DedupWindow: 2})
+		first := sequenced("p", 1)
+
+		observeAll(t, tracker, first)
+		if duplicate, _ := tracker.Observe(first); !duplicate {
+			t.Error("Expected a redelivered event to be a duplicate")
+		}
+
+		observeAll(t, tracker, sequenced("p", 2), sequenced("p", 3))
+		if duplicate, _ := tracker.Observe(first); duplicate {
+			t.Error("Expected the ID to have left the window")
+		}
+		if stats := tracker.Stats(); stats.Duplicates != 1 {
+			t.Errorf("Expected 1 duplicate, got %+v", stats)
+		}
+	})
+
+	t.Run("Gaps are reported per producer", func(t *testing.T) {
+		tracker := NewTracker(TrackerConfig{})
+
+		gaps := observeAll(t, tracker,
+			sequenced("a", 5), sequenced("b", 1), sequenced("a", 6),
+			sequenced("a", 9), sequenced("b", 2), sequenced("a", 10))
+		if len(gaps) != 1 || gaps[0] != (Gap{ProducerID: "a", Service: "api", From: 7, To: 8}) {
+			t.Fatalf("Expected a gap of 7-8 from a, got %+v", gaps)
+		}
+
+		observeAll(t, tracker, sequenced("a", 7))
+		if stats := tracker.Stats(); stats != (TrackerStats{Gaps: 1, Missing: 2, Late: 1}) {
+			t.Errorf("Unexpected stats %+v", stats)
+		}
+	})
+
+	t.Run("Reordered events within the window fill the gap", func(t *testing.T) {
+		tracker := NewTracker(TrackerConfig{ReorderWindow: 3})
+
+		gaps := observeAll(t, tracker, sequenced("p", 1), sequenced("p", 4), sequenced("p", 3), sequenced("p", 5))
+		if len(gaps) != 0 {
+			t.Fatalf("Expected nothing reported yet, got %+v", gaps)
+		}
+
+		gaps = observeAll(t, tracker, sequenced("p", 6))
+		if len(gaps) != 1 || gaps[0].From != 2 || gaps[0].To != 2 {
+			t.Fatalf("Expected only 2 to be missing, got %+v", gaps)
+		}
+		gaps = observeAll(t, tracker, sequenced("p", 10), sequenced("p", 8))
+		if len(gaps) != 0 {
+			t.Errorf("Expected 7 and 9 to wait for the reorder window, got %+v", gaps)
+		}
+	})
+
+	t.Run("Idle producers are forgotten with their pending gaps", func(t *testing.T) {
+		now := time.Date(2024, 1, 15, 10, 0, 0, 0, time.UTC)
+		tracker := NewTracker(TrackerConfig{ReorderWindow: 100, IdleTimeout: time.Minute})
+		tracker.now = func() time.Time { return now }
+
+		if gaps := observeAll(t, tracker, sequenced("quiet", 1), sequenced("quiet", 4)); len(gaps) != 0 {
+			t.Fatalf("Expected the gap to be pending, got %+v", gaps)
+		}
+
+		now = now.Add(2 * time.Minute)
+		gaps := observeAll(t, tracker, sequenced("busy", 1))
+		if len(gaps) != 1 || gaps[0].ProducerID != "quiet" || gaps[0].Missing() != 2 {
+			t.Fatalf("Expected the pending gap to be reported, got %+v", gaps)
+		}
+
+		// The producer starts over from a new baseline.
+		if gaps := observeAll(t, tracker, sequenced("quiet", 10)); len(gaps) != 0 {
+			t.Errorf("Expected no gap after the producer was forgotten, got %+v", gaps)
+		}
+	})
+
+	t.Run("Events without sequence numbers", func(t *testing.T) {
+		tracker := NewTracker(TrackerConfig{})
+		legacy := service.LogEvent{Level: service.INFO, Message: "legacy"}
+		observeAll(t, tracker, legacy, legacy)
+		if stats := tracker.Stats(); stats != (TrackerStats{}) {
+			t.Errorf("Expected legacy events to be passed through, got %+v", stats)
+		}
+	})
+}
+
+func TestConsumeWithTracker(t *testing.T) {
+	t.Parallel()
+
+	var messages []kafka.Message
+	for _, seq := range []uint64{1, 2, 2, 5} {
+		value, err := json.Marshal(sequenced("p", seq))
+		if err != nil {
+			t.Fatal(err)
+		}
+		messages = append(messages, kafka.Message{Value: value})
+	}
+	mockReader := &mocks.MockMessageReader{Messages: messages}
+	mockWriter := mocks.NewMockLogFileWriter()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	tracker := NewTracker(TrackerConfig{})
+	err := ConsumeLogEventsToFiles(ctx, mockReader, mockWriter, WithTracker(tracker))
+	if err != io.EOF {
+		t.Errorf("Expected EOF, got: %v", err)
+	}
+
+	if got := len(mockWriter.Logs[string(service.INFO)]); got != 3 {
+		t.Errorf("Expected the duplicate to be dropped, got %d INFO entries", got)
+	}
+	warnings := mockWriter.Logs[string(service.WARN)]
+	if len(warnings) != 1 {
+		t.Fatalf("Expected 1 gap warning, got %v", warnings)
+	}
+	for _, want := range []string{"2 log events missing", "producer_id=p", "sequence_from=3", "sequence_to=4"} {
+		if !strings.Contains(warnings[0], want) {
+			t.Errorf("Expected %q in the warning, got %s", want, warnings[0])
+		}
+	}
+	if stats := tracker.Stats(); stats.Duplicates != 1 || stats.Missing != 2 {
+		t.Errorf("Unexpected stats %+v", stats)
+	}
+}
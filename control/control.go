@@ -0,0 +1,182 @@
+package control
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"kafka-logger/consumer"
+	"kafka-logger/producer"
+	"kafka-logger/service"
+	"log"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+)
+
+const keySeparator = "/"
+
+// LevelOverride is the value stored on the control topic. The topic is
+// compacted on its key, so the latest override per service or instance wins.
+type LevelOverride struct {
+	Service   string           `json:"service"`
+	Instance  string           `json:"instance,omitempty"`
+	Level     service.LogLevel `json:"level"`
+	UpdatedAt time.Time        `json:"updated_at"`
+}
+
+// OverrideKey returns the control topic key for a service-wide override, or
+// for a single instance when instance is not empty.
+func OverrideKey(serviceName, instance string) string {
+	if instance == "" {
+		return serviceName
+	}
+	return serviceName + keySeparator + instance
+}
+
+func PublishOverride(ctx context.Context, writer producer.MessageWriter, override LevelOverride) error {
+	if override.Service == "" {
+		return fmt.Errorf("level override needs a service")
+	}
+	if _, err := service.ParseLogLevel(string(override.Level)); err != nil {
+		return err
+	}
+	if override.UpdatedAt.IsZero() {
+		override.UpdatedAt = time.Now().UTC()
+	}
+
+	data, err := json.Marshal(override)
+	if err != nil {
+		return err
+	}
+
+	return writer.WriteMessages(ctx, kafka.Message{
+		Key:   []byte(OverrideKey(override.Service, override.Instance)),
+		Value: data,
+	})
+}
+
+// ClearOverride writes a tombstone so the service or instance falls back to
+// its configured level and compaction eventually removes the key.
+func ClearOverride(ctx context.Context, writer producer.MessageWriter, serviceName, instance string) error {
+	return writer.WriteMessages(ctx, kafka.Message{
+		Key: []byte(OverrideKey(serviceName, instance)),
+	})
+}
+
+// Subscriber applies overrides for one service instance to a LevelVar. An
+// instance override takes precedence over a service override, which takes
+// precedence over the configured level: whatever the LevelVar was last set
+// to other than by the subscriber.
+type Subscriber struct {
+	service  string
+	instance string
+	level    *service.LevelVar
+
+	mutex         sync.Mutex
+	defaultLevel  service.LogLevel
+	serviceLevel  service.LogLevel
+	instanceLevel service.LogLevel
+	// applied is the override level last set, or empty while none is in
+	// effect.
+	applied service.LogLevel
+}
+
+func NewSubscriber(serviceName, instance string, level *service.LevelVar) *Subscriber {
+	return &Subscriber{
+		service:  serviceName,
+		instance: instance,
+		level:    level,
+	}
+}
+
+// Subscribe starts applying overrides from the control topic to logger until
+// ctx is done. instance defaults to the hostname. Loggers do not subscribe
+// on their own; call Subscribe for each root logger that should follow the
+// control topic.
+func Subscribe(ctx context.Context, brokers []string, topic string, logger *service.KafkaLogger, instance string) {
+	if instance == "" {
+		instance, _ = os.Hostname()
+	}
+
+	subscriber := NewSubscriber(logger.Service(), instance, logger.LevelVar())
+	reader := consumer.NewPartitionConsumer(brokers, topic, 0)
+
+	go func() {
+		defer reader.Close()
+		if err := subscriber.Run(ctx, reader); err != nil && ctx.Err() == nil {
+			log.Printf("Control topic subscription stopped: %v", err)
+		}
+	}()
+}
+
+func (s *Subscriber) Run(ctx context.Context, reader consumer.MessageReader) error {
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		default:
+			message, err := reader.ReadMessage(ctx)
+			if err != nil {
+				return err
+			}
+			if err := s.Apply(message); err != nil {
+				log.Printf("Ignoring control message %q: %v", string(message.Key), err)
+			}
+		}
+	}
+}
+
+// Apply handles one control topic message. Messages for other services or
+// instances are ignored.
+func (s *Subscriber) Apply(message kafka.Message) error {
+	serviceName, instance, _ := strings.Cut(string(message.Key), keySeparator)
+	if serviceName != s.service || (instance != "" && instance != s.instance) {
+		return nil
+	}
+
+	var level service.LogLevel
+	if len(message.Value) > 0 {
+		var override LevelOverride
+		if err := json.Unmarshal(message.Value, &override); err != nil {
+			return fmt.Errorf("failed to parse level override: %w", err)
+		}
+		parsed, err := service.ParseLogLevel(string(override.Level))
+		if err != nil {
+			return err
+		}
+		level = parsed
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	// Without an override in effect, or if the level was set elsewhere since
+	// one was applied, the current level is the configured one.
+	if current := s.level.Level(); s.applied == "" || current != s.applied {
+		s.defaultLevel = current
+	}
+
+	if instance == "" {
+		s.serviceLevel = level
+	} else {
+		s.instanceLevel = level
+	}
+
+	s.applied = s.instanceLevel
+	if s.applied == "" {
+		s.applied = s.serviceLevel
+	}
+	effective := s.applied
+	if effective == "" {
+		effective = s.defaultLevel
+	}
+
+	if s.level.Level() != effective {
+		log.Printf("Log level for %s changed to %s", OverrideKey(s.service, s.instance), effective)
+	}
+	s.level.Set(effective)
+	return nil
+}
@@ -0,0 +1,168 @@
+package control
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"kafka-logger/mocks"
+	"kafka-logger/service"
+	"testing"
+
+	"github.com/segmentio/kafka-go"
+)
+
+func TestOverrideKey(t *testing.T) {
+	if key := OverrideKey("billing", ""); key != "billing" {
+		t.Errorf("Expected key 'billing', got '%s'", key)
+	}
+	if key := OverrideKey("billing", "pod-1"); key != "billing/pod-1" {
+		t.Errorf("Expected key 'billing/pod-1', got '%s'", key)
+	}
+}
+
+func TestPublishOverride(t *testing.T) {
+	t.Run("Success", func(t *testing.T) {
+		writer := &mocks.MockMessageWriter{}
+
+		err := PublishOverride(context.Background(), writer, LevelOverride{
+			Service:  "billing",
+			Instance: "pod-1",
+			Level:    service.WARN,
+		})
+		if err != nil {
+			t.Fatalf("Expected no error, got: %v", err)
+		}
+
+		if len(writer.Messages) != 1 {
+			t.Fatalf("Expected 1 message, got %d", len(writer.Messages))
+		}
+		msg := writer.Messages[0]
+		if string(msg.Key) != "billing/pod-1" {
+			t.Errorf("Expected key 'billing/pod-1', got '%s'", string(msg.Key))
+		}
+
+		var override LevelOverride
+		if err := json.Unmarshal(msg.Value, &override); err != nil {
+			t.Fatalf("Failed to unmarshal override: %v", err)
+		}
+		if override.Level != service.WARN || override.UpdatedAt.IsZero() {
+			t.Errorf("Unexpected override %+v", override)
+		}
+	})
+
+	t.Run("Invalid level", func(t *testing.T) {
+		writer := &mocks.MockMessageWriter{}
+
+		err := PublishOverride(context.Background(), writer, LevelOverride{Service: "billing", Level: "LOUD"})
+		if err == nil {
+			t.Error("Expected error for unknown level")
+		}
+		if len(writer.Messages) != 0 {
+			t.Errorf("Expected nothing to be published, got %d messages", len(writer.Messages))
+		}
+	})
+
+	t.Run("Clear writes tombstone", func(t *testing.T) {
+		writer := &mocks.MockMessageWriter{}
+
+		if err := ClearOverride(context.Background(), writer, "billing", ""); err != nil {
+			t.Fatalf("Expected no error, got: %v", err)
+		}
+		if len(writer.Messages) != 1 || writer.Messages[0].Value != nil {
+			t.Errorf("Expected a single tombstone, got %v", writer.Messages)
+		}
+	})
+}
+
+func TestSubscriber(t *testing.T) {
+	overrideMessage := func(t *testing.T, serviceName, instance string, level service.LogLevel) kafka.Message {
+		t.Helper()
+		writer := &mocks.MockMessageWriter{}
+		err := PublishOverride(context.Background(), writer, LevelOverride{
+			Service:  serviceName,
+			Instance: instance,
+			Level:    level,
+		})
+		if err != nil {
+			t.Fatalf("Failed to build override: %v", err)
+		}
+		return writer.Messages[0]
+	}
+
+	t.Run("Precedence and tombstones", func(t *testing.T) {
+		level := service.NewLevelVar(service.INFO)
+		subscriber := NewSubscriber("billing", "pod-1", level)
+
+		reader := &mocks.MockMessageReader{
+			Messages: []kafka.Message{
+				overrideMessage(t, "billing", "", service.WARN),
+				overrideMessage(t, "billing", "pod-1", service.DEBUG),
+				overrideMessage(t, "billing", "pod-2", service.ERROR),
+				overrideMessage(t, "shipping", "", service.ERROR),
+			},
+		}
+
+		err := subscriber.Run(context.Background(), reader)
+		if err != io.EOF {
+			t.Fatalf("Expected EOF, got: %v", err)
+		}
+		if level.Level() != service.DEBUG {
+			t.Errorf("Expected instance override DEBUG, got %s", level.Level())
+		}
+
+		subscriber.Apply(kafka.Message{Key: []byte("billing/pod-1")})
+		if level.Level() != service.WARN {
+			t.Errorf("Expected service override WARN after instance tombstone, got %s", level.Level())
+		}
+
+		subscriber.Apply(kafka.Message{Key: []byte("billing")})
+		if level.Level() != service.INFO {
+			t.Errorf("Expected configured level INFO after all tombstones, got %s", level.Level())
+		}
+	})
+
+	t.Run("Clearing restores a level set since construction", func(t *testing.T) {
+		level := service.NewLevelVar(service.INFO)
+		subscriber := NewSubscriber("billing", "pod-1", level)
+
+		level.Set(service.WARN)
+		subscriber.Apply(overrideMessage(t, "billing", "", service.DEBUG))
+		subscriber.Apply(kafka.Message{Key: []byte("billing")})
+		if level.Level() != service.WARN {
+			t.Errorf("Expected WARN after the tombstone, got %s", level.Level())
+		}
+
+		subscriber.Apply(overrideMessage(t, "billing", "", service.DEBUG))
+		level.Set(service.ERROR)
+		subscriber.Apply(kafka.Message{Key: []byte("billing")})
+		if level.Level() != service.ERROR {
+			t.Errorf("Expected ERROR set during the override, got %s", level.Level())
+		}
+	})
+
+	t.Run("Invalid message is rejected", func(t *testing.T) {
+		level := service.NewLevelVar(service.INFO)
+		subscriber := NewSubscriber("billing", "pod-1", level)
+
+		err := subscriber.Apply(kafka.Message{Key: []byte("billing"), Value: []byte("not json")})
+		if err == nil {
+			t.Error("Expected error for invalid message")
+		}
+		if level.Level() != service.INFO {
+			t.Errorf("Expected level to stay INFO, got %s", level.Level())
+		}
+	})
+
+	t.Run("Applies to logger", func(t *testing.T) {
+		logger := service.NewKafkaLogger([]string{"localhost:9092"}, "test-topic", "billing",
+			service.WithLevel(service.NewLevelVar(service.DEBUG)))
+		defer logger.Close()
+
+		subscriber := NewSubscriber(logger.Service(), "pod-1", logger.LevelVar())
+		subscriber.Apply(overrideMessage(t, "billing", "", service.ERROR))
+
+		if logger.Enabled(service.WARN) {
+			t.Error("Expected WARN to be disabled after override")
+		}
+	})
+}
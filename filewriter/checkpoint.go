@@ -0,0 +1,213 @@
+package filewriter
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+)
+
+// checkpointSuffix is appended to a log file's name to name its checkpoint.
+const checkpointSuffix = ".checkpoint"
+
+// Position is the Kafka position of the message a line was written for.
+type Position struct {
+	Topic     string `json:"topic"`
+	Partition int    `json:"partition"`
+	Offset    int64  `json:"offset"`
+}
+
+type partitionKey struct {
+	topic     string
+	partition int
+}
+
+// CheckpointWriter is a LogWriter that remembers which Kafka messages it has
+// written, so a consumer can skip messages redelivered after a crash.
+type CheckpointWriter interface {
+	LogWriter
+	// WriteLogAt writes message like WriteLog and records pos as written.
+	WriteLogAt(level, message string, pos Position) error
+	// Written returns the offset of the last message written from the
+	// partition, and false if none was.
+	Written(topic string, partition int) (offset int64, ok bool, err error)
+}
+
+// checkpoint is stored next to a log file. Size is the length of the file
+// when the offsets were recorded, so anything past it was written after the
+// checkpoint and is cut off on recovery.
+type checkpoint struct {
+	Size    int64      `json:"size"`
+	Offsets []Position `json:"offsets"`
+}
+
+// WriteLogAt writes message and then atomically replaces the file's
+// checkpoint with the new file size and pos. After a crash, recovery
+// truncates the file to the size in its checkpoint, so a line is kept if
+// and only if its position was recorded: redelivered messages up to the
+// recorded offset are skipped and the rest are written again, and no line
+// is lost or duplicated.
+func (lfw *LogFileWriter) WriteLogAt(level, message string, pos Position) error {
+	return lfw.write(level, message, &pos)
+}
+
+// Written returns the offset of the last message from the partition
+// written to any file under the base path, including files written before
+// a restart.
+func (lfw *LogFileWriter) Written(topic string, partition int) (int64, bool, error) {
+	if err := lfw.recover(); err != nil {
+		return 0, false, err
+	}
+	lfw.writtenMutex.Lock()
+	defer lfw.writtenMutex.Unlock()
+	offset, ok := lfw.written[partitionKey{topic, partition}]
+	return offset, ok, nil
+}
+
+func (lfw *LogFileWriter) recordWritten(pos Position) {
+	lfw.writtenMutex.Lock()
+	defer lfw.writtenMutex.Unlock()
+	key := partitionKey{pos.Topic, pos.Partition}
+	if last, ok := lfw.written[key]; !ok || pos.Offset > last {
+		lfw.written[key] = pos.Offset
+	}
+}
+
+// recover repairs the log files left by a previous run, once, before
+// anything is written: files with a checkpoint are truncated to its size,
+// and other files lose a partially written trailing line.
+func (lfw *LogFileWriter) recover() error {
+	lfw.recoverOnce.Do(func() {
+		lfw.recoverErr = lfw.recoverFiles()
+	})
+	return lfw.recoverErr
+}
+
+func (lfw *LogFileWriter) recoverFiles() error {
+	names, err := filepath.Glob(filepath.Join(lfw.basePath, "*.log"))
+	if err != nil {
+		return err
+	}
+	for _, name := range names {
+		cp, found, err := readCheckpoint(name)
+		if err != nil {
+			return err
+		}
+		if !found {
+			if err := truncatePartialLine(name); err != nil {
+				return err
+			}
+			continue
+		}
+
+		info, err := os.Stat(name)
+		if err != nil {
+			return fmt.Errorf("failed to recover log file %s: %w", name, err)
+		}
+		switch {
+		case info.Size() > cp.Size:
+			if err := os.Truncate(name, cp.Size); err != nil {
+				return fmt.Errorf("failed to recover log file %s: %w", name, err)
+			}
+		case info.Size() < cp.Size:
+			log.Printf("Log file %s is shorter than its checkpoint; lines may be missing", name)
+		}
+		for _, pos := range cp.Offsets {
+			lfw.recordWritten(pos)
+		}
+	}
+	return nil
+}
+
+// truncatePartialLine cuts a file back to its last newline.
+func truncatePartialLine(name string) error {
+	file, err := os.OpenFile(name, os.O_RDWR, 0)
+	if err != nil {
+		return fmt.Errorf("failed to recover log file %s: %w", name, err)
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return fmt.Errorf("failed to recover log file %s: %w", name, err)
+	}
+	// Read backwards in blocks until a newline is found.
+	const blockSize = 4096
+	end := info.Size()
+	for end > 0 {
+		start := max(0, end-blockSize)
+		block := make([]byte, end-start)
+		if _, err := file.ReadAt(block, start); err != nil && err != io.EOF {
+			return fmt.Errorf("failed to recover log file %s: %w", name, err)
+		}
+		if i := bytes.LastIndexByte(block, '\n'); i >= 0 {
+			end = start + int64(i) + 1
+			break
+		}
+		end = start
+	}
+	if end == info.Size() {
+		return nil
+	}
+	return file.Truncate(end)
+}
+
+func readCheckpoint(logFile string) (checkpoint, bool, error) {
+	data, err := os.ReadFile(logFile + checkpointSuffix)
+	if errors.Is(err, os.ErrNotExist) {
+		return checkpoint{}, false, nil
+	}
+	if err != nil {
+		return checkpoint{}, false, fmt.Errorf("failed to read checkpoint for %s: %w", logFile, err)
+	}
+	var cp checkpoint
+	if err := json.Unmarshal(data, &cp); err != nil {
+		return checkpoint{}, false, fmt.Errorf("failed to parse checkpoint for %s: %w", logFile, err)
+	}
+	return cp, true, nil
+}
+
+// writeCheckpoint replaces the checkpoint of logFile. The new checkpoint is
+// synced to a temporary file and renamed over the old one, and the rename
+// is synced before returning, so a crash leaves either checkpoint intact
+// and a committed offset is never ahead of the checkpoint.
+func writeCheckpoint(logFile string, cp checkpoint) error {
+	data, err := json.Marshal(cp)
+	if err != nil {
+		return err
+	}
+
+	name := logFile + checkpointSuffix
+	tmp := name + ".tmp"
+	file, err := os.OpenFile(tmp, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to write checkpoint %s: %w", name, err)
+	}
+	_, err = file.Write(data)
+	if err == nil {
+		err = file.Sync()
+	}
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return fmt.Errorf("failed to write checkpoint %s: %w", name, err)
+	}
+	if err := os.Rename(tmp, name); err != nil {
+		return fmt.Errorf("failed to write checkpoint %s: %w", name, err)
+	}
+	return syncDir(filepath.Dir(name))
+}
+
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+	return d.Sync()
+}
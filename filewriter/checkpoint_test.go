@@ -0,0 +1,121 @@
+package filewriter
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func readLogFile(t *testing.T, dir, level string) string {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join(dir, level+"_"+time.Now().Format(dateFormat)+".log"))
+	if err != nil {
+		t.Fatalf("Failed to read log file: %v", err)
+	}
+	return string(data)
+}
+
+func assertWritten(t *testing.T, writer *LogFileWriter, partition int, expected int64) {
+	t.Helper()
+	offset, ok, err := writer.Written("logs", partition)
+	if err != nil {
+		t.Fatalf("Written failed: %v", err)
+	}
+	if !ok || offset != expected {
+		t.Errorf("Expected offset %d written for partition %d, got %d (%v)", expected, partition, offset, ok)
+	}
+}
+
+func TestCheckpoint(t *testing.T) {
+	t.Run("Records the last offset per partition", func(t *testing.T) {
+		dir := t.TempDir()
+		writer := NewLogFileWriter(dir)
+		defer writer.Close()
+
+		for _, pos := range []Position{{"logs", 0, 7}, {"logs", 1, 3}, {"logs", 0, 8}} {
+			if err := writer.WriteLogAt("INFO", "line", pos); err != nil {
+				t.Fatalf("WriteLogAt failed: %v", err)
+			}
+		}
+		assertWritten(t, writer, 0, 8)
+		assertWritten(t, writer, 1, 3)
+		if _, ok, _ := writer.Written("logs", 2); ok {
+			t.Errorf("Expected nothing written for partition 2")
+		}
+
+		cp, found, err := readCheckpoint(filepath.Join(dir, "INFO_"+time.Now().Format(dateFormat)+".log"))
+		if err != nil || !found {
+			t.Fatalf("Expected a checkpoint, got %v", err)
+		}
+		if cp.Size != int64(len("line\n")*3) || len(cp.Offsets) != 2 {
+			t.Errorf("Unexpected checkpoint %+v", cp)
+		}
+	})
+
+	t.Run("Recovery truncates lines past the checkpoint", func(t *testing.T) {
+		dir := t.TempDir()
+		writer := NewLogFileWriter(dir)
+		writer.WriteLogAt("INFO", "first", Position{"logs", 0, 1})
+		writer.WriteLog("INFO", "warning without an offset")
+		writer.WriteLogAt("INFO", "second", Position{"logs", 0, 2})
+		writer.Close()
+
+		// A crash after writing a line but before its checkpoint, then in
+		// the middle of a line.
+		name := filepath.Join(dir, "INFO_"+time.Now().Format(dateFormat)+".log")
+		file, err := os.OpenFile(name, os.O_APPEND|os.O_WRONLY, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		file.WriteString("third\nfou")
+		file.Close()
+
+		restarted := NewLogFileWriter(dir)
+		defer restarted.Close()
+		assertWritten(t, restarted, 0, 2)
+		if got := readLogFile(t, dir, "INFO"); got != "first\nwarning without an offset\nsecond\n" {
+			t.Errorf("Unexpected file after recovery: %q", got)
+		}
+
+		if err := restarted.WriteLogAt("INFO", "third", Position{"logs", 0, 3}); err != nil {
+			t.Fatalf("WriteLogAt failed: %v", err)
+		}
+		if got := readLogFile(t, dir, "INFO"); got != "first\nwarning without an offset\nsecond\nthird\n" {
+			t.Errorf("Unexpected file after rewriting: %q", got)
+		}
+	})
+
+	t.Run("Recovery drops a partial line from files without a checkpoint", func(t *testing.T) {
+		dir := t.TempDir()
+		name := filepath.Join(dir, "ERROR_"+time.Now().Format(dateFormat)+".log")
+		if err := os.WriteFile(name, []byte("complete\npart"), 0644); err != nil {
+			t.Fatal(err)
+		}
+
+		writer := NewLogFileWriter(dir)
+		defer writer.Close()
+		if err := writer.WriteLog("ERROR", "next"); err != nil {
+			t.Fatalf("WriteLog failed: %v", err)
+		}
+		if got := readLogFile(t, dir, "ERROR"); got != "complete\nnext\n" {
+			t.Errorf("Unexpected file after recovery: %q", got)
+		}
+		if _, err := os.Stat(name + checkpointSuffix); !os.IsNotExist(err) {
+			t.Errorf("Expected no checkpoint for plain writes, got %v", err)
+		}
+	})
+
+	t.Run("Corrupt checkpoint is reported", func(t *testing.T) {
+		dir := t.TempDir()
+		name := filepath.Join(dir, "INFO_"+time.Now().Format(dateFormat)+".log")
+		os.WriteFile(name, []byte("line\n"), 0644)
+		os.WriteFile(name+checkpointSuffix, []byte("{"), 0644)
+
+		writer := NewLogFileWriter(dir)
+		defer writer.Close()
+		if err := writer.WriteLog("INFO", "line"); err == nil {
+			t.Errorf("Expected an error for a corrupt checkpoint")
+		}
+	})
+}
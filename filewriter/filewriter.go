@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"sort"
 	"sync"
 	"time"
 )
@@ -18,22 +19,44 @@ type LogWriter interface {
 type fileInfo struct {
 	file  *os.File
 	mutex sync.Mutex
+
+	// size is the length of the file. Files with a checkpoint have the
+	// checkpoint rewritten after every line, with the latest offset per
+	// partition.
+	size         int64
+	checkpointed bool
+	offsets      map[partitionKey]int64
 }
 
 type LogFileWriter struct {
 	basePath string
 	files    map[string]*fileInfo
 	mapMutex sync.RWMutex
+
+	recoverOnce  sync.Once
+	recoverErr   error
+	written      map[partitionKey]int64
+	writtenMutex sync.Mutex
 }
 
 func NewLogFileWriter(basePath string) *LogFileWriter {
 	return &LogFileWriter{
 		basePath: basePath,
 		files:    make(map[string]*fileInfo),
+		written:  make(map[partitionKey]int64),
 	}
 }
 
 func (lfw *LogFileWriter) WriteLog(level, message string) error {
+	return lfw.write(level, message, nil)
+}
+
+// write appends message to the level's file for today. With a position, or
+// to a file that already has a checkpoint, it also updates the checkpoint.
+func (lfw *LogFileWriter) write(level, message string, pos *Position) error {
+	if err := lfw.recover(); err != nil {
+		return err
+	}
 	filename := lfw.getFilename(level, time.Now())
 
 	lfw.mapMutex.Lock()
@@ -44,7 +67,12 @@ func (lfw *LogFileWriter) WriteLog(level, message string) error {
 			lfw.mapMutex.Unlock()
 			return fmt.Errorf("failed to create log file %s: %w", filename, err)
 		}
-		fileWithMutex = &fileInfo{file: file}
+		fileWithMutex, err = openFileInfo(filename, file)
+		if err != nil {
+			file.Close()
+			lfw.mapMutex.Unlock()
+			return err
+		}
 		lfw.files[filename] = fileWithMutex
 	}
 	lfw.mapMutex.Unlock()
@@ -52,12 +80,88 @@ func (lfw *LogFileWriter) WriteLog(level, message string) error {
 	fileWithMutex.mutex.Lock()
 	defer fileWithMutex.mutex.Unlock()
 
-	_, err := fmt.Fprintf(fileWithMutex.file, "%s\n", message)
+	n, err := fmt.Fprintf(fileWithMutex.file, "%s\n", message)
 	if err != nil {
+		if fileWithMutex.checkpointed {
+			// Cut off the partial line so the next write starts cleanly.
+			fileWithMutex.file.Truncate(fileWithMutex.size)
+		}
 		return fmt.Errorf("failed to write to log file %s: %w", filename, err)
 	}
+	fileWithMutex.size += int64(n)
+
+	if err := fileWithMutex.file.Sync(); err != nil {
+		// The line may not survive a crash, so take it back rather than
+		// leave it for a retried write to repeat.
+		fileWithMutex.size -= int64(n)
+		fileWithMutex.file.Truncate(fileWithMutex.size)
+		return fmt.Errorf("failed to sync log file %s: %w", filename, err)
+	}
+	if pos == nil && !fileWithMutex.checkpointed {
+		return nil
+	}
+
+	fileWithMutex.checkpointed = true
+	if pos != nil {
+		fileWithMutex.offsets[partitionKey{pos.Topic, pos.Partition}] = pos.Offset
+	}
+	if err := writeCheckpoint(filename, fileWithMutex.checkpoint()); err != nil {
+		// Take the line back, so a retried write does not repeat it.
+		fileWithMutex.size -= int64(n)
+		fileWithMutex.file.Truncate(fileWithMutex.size)
+		if pos != nil {
+			fileWithMutex.offsets = checkpointOffsets(filename)
+		}
+		return err
+	}
+	if pos != nil {
+		lfw.recordWritten(*pos)
+	}
+	return nil
+}
+
+// openFileInfo loads the state of a log file that was just opened: its size
+// and, if it has a checkpoint, the offsets recorded in it.
+func openFileInfo(filename string, file *os.File) (*fileInfo, error) {
+	info, err := file.Stat()
+	if err != nil {
+		return nil, fmt.Errorf("failed to open file %s: %w", filename, err)
+	}
+	cp, found, err := readCheckpoint(filename)
+	if err != nil {
+		return nil, err
+	}
 
-	return fileWithMutex.file.Sync()
+	fi := &fileInfo{file: file, size: info.Size(), checkpointed: found, offsets: make(map[partitionKey]int64)}
+	for _, pos := range cp.Offsets {
+		fi.offsets[partitionKey{pos.Topic, pos.Partition}] = pos.Offset
+	}
+	return fi, nil
+}
+
+// checkpointOffsets reloads the offsets from the checkpoint on disk.
+func checkpointOffsets(filename string) map[partitionKey]int64 {
+	cp, _, _ := readCheckpoint(filename)
+	offsets := make(map[partitionKey]int64, len(cp.Offsets))
+	for _, pos := range cp.Offsets {
+		offsets[partitionKey{pos.Topic, pos.Partition}] = pos.Offset
+	}
+	return offsets
+}
+
+func (fi *fileInfo) checkpoint() checkpoint {
+	cp := checkpoint{Size: fi.size}
+	for key, offset := range fi.offsets {
+		cp.Offsets = append(cp.Offsets, Position{Topic: key.topic, Partition: key.partition, Offset: offset})
+	}
+	sort.Slice(cp.Offsets, func(i, j int) bool {
+		a, b := cp.Offsets[i], cp.Offsets[j]
+		if a.Topic != b.Topic {
+			return a.Topic < b.Topic
+		}
+		return a.Partition < b.Partition
+	})
+	return cp
 }
 
 func (lfw *LogFileWriter) getFilename(level string, timestamp time.Time) string {
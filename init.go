@@ -22,7 +22,7 @@ func (d DefaultDialer) DialContext(ctx context.Context, network, address string)
 	return kafka.DialContext(ctx, network, address)
 }
 
-func createTopicWithDialer(dialer ConnDialer, brokers []string, topic string, partitions int) error {
+func createTopicWithDialer(dialer ConnDialer, brokers []string, topic string, partitions int, configEntries ...kafka.ConfigEntry) error {
 	conn, err := dialer.DialContext(context.Background(), "tcp", brokers[0])
 	if err != nil {
 		return err
@@ -33,6 +33,7 @@ func createTopicWithDialer(dialer ConnDialer, brokers []string, topic string, pa
 		Topic:             topic,
 		NumPartitions:     partitions,
 		ReplicationFactor: 1,
+		ConfigEntries:     configEntries,
 	}
 
 	err = conn.CreateTopics(topicConfig)
@@ -53,3 +54,12 @@ func initKafkaTopic(brokers []string, topic string, partitions int) {
 		log.Printf("Warning: Failed to create topic '%s': %v", topic, err)
 	}
 }
+
+// initControlTopic creates the single-partition, compacted topic that holds
+// log level overrides.
+func initControlTopic(brokers []string, topic string) {
+	compact := kafka.ConfigEntry{ConfigName: "cleanup.policy", ConfigValue: "compact"}
+	if err := createTopicWithDialer(DefaultDialer{}, brokers, topic, 1, compact); err != nil {
+		log.Printf("Warning: Failed to create control topic '%s': %v", topic, err)
+	}
+}
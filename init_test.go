@@ -79,6 +79,33 @@ func TestCreateTopic(t *testing.T) {
 		}
 	})
 
+	t.Run("Config entries", func(t *testing.T) {
+		var createdTopic kafka.TopicConfig
+		mockConn := &MockConn{
+			createTopicsFunc: func(topics ...kafka.TopicConfig) error {
+				createdTopic = topics[0]
+				return nil
+			},
+		}
+
+		mockDialer := &MockDialer{
+			dialFunc: func(ctx context.Context, network, address string) (KafkaConn, error) {
+				return mockConn, nil
+			},
+		}
+
+		compact := kafka.ConfigEntry{ConfigName: "cleanup.policy", ConfigValue: "compact"}
+		err := createTopicWithDialer(mockDialer, []string{"localhost:9092"}, "control-topic", 1, compact)
+
+		if err != nil {
+			t.Errorf("Expected no error, got: %v", err)
+		}
+
+		if len(createdTopic.ConfigEntries) != 1 || createdTopic.ConfigEntries[0] != compact {
+			t.Errorf("Expected config entries %v, got %v", compact, createdTopic.ConfigEntries)
+		}
+	})
+
 	t.Run("DialError", func(t *testing.T) {
 		expectedErr := errors.New("dial failed")
 		mockDialer := &MockDialer{
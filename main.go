@@ -4,7 +4,9 @@ import (
 	"context"
 	"kafka-logger/config"
 	"kafka-logger/consumer"
+	"kafka-logger/control"
 	"kafka-logger/filewriter"
+	"kafka-logger/producer"
 	"kafka-logger/service"
 	"log"
 	"os"
@@ -22,11 +24,112 @@ func main() {
 		cfg = config.DefaultConfig()
 	}
 
+	if len(os.Args) > 1 {
+		if err := runCommand(cfg, os.Args[1:], os.Stdout); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
 	initKafkaTopic(cfg.Kafka.Brokers, cfg.Kafka.Topic, cfg.Kafka.Partitions)
 
-	logger := service.NewKafkaLogger(cfg.Kafka.Brokers, cfg.Kafka.Topic, cfg.Logging.ServiceName)
+	logLevel := &service.LevelVar{}
+	if cfg.Logging.Level != "" {
+		minLevel, err := service.ParseLogLevel(cfg.Logging.Level)
+		if err != nil {
+			log.Printf("Invalid log level, using DEBUG: %v", err)
+		} else {
+			logLevel.Set(minLevel)
+		}
+	}
+
+	loggerOptions := []service.Option{service.WithLevel(logLevel)}
+	var encoder service.Encoder
+	if cfg.Logging.Encoding != "" {
+		codec, err := service.CodecByName(cfg.Logging.Encoding)
+		if err != nil {
+			log.Printf("Invalid encoding, using JSON: %v", err)
+		} else {
+			encoder = codec
+			loggerOptions = append(loggerOptions, service.WithEncoder(codec))
+		}
+	}
+
+	if cfg.Logging.PartitionKey != "" {
+		keys, err := service.ParseKeyStrategy(cfg.Logging.PartitionKey)
+		if err != nil {
+			log.Printf("Invalid partition key, keying by service: %v", err)
+		} else {
+			loggerOptions = append(loggerOptions, service.WithKeyStrategy(keys))
+		}
+	}
+	if cfg.Kafka.Balancer != "" {
+		balancer, err := producer.BalancerByName(cfg.Kafka.Balancer)
+		if err != nil {
+			log.Printf("Invalid balancer, using least-bytes: %v", err)
+		} else {
+			loggerOptions = append(loggerOptions, service.WithBalancer(balancer))
+		}
+	}
+
+	if len(cfg.Logging.Redaction.Rules) > 0 {
+		redactor, err := service.NewRedactor(cfg.Logging.Redaction)
+		if err != nil {
+			log.Fatalf("Invalid redaction rules: %v", err)
+		}
+		loggerOptions = append(loggerOptions, service.WithRedactor(redactor))
+	}
+
+	if hooks := service.EnrichmentHooks(cfg.Logging.Enrichment); len(hooks) > 0 {
+		loggerOptions = append(loggerOptions, service.WithHooks(hooks...))
+	}
+
+	if cfg.Logging.FlightRecorderSize > 0 {
+		loggerOptions = append(loggerOptions, service.WithFlightRecorder(service.FlightRecorderConfig{Size: cfg.Logging.FlightRecorderSize}))
+	}
+
+	if sampling := cfg.Logging.Sampling; sampling.First > 0 || sampling.RateLimit > 0 {
+		loggerOptions = append(loggerOptions, service.WithSampling(service.SamplingConfig{
+			First:           sampling.First,
+			Thereafter:      sampling.Thereafter,
+			Interval:        sampling.Interval,
+			RateLimit:       sampling.RateLimit,
+			Burst:           sampling.Burst,
+			SummaryInterval: sampling.SummaryInterval,
+		}))
+	}
+
+	var consumeOptions []consumer.ConsumeOption
+	if cfg.Kafka.SchemaRegistryURL != "" {
+		if err := service.CheckRegistryEncoder(encoder); err != nil {
+			log.Fatalf("Invalid schema registry settings: %v", err)
+		}
+		registry := service.NewRegistryClient(cfg.Kafka.SchemaRegistryURL, nil)
+		loggerOptions = append(loggerOptions, service.WithSchemaRegistry(registry, service.TopicSubject(cfg.Kafka.Topic)))
+		consumeOptions = append(consumeOptions, consumer.WithSchemaRegistry(registry))
+	}
+
+	producerCfg := cfg.Kafka.Producer
+	logger, err := service.NewKafkaLoggerWithSettings(cfg.Kafka.Brokers, cfg.Kafka.Topic, cfg.Logging.ServiceName, producer.Settings{
+		Delivery:     producerCfg.Delivery,
+		BatchSize:    producerCfg.BatchSize,
+		BatchTimeout: producerCfg.BatchTimeout,
+		Compression:  producerCfg.Compression,
+		MaxAttempts:  producerCfg.MaxAttempts,
+		Async:        producerCfg.Async,
+	}, loggerOptions...)
+	if err != nil {
+		log.Fatalf("Invalid producer settings: %v", err)
+	}
 	defer logger.Close()
 
+	controlCtx, stopControl := context.WithCancel(context.Background())
+	defer stopControl()
+	if cfg.Kafka.ControlTopic != "" {
+		initControlTopic(cfg.Kafka.Brokers, cfg.Kafka.ControlTopic)
+		control.Subscribe(controlCtx, cfg.Kafka.Brokers, cfg.Kafka.ControlTopic, logger, cfg.Logging.InstanceID)
+	}
+
 	logger.Info("Application started", nil)
 
 	warnFields := map[string]any{
@@ -53,6 +156,18 @@ func main() {
 	logWriter := filewriter.NewLogFileWriter(cfg.Logging.FilePath)
 	defer logWriter.Close()
 
+	if cfg.Kafka.DeadLetterTopic != "" {
+		initKafkaTopic(cfg.Kafka.Brokers, cfg.Kafka.DeadLetterTopic, cfg.Kafka.Partitions)
+		// Dead letters are the only copy of a failed event, so wait for
+		// every in-sync replica.
+		deadLetters, err := producer.NewProducerWithSettings(cfg.Kafka.Brokers, cfg.Kafka.DeadLetterTopic, producer.Settings{Delivery: producer.AllISR})
+		if err != nil {
+			log.Fatalf("Invalid dead-letter producer settings: %v", err)
+		}
+		defer deadLetters.Close()
+		consumeOptions = append(consumeOptions, consumer.WithDeadLetter(deadLetters))
+	}
+
 	// Setup graceful shutdown
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
@@ -67,6 +182,28 @@ func main() {
 		cancel()
 	}()
 
+	// Consumers in the group split the partitions between them, so they
+	// share one tracker to see each producer's whole sequence.
+	tracker := consumer.NewTracker(consumer.TrackerConfig{
+		DedupWindow:   cfg.Consumer.DedupWindow,
+		ReorderWindow: cfg.Consumer.ReorderWindow,
+	})
+	errorGroups := consumer.NewErrorGroups()
+	consumeOptions = append(consumeOptions,
+		consumer.WithTracker(tracker),
+		consumer.WithErrorGroups(errorGroups),
+		consumer.WithCommitBatching(cfg.Consumer.CommitBatchSize, cfg.Consumer.CommitInterval))
+
+	supervisor := consumer.NewSupervisor(consumer.SupervisorConfig{
+		Backoff: consumer.Backoff{Initial: cfg.Consumer.RetryInitial, Max: cfg.Consumer.RetryMax},
+		OnRestart: func(id int, err error, delay time.Duration) {
+			log.Printf("Consumer %d failed, restarting in %v: %v", id, delay, err)
+		},
+		OnRetry: func(id int, err error, delay time.Duration) {
+			log.Printf("Consumer %d retrying in %v: %v", id, delay, err)
+		},
+	})
+
 	numConsumers := cfg.Consumer.NumConsumers
 	var wg sync.WaitGroup
 
@@ -75,20 +212,29 @@ func main() {
 		go func(consumerID int) {
 			defer wg.Done()
 
-			c := consumer.NewConsumer(cfg.Kafka.Brokers, cfg.Kafka.Topic, cfg.Consumer.GroupName)
-			defer c.Close()
-
+			opts := append(consumeOptions[:len(consumeOptions):len(consumeOptions)], supervisor.RetryOption(consumerID))
 			log.Printf("Starting consumer %d", consumerID)
-			if err := consumer.ConsumeLogEventsToFiles(ctx, c, logWriter); err != nil {
-				if err != context.Canceled {
-					log.Printf("Consumer %d error: %v", consumerID, err)
-				} else {
-					log.Printf("Consumer %d shutdown gracefully", consumerID)
-				}
-			}
+			supervisor.Run(ctx, consumerID, func(ctx context.Context) error {
+				c := consumer.NewConsumer(cfg.Kafka.Brokers, cfg.Kafka.Topic, cfg.Consumer.GroupName)
+				defer c.Close()
+				return consumer.ConsumeLogEventsToFiles(ctx, c, logWriter, opts...)
+			})
+			log.Printf("Consumer %d shutdown gracefully", consumerID)
 		}(i)
 	}
 
 	wg.Wait()
+	stats := tracker.Stats()
+	log.Printf("Consumed with %d duplicates dropped, %d gaps (%d events missing, %d late)",
+		stats.Duplicates, stats.Gaps, stats.Missing, stats.Late)
+	for _, group := range errorGroups.Groups() {
+		log.Printf("%d errors caused by %s, latest: %s", group.Count, group.RootCause, group.Latest.Message)
+	}
+	for _, status := range supervisor.Statuses() {
+		if status.Restarts > 0 || status.Retries > 0 {
+			log.Printf("Consumer %d restarted %d times and retried %d errors, last error: %v",
+				status.ID, status.Restarts, status.Retries, status.LastError)
+		}
+	}
 	log.Println("All consumers stopped, application shutdown complete")
 }
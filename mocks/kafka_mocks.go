@@ -4,6 +4,7 @@ import (
 	"context"
 	"errors"
 	"io"
+	"sync"
 
 	"github.com/segmentio/kafka-go"
 )
@@ -12,18 +13,37 @@ import (
 type MockMessageWriter struct {
 	Messages    []kafka.Message
 	WriteErr    error
+	WriteFunc   func(ctx context.Context, msgs ...kafka.Message) error
 	CloseFunc   func() error
 	CloseCalled bool
+	Batches     int
+	mutex       sync.Mutex
 }
 
 func (m *MockMessageWriter) WriteMessages(ctx context.Context, msgs ...kafka.Message) error {
+	if m.WriteFunc != nil {
+		if err := m.WriteFunc(ctx, msgs...); err != nil {
+			return err
+		}
+	}
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
 	if m.WriteErr != nil {
 		return m.WriteErr
 	}
 	m.Messages = append(m.Messages, msgs...)
+	m.Batches++
 	return nil
 }
 
+// Written returns a copy of the messages written so far, safe to call while
+// another goroutine is writing.
+func (m *MockMessageWriter) Written() []kafka.Message {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	return append([]kafka.Message(nil), m.Messages...)
+}
+
 func (m *MockMessageWriter) Close() error {
 	m.CloseCalled = true
 	if m.CloseFunc != nil {
@@ -39,9 +59,20 @@ type MockMessageReader struct {
 	ShouldError bool
 	ErrorMsg    string
 	CloseCalled bool
+	// Committed records the messages passed to CommitMessages, in order.
+	Committed []kafka.Message
+	Commits   int
+	CommitErr error
+	mutex     sync.Mutex
 }
 
 func (m *MockMessageReader) ReadMessage(ctx context.Context) (kafka.Message, error) {
+	return m.FetchMessage(ctx)
+}
+
+// FetchMessage returns the next message like ReadMessage. The mock keeps no
+// offsets, so the two only differ in what the caller does afterwards.
+func (m *MockMessageReader) FetchMessage(ctx context.Context) (kafka.Message, error) {
 	if m.ShouldError {
 		if m.ErrorMsg != "" {
 			return kafka.Message{}, errors.New(m.ErrorMsg)
@@ -56,6 +87,25 @@ func (m *MockMessageReader) ReadMessage(ctx context.Context) (kafka.Message, err
 	return msg, nil
 }
 
+func (m *MockMessageReader) CommitMessages(ctx context.Context, msgs ...kafka.Message) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	if m.CommitErr != nil {
+		return m.CommitErr
+	}
+	m.Committed = append(m.Committed, msgs...)
+	m.Commits++
+	return nil
+}
+
+// CommittedMessages returns a copy of the committed messages, safe to call
+// while another goroutine is committing.
+func (m *MockMessageReader) CommittedMessages() []kafka.Message {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	return append([]kafka.Message(nil), m.Committed...)
+}
+
 func (m *MockMessageReader) Close() error {
 	m.CloseCalled = true
 	return nil
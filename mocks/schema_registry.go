@@ -0,0 +1,95 @@
+package mocks
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+)
+
+type registrySchema struct {
+	Schema     string `json:"schema"`
+	SchemaType string `json:"schemaType,omitempty"`
+}
+
+// SchemaRegistry is an in-process stand-in for a schema registry. It serves
+// the subset of the REST API used by the logger: registering a schema under a
+// subject and looking a schema up by ID.
+type SchemaRegistry struct {
+	*httptest.Server
+
+	mutex    sync.Mutex
+	schemas  []registrySchema
+	requests int
+}
+
+// NewSchemaRegistry starts a registry stub. Callers must Close it.
+func NewSchemaRegistry() *SchemaRegistry {
+	r := &SchemaRegistry{}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /subjects/{subject}/versions", r.register)
+	mux.HandleFunc("GET /schemas/ids/{id}", r.schemaByID)
+	r.Server = httptest.NewServer(mux)
+	return r
+}
+
+// RequestCount returns the number of requests the registry has served.
+func (r *SchemaRegistry) RequestCount() int {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return r.requests
+}
+
+func (r *SchemaRegistry) register(w http.ResponseWriter, req *http.Request) {
+	var schema registrySchema
+	if err := json.NewDecoder(req.Body).Decode(&schema); err != nil || schema.Schema == "" {
+		writeRegistryError(w, http.StatusUnprocessableEntity, 42201, "Invalid schema")
+		return
+	}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.requests++
+
+	// Schemas are global: the same schema gets the same ID in every subject.
+	id := 0
+	for i, existing := range r.schemas {
+		if existing == schema {
+			id = i + 1
+			break
+		}
+	}
+	if id == 0 {
+		r.schemas = append(r.schemas, schema)
+		id = len(r.schemas)
+	}
+
+	writeRegistryJSON(w, map[string]int{"id": id})
+}
+
+func (r *SchemaRegistry) schemaByID(w http.ResponseWriter, req *http.Request) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.requests++
+
+	id, err := strconv.Atoi(req.PathValue("id"))
+	if err != nil || id < 1 || id > len(r.schemas) {
+		writeRegistryError(w, http.StatusNotFound, 40403, fmt.Sprintf("Schema %s not found", req.PathValue("id")))
+		return
+	}
+	writeRegistryJSON(w, r.schemas[id-1])
+}
+
+func writeRegistryJSON(w http.ResponseWriter, body any) {
+	w.Header().Set("Content-Type", "application/vnd.schemaregistry.v1+json")
+	json.NewEncoder(w).Encode(body)
+}
+
+func writeRegistryError(w http.ResponseWriter, status, code int, message string) {
+	w.Header().Set("Content-Type", "application/vnd.schemaregistry.v1+json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]any{"error_code": code, "message": message})
+}
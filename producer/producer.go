@@ -2,6 +2,9 @@ package producer
 
 import (
 	"context"
+	"fmt"
+	"strings"
+	"time"
 
 	"github.com/segmentio/kafka-go"
 )
@@ -21,6 +24,87 @@ func NewProducer(brokers []string, topic string) *kafka.Writer {
 	return writer
 }
 
+// Delivery profiles trade latency for durability by choosing how many
+// replicas must acknowledge a write.
+const (
+	FireAndForget = "fire-and-forget"
+	LeaderAck     = "leader-ack"
+	AllISR        = "all-isr"
+)
+
+// Settings tunes the writer created by NewProducerWithSettings. Zero values
+// keep the kafka-go defaults.
+type Settings struct {
+	// Delivery is FireAndForget, LeaderAck or AllISR.
+	Delivery     string
+	BatchSize    int
+	BatchTimeout time.Duration
+	// Compression is "none", "gzip", "snappy", "lz4" or "zstd".
+	Compression string
+	MaxAttempts int
+	// Async makes WriteMessages return without waiting for the broker.
+	// Errors are then only reported to Completion.
+	Async      bool
+	Completion func(messages []kafka.Message, err error)
+}
+
+func NewProducerWithSettings(brokers []string, topic string, settings Settings) (*kafka.Writer, error) {
+	writer := NewProducer(brokers, topic)
+
+	acks, err := requiredAcks(settings.Delivery)
+	if err != nil {
+		return nil, err
+	}
+	writer.RequiredAcks = acks
+
+	if settings.Compression != "" {
+		var codec kafka.Compression
+		if err := codec.UnmarshalText([]byte(strings.ToLower(settings.Compression))); err != nil {
+			return nil, fmt.Errorf("unknown compression %q", settings.Compression)
+		}
+		writer.Compression = codec
+	}
+
+	writer.BatchSize = settings.BatchSize
+	writer.BatchTimeout = settings.BatchTimeout
+	writer.MaxAttempts = settings.MaxAttempts
+	writer.Async = settings.Async
+	writer.Completion = settings.Completion
+	return writer, nil
+}
+
+func requiredAcks(delivery string) (kafka.RequiredAcks, error) {
+	switch strings.ToLower(strings.TrimSpace(delivery)) {
+	case "", FireAndForget:
+		return kafka.RequireNone, nil
+	case LeaderAck:
+		return kafka.RequireOne, nil
+	case AllISR:
+		return kafka.RequireAll, nil
+	default:
+		return 0, fmt.Errorf("unknown delivery profile %q", delivery)
+	}
+}
+
+// BalancerByName returns the partition balancer for a configuration name:
+// "least-bytes" (the default), "hash", "murmur2" or "round-robin". Use "hash"
+// or "murmur2" to keep messages with the same key on one partition; murmur2
+// matches the partitioning of the Java client.
+func BalancerByName(name string) (kafka.Balancer, error) {
+	switch strings.ToLower(strings.TrimSpace(name)) {
+	case "", "least-bytes":
+		return &kafka.LeastBytes{}, nil
+	case "hash":
+		return &kafka.Hash{}, nil
+	case "murmur2":
+		return kafka.Murmur2Balancer{}, nil
+	case "round-robin":
+		return &kafka.RoundRobin{}, nil
+	default:
+		return nil, fmt.Errorf("unknown balancer %q", name)
+	}
+}
+
 func SendMessage(writer MessageWriter, key, value string) error {
 	return writer.WriteMessages(context.Background(),
 		kafka.Message{
@@ -2,7 +2,11 @@ package producer
 
 import (
 	"kafka-logger/mocks"
+	"reflect"
 	"testing"
+	"time"
+
+	"github.com/segmentio/kafka-go"
 )
 
 func TestNewProducer(t *testing.T) {
@@ -45,3 +49,76 @@ func TestSendMessage(t *testing.T) {
 		t.Errorf("expected value '%s', got %s", testValue, msg.Value)
 	}
 }
+
+func TestBalancerByName(t *testing.T) {
+	for name, expected := range map[string]kafka.Balancer{
+		"":            &kafka.LeastBytes{},
+		"least-bytes": &kafka.LeastBytes{},
+		"hash":        &kafka.Hash{},
+		"Murmur2":     kafka.Murmur2Balancer{},
+		"round-robin": &kafka.RoundRobin{},
+	} {
+		balancer, err := BalancerByName(name)
+		if err != nil {
+			t.Errorf("Expected balancer for %q, got error: %v", name, err)
+			continue
+		}
+		if reflect.TypeOf(balancer) != reflect.TypeOf(expected) {
+			t.Errorf("Expected %T for %q, got %T", expected, name, balancer)
+		}
+	}
+
+	if _, err := BalancerByName("sticky"); err == nil {
+		t.Error("Expected error for unknown balancer")
+	}
+}
+
+func TestNewProducerWithSettings(t *testing.T) {
+	t.Run("Profiles", func(t *testing.T) {
+		for profile, expected := range map[string]kafka.RequiredAcks{
+			"":            kafka.RequireNone,
+			FireAndForget: kafka.RequireNone,
+			LeaderAck:     kafka.RequireOne,
+			"ALL-ISR":     kafka.RequireAll,
+		} {
+			writer, err := NewProducerWithSettings([]string{"localhost:9092"}, "test-topic", Settings{Delivery: profile})
+			if err != nil {
+				t.Errorf("Expected profile %q to be valid, got: %v", profile, err)
+				continue
+			}
+			if writer.RequiredAcks != expected {
+				t.Errorf("Expected acks %v for %q, got %v", expected, profile, writer.RequiredAcks)
+			}
+			writer.Close()
+		}
+	})
+
+	t.Run("Batching, compression and retries", func(t *testing.T) {
+		writer, err := NewProducerWithSettings([]string{"localhost:9092"}, "test-topic", Settings{
+			BatchSize:    500,
+			BatchTimeout: 50 * time.Millisecond,
+			Compression:  "zstd",
+			MaxAttempts:  7,
+			Async:        true,
+		})
+		if err != nil {
+			t.Fatalf("Expected no error, got: %v", err)
+		}
+		defer writer.Close()
+
+		if writer.BatchSize != 500 || writer.BatchTimeout != 50*time.Millisecond || writer.MaxAttempts != 7 || !writer.Async {
+			t.Errorf("Settings not applied: %+v", writer)
+		}
+		if writer.Compression != kafka.Zstd {
+			t.Errorf("Expected zstd compression, got %v", writer.Compression)
+		}
+	})
+
+	t.Run("Invalid settings", func(t *testing.T) {
+		for _, settings := range []Settings{{Delivery: "maybe"}, {Compression: "brotli"}} {
+			if _, err := NewProducerWithSettings([]string{"localhost:9092"}, "test-topic", settings); err == nil {
+				t.Errorf("Expected error for %+v", settings)
+			}
+		}
+	})
+}
@@ -0,0 +1,248 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"kafka-logger/producer"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+)
+
+// OverflowPolicy decides what happens when the async queue is full.
+type OverflowPolicy int
+
+const (
+	// OverflowBlock makes the caller wait until there is room in the queue.
+	OverflowBlock OverflowPolicy = iota
+	// OverflowDropNewest discards the event being logged.
+	OverflowDropNewest
+	// OverflowDropOldest evicts the oldest queued event to make room.
+	OverflowDropOldest
+	// OverflowDropBelowLevel discards events below AsyncConfig.DropBelow and
+	// blocks for everything else.
+	OverflowDropBelowLevel
+)
+
+const (
+	defaultQueueSize     = 1024
+	defaultBatchSize     = 100
+	defaultFlushInterval = 100 * time.Millisecond
+	defaultCloseTimeout  = 5 * time.Second
+)
+
+type AsyncConfig struct {
+	QueueSize     int
+	BatchSize     int
+	FlushInterval time.Duration
+	Overflow      OverflowPolicy
+	DropBelow     LogLevel
+	// CloseTimeout bounds how long Close waits for the queue to drain.
+	CloseTimeout time.Duration
+	// OnError is called when a batch cannot be written. Defaults to logging to stderr.
+	OnError func(err error, msgs []kafka.Message)
+}
+
+// WithAsync makes the logger enqueue events and write them in batches from a
+// background goroutine instead of calling WriteMessages on every event.
+func WithAsync(cfg AsyncConfig) Option {
+	return func(o *loggerOptions) {
+		o.async = &cfg
+	}
+}
+
+type queuedMessage struct {
+	level LogLevel
+	msg   kafka.Message
+}
+
+type asyncQueue struct {
+	writer producer.MessageWriter
+	spool  *diskSpool
+	cfg    AsyncConfig
+	queue  chan queuedMessage
+
+	// completion receives the events of dropped batches; see WithCompletion.
+	completion func(failed []LogEvent, err error)
+
+	mu     sync.RWMutex
+	closed bool
+
+	dropped      atomic.Uint64
+	writeCtx     context.Context
+	cancelWrites context.CancelFunc
+	done         chan struct{}
+}
+
+func newAsyncQueue(writer producer.MessageWriter, spool *diskSpool, cfg AsyncConfig) *asyncQueue {
+	if cfg.QueueSize <= 0 {
+		cfg.QueueSize = defaultQueueSize
+	}
+	if cfg.BatchSize <= 0 {
+		cfg.BatchSize = defaultBatchSize
+	}
+	if cfg.FlushInterval <= 0 {
+		cfg.FlushInterval = defaultFlushInterval
+	}
+	if cfg.CloseTimeout <= 0 {
+		cfg.CloseTimeout = defaultCloseTimeout
+	}
+	if cfg.OnError == nil {
+		cfg.OnError = func(err error, msgs []kafka.Message) {
+			internalLog.Printf("Failed to write %d log events: %v", len(msgs), err)
+		}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	q := &asyncQueue{
+		writer:       writer,
+		spool:        spool,
+		cfg:          cfg,
+		queue:        make(chan queuedMessage, cfg.QueueSize),
+		writeCtx:     ctx,
+		cancelWrites: cancel,
+		done:         make(chan struct{}),
+	}
+	go q.run()
+	return q
+}
+
+func (q *asyncQueue) enqueue(level LogLevel, msg kafka.Message) error {
+	q.mu.RLock()
+	defer q.mu.RUnlock()
+
+	if q.closed {
+		return ErrLoggerClosed
+	}
+
+	item := queuedMessage{level: level, msg: msg}
+
+	switch q.cfg.Overflow {
+	case OverflowDropNewest:
+		return q.tryEnqueue(item)
+	case OverflowDropOldest:
+		for {
+			select {
+			case q.queue <- item:
+				return nil
+			default:
+			}
+			select {
+			case oldest := <-q.queue:
+				q.dropped.Add(1)
+				reportFailedLines([]kafka.Message{oldest.msg}, ErrEventDropped)
+			default:
+			}
+		}
+	case OverflowDropBelowLevel:
+		if !level.AtLeast(q.cfg.DropBelow) {
+			return q.tryEnqueue(item)
+		}
+	}
+
+	q.queue <- item
+	return nil
+}
+
+func (q *asyncQueue) tryEnqueue(item queuedMessage) error {
+	select {
+	case q.queue <- item:
+		return nil
+	default:
+		q.dropped.Add(1)
+		return ErrEventDropped
+	}
+}
+
+func (q *asyncQueue) run() {
+	defer close(q.done)
+
+	ticker := time.NewTicker(q.cfg.FlushInterval)
+	defer ticker.Stop()
+
+	batch := make([]kafka.Message, 0, q.cfg.BatchSize)
+	for {
+		select {
+		case item, ok := <-q.queue:
+			if !ok {
+				q.flush(batch)
+				return
+			}
+			batch = append(batch, item.msg)
+			if len(batch) >= q.cfg.BatchSize {
+				q.flush(batch)
+				batch = make([]kafka.Message, 0, q.cfg.BatchSize)
+			}
+		case <-ticker.C:
+			if len(batch) > 0 {
+				q.flush(batch)
+				batch = make([]kafka.Message, 0, q.cfg.BatchSize)
+			}
+		}
+	}
+}
+
+func (q *asyncQueue) flush(batch []kafka.Message) {
+	if len(batch) == 0 {
+		return
+	}
+
+	if q.spool != nil && q.spool.pending() {
+		// Older events are waiting in the spool; queue behind them.
+		if err := q.spool.enqueue(batch...); err != nil {
+			q.drop(batch, err)
+		}
+		return
+	}
+
+	// Once the close deadline has passed, whatever is left is given up on
+	// unless it can be spooled.
+	err := q.writeCtx.Err()
+	if err == nil {
+		err = q.writer.WriteMessages(q.writeCtx, batch...)
+	}
+	if err == nil {
+		return
+	}
+
+	if q.spool != nil {
+		spoolErr := q.spool.append(batch...)
+		if spoolErr == nil {
+			return
+		}
+		err = errors.Join(err, spoolErr)
+	}
+	q.drop(batch, err)
+}
+
+// drop gives up on a batch that could be neither written nor spooled.
+func (q *asyncQueue) drop(batch []kafka.Message, err error) {
+	q.dropped.Add(uint64(len(batch)))
+	q.cfg.OnError(err, batch)
+	if q.completion != nil {
+		q.completion(decodeFailed(batch), err)
+	}
+	reportFailedLines(batch, err)
+}
+
+// close stops accepting events and waits up to CloseTimeout for the queue to
+// be written.
+func (q *asyncQueue) close() {
+	// The deadline starts before taking the lock, which waits for callers
+	// blocked on a full queue: cancelling the writes empties the queue and
+	// lets them through.
+	deadline := time.AfterFunc(q.cfg.CloseTimeout, q.cancelWrites)
+	defer deadline.Stop()
+
+	q.mu.Lock()
+	if !q.closed {
+		q.closed = true
+		close(q.queue)
+	}
+	q.mu.Unlock()
+
+	<-q.done
+	q.cancelWrites()
+}
@@ -0,0 +1,275 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"kafka-logger/mocks"
+	"testing"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+)
+
+func TestAsyncLogger(t *testing.T) {
+	t.Parallel()
+
+	t.Run("Batches events into one write", func(t *testing.T) {
+		t.Parallel()
+		mockWriter := &mocks.MockMessageWriter{}
+		logger := newKafkaLogger(mockWriter, "test-service", WithAsync(AsyncConfig{
+			BatchSize:     10,
+			FlushInterval: time.Hour,
+		}))
+
+		for range 10 {
+			checkNoError(t, logger.Info("batched", nil))
+		}
+
+		checkNoError(t, logger.Close())
+
+		if len(mockWriter.Messages) != 10 {
+			t.Fatalf("Expected 10 messages, got %d", len(mockWriter.Messages))
+		}
+		if mockWriter.Batches != 1 {
+			t.Errorf("Expected 1 WriteMessages call, got %d", mockWriter.Batches)
+		}
+	})
+
+	t.Run("Flushes on interval", func(t *testing.T) {
+		t.Parallel()
+		mockWriter := &mocks.MockMessageWriter{}
+		logger := newKafkaLogger(mockWriter, "test-service", WithAsync(AsyncConfig{
+			BatchSize:     100,
+			FlushInterval: 5 * time.Millisecond,
+		}))
+		defer logger.Close()
+
+		checkNoError(t, logger.Warn("waiting for tick", nil))
+
+		deadline := time.Now().Add(time.Second)
+		for len(mockWriter.Written()) == 0 {
+			if time.Now().After(deadline) {
+				t.Fatal("Expected event to be flushed by the interval")
+			}
+			time.Sleep(time.Millisecond)
+		}
+	})
+
+	t.Run("Drop newest when full", func(t *testing.T) {
+		t.Parallel()
+		release := make(chan struct{})
+		mockWriter := &mocks.MockMessageWriter{
+			WriteFunc: func(ctx context.Context, msgs ...kafka.Message) error {
+				<-release
+				return nil
+			},
+		}
+		logger := newKafkaLogger(mockWriter, "test-service", WithAsync(AsyncConfig{
+			QueueSize:     1,
+			BatchSize:     1,
+			FlushInterval: time.Hour,
+			Overflow:      OverflowDropNewest,
+		}))
+
+		// The first event is held by the blocked writer, the second fills the queue.
+		checkNoError(t, logger.Info("first", nil))
+		waitForQueueEmpty(t, logger)
+		checkNoError(t, logger.Info("second", nil))
+
+		if err := logger.Info("third", nil); !errors.Is(err, ErrEventDropped) {
+			t.Errorf("Expected ErrEventDropped, got: %v", err)
+		}
+
+		close(release)
+		if err := logger.Close(); !errors.Is(err, ErrEventDropped) {
+			t.Errorf("Expected Close to report dropped events, got: %v", err)
+		}
+
+		assertMessages(t, mockWriter, "first", "second")
+		if logger.Dropped() != 1 {
+			t.Errorf("Expected 1 dropped event, got %d", logger.Dropped())
+		}
+	})
+
+	t.Run("Drop oldest when full", func(t *testing.T) {
+		t.Parallel()
+		release := make(chan struct{})
+		mockWriter := &mocks.MockMessageWriter{
+			WriteFunc: func(ctx context.Context, msgs ...kafka.Message) error {
+				<-release
+				return nil
+			},
+		}
+		logger := newKafkaLogger(mockWriter, "test-service", WithAsync(AsyncConfig{
+			QueueSize:     1,
+			BatchSize:     1,
+			FlushInterval: time.Hour,
+			Overflow:      OverflowDropOldest,
+		}))
+
+		checkNoError(t, logger.Info("first", nil))
+		waitForQueueEmpty(t, logger)
+		checkNoError(t, logger.Info("second", nil))
+		checkNoError(t, logger.Info("third", nil))
+
+		close(release)
+		if err := logger.Close(); !errors.Is(err, ErrEventDropped) {
+			t.Errorf("Expected Close to report dropped events, got: %v", err)
+		}
+
+		assertMessages(t, mockWriter, "first", "third")
+	})
+
+	t.Run("Drop below level when full", func(t *testing.T) {
+		t.Parallel()
+		release := make(chan struct{})
+		mockWriter := &mocks.MockMessageWriter{
+			WriteFunc: func(ctx context.Context, msgs ...kafka.Message) error {
+				<-release
+				return nil
+			},
+		}
+		logger := newKafkaLogger(mockWriter, "test-service", WithAsync(AsyncConfig{
+			QueueSize:     1,
+			BatchSize:     1,
+			FlushInterval: time.Hour,
+			Overflow:      OverflowDropBelowLevel,
+			DropBelow:     WARN,
+		}))
+
+		checkNoError(t, logger.Info("first", nil))
+		waitForQueueEmpty(t, logger)
+		checkNoError(t, logger.Info("second", nil))
+
+		if err := logger.Debug("dropped", nil); !errors.Is(err, ErrEventDropped) {
+			t.Errorf("Expected ErrEventDropped for DEBUG, got: %v", err)
+		}
+
+		errDone := make(chan error)
+		go func() {
+			errDone <- logger.Error("blocks until there is room", nil)
+		}()
+
+		select {
+		case <-errDone:
+			t.Fatal("Expected ERROR to block while the queue is full")
+		case <-time.After(10 * time.Millisecond):
+		}
+
+		close(release)
+		checkNoError(t, <-errDone)
+		if err := logger.Close(); !errors.Is(err, ErrEventDropped) {
+			t.Errorf("Expected Close to report dropped events, got: %v", err)
+		}
+
+		assertMessages(t, mockWriter, "first", "second", "blocks until there is room")
+	})
+
+	t.Run("Close deadline reports dropped events", func(t *testing.T) {
+		t.Parallel()
+		mockWriter := &mocks.MockMessageWriter{
+			WriteFunc: func(ctx context.Context, msgs ...kafka.Message) error {
+				<-ctx.Done()
+				return ctx.Err()
+			},
+		}
+		logger := newKafkaLogger(mockWriter, "test-service", WithAsync(AsyncConfig{
+			BatchSize:     1,
+			FlushInterval: time.Hour,
+			CloseTimeout:  10 * time.Millisecond,
+			OnError:       func(error, []kafka.Message) {},
+		}))
+
+		for range 3 {
+			checkNoError(t, logger.Info("never delivered", nil))
+		}
+
+		err := logger.Close()
+		if !errors.Is(err, ErrEventDropped) {
+			t.Fatalf("Expected ErrEventDropped, got: %v", err)
+		}
+		if logger.Dropped() != 3 {
+			t.Errorf("Expected 3 dropped events, got %d", logger.Dropped())
+		}
+	})
+
+	t.Run("Close deadline holds with a caller blocked on a full queue", func(t *testing.T) {
+		t.Parallel()
+		mockWriter := &mocks.MockMessageWriter{
+			WriteFunc: func(ctx context.Context, msgs ...kafka.Message) error {
+				<-ctx.Done()
+				return ctx.Err()
+			},
+		}
+		logger := newKafkaLogger(mockWriter, "test-service", WithAsync(AsyncConfig{
+			QueueSize:     1,
+			BatchSize:     1,
+			FlushInterval: time.Hour,
+			CloseTimeout:  10 * time.Millisecond,
+			OnError:       func(error, []kafka.Message) {},
+		}))
+
+		checkNoError(t, logger.Info("being written", nil))
+		waitForQueueEmpty(t, logger)
+		checkNoError(t, logger.Info("queued", nil))
+
+		errDone := make(chan error)
+		go func() {
+			errDone <- logger.Info("blocked", nil)
+		}()
+		select {
+		case <-errDone:
+			t.Fatal("Expected the event to block while the queue is full")
+		case <-time.After(10 * time.Millisecond):
+		}
+
+		closeDone := make(chan error)
+		go func() {
+			closeDone <- logger.Close()
+		}()
+		select {
+		case err := <-closeDone:
+			if !errors.Is(err, ErrEventDropped) {
+				t.Errorf("Expected ErrEventDropped, got: %v", err)
+			}
+		case <-time.After(time.Second):
+			t.Fatal("Expected Close to return after its deadline")
+		}
+		checkNoError(t, <-errDone)
+	})
+
+	t.Run("Log after close", func(t *testing.T) {
+		t.Parallel()
+		logger := newKafkaLogger(&mocks.MockMessageWriter{}, "test-service", WithAsync(AsyncConfig{}))
+		checkNoError(t, logger.Close())
+
+		if err := logger.Info("too late", nil); !errors.Is(err, ErrLoggerClosed) {
+			t.Errorf("Expected ErrLoggerClosed, got: %v", err)
+		}
+	})
+}
+
+func waitForQueueEmpty(t *testing.T, logger *KafkaLogger) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for len(logger.async.queue) > 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("Timed out waiting for the queue to drain")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func assertMessages(t *testing.T, mockWriter *mocks.MockMessageWriter, expected ...string) {
+	t.Helper()
+	written := mockWriter.Written()
+	if len(written) != len(expected) {
+		t.Fatalf("Expected %d messages, got %d", len(expected), len(written))
+	}
+	for i, msg := range written {
+		logEvent := decodeLogEvent(t, msg)
+		if logEvent.Message != expected[i] {
+			t.Errorf("Expected message %d to be '%s', got '%s'", i, expected[i], logEvent.Message)
+		}
+	}
+}
@@ -0,0 +1,123 @@
+package service
+
+import (
+	"runtime"
+	"strconv"
+	"strings"
+)
+
+// Caller is the source location an event was logged from.
+type Caller struct {
+	File     string `json:"file"`
+	Line     int    `json:"line"`
+	Function string `json:"function"`
+}
+
+func (c Caller) String() string {
+	return c.Function + " (" + c.File + ":" + strconv.Itoa(c.Line) + ")"
+}
+
+// maxStackDepth bounds the number of frames captured for a stack trace.
+const maxStackDepth = 64
+
+// WithStackTraces sets the levels whose events carry a stack trace of the
+// logging goroutine. The default is ERROR only; no levels disables stacks.
+func WithStackTraces(levels ...LogLevel) Option {
+	return func(o *loggerOptions) {
+		o.stackLevels = levels
+		o.stackLevelsSet = true
+	}
+}
+
+func (kl *KafkaLogger) wantsStack(level LogLevel) bool {
+	for _, l := range kl.stackLevels {
+		if l == level {
+			return true
+		}
+	}
+	return false
+}
+
+// callerAt returns the location skip frames above its caller.
+func callerAt(skip int) *Caller {
+	var pcs [1]uintptr
+	if runtime.Callers(skip+2, pcs[:]) == 0 {
+		return nil
+	}
+	return callerFromPC(pcs[0])
+}
+
+func callerFromPC(pc uintptr) *Caller {
+	if pc == 0 {
+		return nil
+	}
+	frame, _ := runtime.CallersFrames([]uintptr{pc}).Next()
+	if frame.Function == "" {
+		return nil
+	}
+	return &Caller{File: frame.File, Line: frame.Line, Function: frame.Function}
+}
+
+// panicCaller returns the frame that panicked, for use by a deferred
+// function that recovered the panic, or nil if it is not found.
+func panicCaller() *Caller {
+	pcs := make([]uintptr, maxStackDepth)
+	n := runtime.Callers(2, pcs)
+	frames := runtime.CallersFrames(pcs[:n])
+
+	panicking := false
+	for {
+		frame, more := frames.Next()
+		switch {
+		case frame.Function == "runtime.gopanic":
+			panicking = true
+		case panicking && !strings.HasPrefix(frame.Function, "runtime."):
+			// Frames such as runtime.panicmem sit between gopanic and the
+			// code that faulted.
+			return &Caller{File: frame.File, Line: frame.Line, Function: frame.Function}
+		}
+		if !more {
+			return nil
+		}
+	}
+}
+
+// stackFrom formats the current goroutine's stack starting at the frame for
+// caller, so frames inside the logger are left out. The format follows
+// runtime/debug.Stack: the function, then its file and line indented by a tab.
+func stackFrom(caller *Caller) string {
+	pcs := make([]uintptr, maxStackDepth)
+	n := runtime.Callers(2, pcs)
+	frames := runtime.CallersFrames(pcs[:n])
+
+	var sb strings.Builder
+	started := caller == nil
+	for {
+		frame, more := frames.Next()
+		if !started && frame.Function == caller.Function && frame.Line == caller.Line {
+			started = true
+		}
+		if started {
+			writeFrame(&sb, frame)
+		}
+		if !more {
+			break
+		}
+	}
+
+	if sb.Len() == 0 {
+		// The caller was not found, e.g. because of inlining; keep everything
+		// rather than lose the trace.
+		return stackFrom(nil)
+	}
+	return sb.String()
+}
+
+func writeFrame(sb *strings.Builder, frame runtime.Frame) {
+	sb.WriteString(frame.Function)
+	sb.WriteString("\n\t")
+	sb.WriteString(frame.File)
+	sb.WriteByte(':')
+	sb.WriteString(strconv.Itoa(frame.Line))
+	sb.WriteByte('\n')
+}
@@ -0,0 +1,85 @@
+package service
+
+import (
+	"context"
+	"kafka-logger/mocks"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+func TestCallerAndStack(t *testing.T) {
+	t.Run("Caller on every event, stack on ERROR", func(t *testing.T) {
+		mockWriter := &mocks.MockMessageWriter{}
+		logger := newKafkaLogger(mockWriter, "test-service")
+
+		checkNoError(t, logger.Info("info", nil))
+		checkNoError(t, logger.With(map[string]any{"k": "v"}).ErrorContext(context.Background(), "error", nil))
+
+		info := decodeLogEvent(t, mockWriter.Messages[0])
+		assertCaller(t, info.Caller, "caller_test.go", "TestCallerAndStack.func1")
+		if info.Stack != "" {
+			t.Errorf("Expected no stack on INFO, got %s", info.Stack)
+		}
+
+		errEvent := decodeLogEvent(t, mockWriter.Messages[1])
+		assertCaller(t, errEvent.Caller, "caller_test.go", "TestCallerAndStack.func1")
+		firstFrame, _, _ := strings.Cut(errEvent.Stack, "\n")
+		if !strings.HasSuffix(firstFrame, "TestCallerAndStack.func1") {
+			t.Errorf("Expected the stack to start at the caller, got:\n%s", errEvent.Stack)
+		}
+		if strings.Contains(errEvent.Stack, "KafkaLogger") {
+			t.Errorf("Expected logger frames to be left out, got:\n%s", errEvent.Stack)
+		}
+		if !strings.Contains(errEvent.Stack, "testing.tRunner") {
+			t.Errorf("Expected the full goroutine stack, got:\n%s", errEvent.Stack)
+		}
+	})
+
+	t.Run("Configurable stack levels", func(t *testing.T) {
+		mockWriter := &mocks.MockMessageWriter{}
+		logger := newKafkaLogger(mockWriter, "test-service", WithStackTraces(WARN))
+
+		checkNoError(t, logger.Warn("warn", nil))
+		checkNoError(t, logger.Error("error", nil))
+
+		if decodeLogEvent(t, mockWriter.Messages[0]).Stack == "" {
+			t.Error("Expected a stack on WARN")
+		}
+		if decodeLogEvent(t, mockWriter.Messages[1]).Stack != "" {
+			t.Error("Expected no stack on ERROR")
+		}
+
+		mockWriter = &mocks.MockMessageWriter{}
+		checkNoError(t, newKafkaLogger(mockWriter, "test-service", WithStackTraces()).Error("error", nil))
+		if decodeLogEvent(t, mockWriter.Messages[0]).Stack != "" {
+			t.Error("Expected stacks to be disabled")
+		}
+	})
+
+	t.Run("slog records", func(t *testing.T) {
+		mockWriter := &mocks.MockMessageWriter{}
+		slogger := slog.New(NewSlogHandler(newKafkaLogger(mockWriter, "test-service"), nil))
+
+		slogger.Error("from slog")
+
+		event := decodeLogEvent(t, mockWriter.Messages[0])
+		assertCaller(t, event.Caller, "caller_test.go", "TestCallerAndStack.func3")
+		if !strings.HasPrefix(event.Stack, event.Caller.Function+"\n") {
+			t.Errorf("Expected the stack to start at the slog caller, got:\n%s", event.Stack)
+		}
+	})
+}
+
+func assertCaller(t *testing.T, caller *Caller, file, function string) {
+	t.Helper()
+	if caller == nil {
+		t.Fatal("Expected caller information")
+	}
+	if !strings.HasSuffix(caller.File, file) || caller.Line == 0 {
+		t.Errorf("Expected a line in %s, got %s:%d", file, caller.File, caller.Line)
+	}
+	if !strings.HasSuffix(caller.Function, function) {
+		t.Errorf("Expected function %s, got %s", function, caller.Function)
+	}
+}
@@ -0,0 +1,77 @@
+package service
+
+import (
+	"context"
+	"sync"
+)
+
+type contextKey string
+
+const (
+	traceIDKey contextKey = "trace_id"
+	spanIDKey  contextKey = "span_id"
+	loggerKey  contextKey = "logger"
+
+	requestIDKey   contextKey = "request_id"
+	traceparentKey contextKey = "traceparent"
+)
+
+var (
+	contextKeysMutex sync.RWMutex
+	contextKeys      = map[string]any{
+		"trace_id": traceIDKey,
+		"span_id":  spanIDKey,
+	}
+)
+
+// RegisterContextKey makes the *Context logging methods add ctx.Value(key) as
+// the field name whenever the context carries a value for key. trace_id and
+// span_id are registered by default.
+func RegisterContextKey(name string, key any) {
+	contextKeysMutex.Lock()
+	defer contextKeysMutex.Unlock()
+	contextKeys[name] = key
+}
+
+func ContextWithTraceID(ctx context.Context, traceID string) context.Context {
+	return context.WithValue(ctx, traceIDKey, traceID)
+}
+
+func ContextWithSpanID(ctx context.Context, spanID string) context.Context {
+	return context.WithValue(ctx, spanIDKey, spanID)
+}
+
+func contextFields(ctx context.Context) map[string]any {
+	if ctx == nil {
+		return nil
+	}
+
+	contextKeysMutex.RLock()
+	defer contextKeysMutex.RUnlock()
+
+	var fields map[string]any
+	for name, key := range contextKeys {
+		if value := ctx.Value(key); value != nil {
+			if fields == nil {
+				fields = make(map[string]any)
+			}
+			fields[name] = value
+		}
+	}
+	return fields
+}
+
+// ContextWithLogger returns a context carrying logger, typically a child
+// logger with request fields bound.
+func ContextWithLogger(ctx context.Context, logger *KafkaLogger) context.Context {
+	return context.WithValue(ctx, loggerKey, logger)
+}
+
+// LoggerFromContext returns the logger stored by ContextWithLogger, or
+// fallback if there is none.
+func LoggerFromContext(ctx context.Context, fallback *KafkaLogger) *KafkaLogger {
+	if logger, ok := ctx.Value(loggerKey).(*KafkaLogger); ok {
+		return logger
+	}
+	return fallback
+}
@@ -0,0 +1,128 @@
+package service
+
+import (
+	"context"
+	"kafka-logger/mocks"
+	"testing"
+
+	"github.com/segmentio/kafka-go"
+)
+
+type tenantKey struct{}
+
+func TestChildLogger(t *testing.T) {
+	t.Parallel()
+
+	t.Run("Bound fields are merged", func(t *testing.T) {
+		mockWriter := &mocks.MockMessageWriter{}
+		logger := &KafkaLogger{
+			writer:  mockWriter,
+			service: "test-service",
+		}
+
+		child := logger.With(map[string]any{"request_id": "req-1", "user_id": 1})
+		fields := map[string]any{"user_id": 2, "action": "login"}
+		checkNoError(t, child.Info("child event", &fields))
+
+		logEvent := assertLogEvent(t, mockWriter, INFO, "child event", "test-service")
+
+		if logEvent.Fields["request_id"] != "req-1" {
+			t.Errorf("Expected request_id 'req-1', got %v", logEvent.Fields["request_id"])
+		}
+		if logEvent.Fields["user_id"] != float64(2) {
+			t.Errorf("Expected call fields to win, got user_id %v", logEvent.Fields["user_id"])
+		}
+		if logEvent.Fields["action"] != "login" {
+			t.Errorf("Expected action 'login', got %v", logEvent.Fields["action"])
+		}
+		if len(fields) != 2 {
+			t.Errorf("Expected caller's fields map to be left untouched, got %v", fields)
+		}
+	})
+
+	t.Run("Parent is unaffected by child", func(t *testing.T) {
+		mockWriter := &mocks.MockMessageWriter{}
+		logger := &KafkaLogger{
+			writer:  mockWriter,
+			service: "test-service",
+		}
+
+		parent := logger.With(map[string]any{"component": "db"})
+		parent.With(map[string]any{"query": "select"})
+		checkNoError(t, parent.Warn("parent event", nil))
+
+		logEvent := assertLogEvent(t, mockWriter, WARN, "parent event", "test-service")
+
+		if _, ok := logEvent.Fields["query"]; ok {
+			t.Errorf("Expected parent not to carry child fields, got %v", logEvent.Fields)
+		}
+		if logEvent.Fields["component"] != "db" {
+			t.Errorf("Expected component 'db', got %v", logEvent.Fields["component"])
+		}
+	})
+}
+
+func TestContextLogging(t *testing.T) {
+	RegisterContextKey("tenant", tenantKey{})
+
+	t.Run("Registered keys become fields", func(t *testing.T) {
+		mockWriter := &mocks.MockMessageWriter{}
+		logger := &KafkaLogger{
+			writer:  mockWriter,
+			service: "test-service",
+		}
+
+		ctx := ContextWithTraceID(context.Background(), "trace-abc")
+		ctx = ContextWithSpanID(ctx, "span-123")
+		ctx = context.WithValue(ctx, tenantKey{}, "acme")
+
+		checkNoError(t, logger.ErrorContext(ctx, "with context", nil))
+
+		logEvent := assertLogEvent(t, mockWriter, ERROR, "with context", "test-service")
+
+		expected := map[string]any{"trace_id": "trace-abc", "span_id": "span-123", "tenant": "acme"}
+		for key, value := range expected {
+			if logEvent.Fields[key] != value {
+				t.Errorf("Expected %s '%v', got %v", key, value, logEvent.Fields[key])
+			}
+		}
+	})
+
+	t.Run("Context is passed to the writer", func(t *testing.T) {
+		var received context.Context
+		mockWriter := &mocks.MockMessageWriter{
+			WriteFunc: func(ctx context.Context, msgs ...kafka.Message) error {
+				received = ctx
+				return nil
+			},
+		}
+		logger := &KafkaLogger{
+			writer:  mockWriter,
+			service: "test-service",
+		}
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		checkNoError(t, logger.DebugContext(ctx, "ctx passed through", nil))
+
+		if received != ctx {
+			t.Error("Expected caller's context to be passed to WriteMessages")
+		}
+	})
+
+	t.Run("No context values keeps fields nil", func(t *testing.T) {
+		mockWriter := &mocks.MockMessageWriter{}
+		logger := &KafkaLogger{
+			writer:  mockWriter,
+			service: "test-service",
+		}
+
+		checkNoError(t, logger.InfoContext(context.Background(), "plain", nil))
+
+		logEvent := assertLogEvent(t, mockWriter, INFO, "plain", "test-service")
+		if logEvent.Fields != nil {
+			t.Errorf("Expected nil fields, got %v", logEvent.Fields)
+		}
+	})
+}
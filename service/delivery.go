@@ -0,0 +1,113 @@
+package service
+
+import (
+	"errors"
+	"kafka-logger/producer"
+	"sync/atomic"
+
+	"github.com/segmentio/kafka-go"
+)
+
+// NewKafkaLoggerWithSettings is NewKafkaLogger with delivery settings for the
+// underlying writer, such as the acknowledgement profile, batching,
+// compression and retries. In settings.Async mode, events the writer gives up
+// on are spooled if a spool is configured, and otherwise reported to the
+// WithCompletion callback and counted as dropped. A schema registry combined
+// with a non-JSON encoder is rejected.
+func NewKafkaLoggerWithSettings(brokers []string, topic, serviceName string, settings producer.Settings, opts ...Option) (*KafkaLogger, error) {
+	var o loggerOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+	if o.registry != nil {
+		if err := CheckRegistryEncoder(o.encoder); err != nil {
+			return nil, err
+		}
+	}
+	return newKafkaLoggerWithSettings(brokers, topic, serviceName, settings, opts...)
+}
+
+func newKafkaLoggerWithSettings(brokers []string, topic, serviceName string, settings producer.Settings, opts ...Option) (*KafkaLogger, error) {
+	var o loggerOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	userCompletion := settings.Completion
+	settings.Completion = nil
+	writer, err := producer.NewProducerWithSettings(brokers, topic, settings)
+	if err != nil {
+		return nil, err
+	}
+	if o.balancer != nil {
+		writer.Balancer = o.balancer
+	}
+
+	kl := newKafkaLogger(writer, serviceName, opts...)
+	if settings.Async {
+		kl.delivery = &deliveryReporter{spool: kl.spool, completion: o.completion}
+		writer.Completion = func(messages []kafka.Message, err error) {
+			if userCompletion != nil {
+				userCompletion(messages, err)
+			}
+			if err != nil {
+				kl.delivery.failed(messages, err)
+			}
+		}
+	} else {
+		writer.Completion = userCompletion
+	}
+	return kl, nil
+}
+
+// WithCompletion sets a callback for events that were accepted by the logger
+// but could not be delivered, in async mode or with an async writer. Events
+// that are spooled are not reported, since they will be retried.
+func WithCompletion(completion func(failed []LogEvent, err error)) Option {
+	return func(o *loggerOptions) {
+		o.completion = completion
+	}
+}
+
+// deliveryReporter handles write failures reported after WriteMessages has
+// returned, as with an async kafka.Writer.
+type deliveryReporter struct {
+	spool      *diskSpool
+	completion func(failed []LogEvent, err error)
+	dropped    atomic.Uint64
+}
+
+func (r *deliveryReporter) failed(messages []kafka.Message, err error) {
+	if r.spool != nil {
+		spoolErr := r.spool.append(messages...)
+		if spoolErr == nil {
+			return
+		}
+		err = errors.Join(err, spoolErr)
+	}
+	r.dropped.Add(uint64(len(messages)))
+	if r.completion != nil {
+		r.completion(decodeFailed(messages), err)
+	} else {
+		internalLog.Printf("Failed to deliver %d log events: %v", len(messages), err)
+	}
+	reportFailedLines(messages, err)
+}
+
+// decodeFailed turns undelivered messages back into events for reporting.
+// Messages that cannot be decoded are reported with only their metadata.
+func decodeFailed(messages []kafka.Message) []LogEvent {
+	events := make([]LogEvent, 0, len(messages))
+	for _, message := range messages {
+		if _, payload, err := DecodeWireFormat(message.Value); err == nil {
+			message.Value = payload
+		}
+		event, err := DecodeMessage(message)
+		if err != nil {
+			meta, _ := MetadataFromMessage(message)
+			event = LogEvent{Timestamp: meta.Time, Level: meta.Level, Service: meta.Service}
+		}
+		events = append(events, event)
+	}
+	return events
+}
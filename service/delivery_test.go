@@ -0,0 +1,158 @@
+package service
+
+import (
+	"errors"
+	"kafka-logger/mocks"
+	"kafka-logger/producer"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+)
+
+type failedEvents struct {
+	mu     sync.Mutex
+	events []LogEvent
+	err    error
+}
+
+func (f *failedEvents) record(failed []LogEvent, err error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.events = append(f.events, failed...)
+	f.err = err
+}
+
+func (f *failedEvents) messages() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	messages := make([]string, len(f.events))
+	for i, event := range f.events {
+		messages[i] = event.Message
+	}
+	return messages
+}
+
+func TestCompletionCallback(t *testing.T) {
+	t.Run("Async queue reports dropped events", func(t *testing.T) {
+		t.Parallel()
+		var failed failedEvents
+		mockWriter := &mocks.MockMessageWriter{WriteErr: errors.New("broker unreachable")}
+		logger := newKafkaLogger(mockWriter, "test-service",
+			WithAsync(AsyncConfig{BatchSize: 2, OnError: func(error, []kafka.Message) {}}),
+			WithCompletion(failed.record))
+
+		checkNoError(t, logger.Info("first", nil))
+		checkNoError(t, logger.Warn("second", nil))
+
+		if err := logger.Close(); !errors.Is(err, ErrEventDropped) {
+			t.Errorf("Expected ErrEventDropped, got: %v", err)
+		}
+
+		got := failed.messages()
+		if len(got) != 2 || got[0] != "first" || got[1] != "second" {
+			t.Errorf("Expected both events to be reported, got %v", got)
+		}
+		if failed.err == nil || failed.err.Error() != "broker unreachable" {
+			t.Errorf("Expected the write error, got %v", failed.err)
+		}
+	})
+
+	t.Run("Spooled events are not reported", func(t *testing.T) {
+		t.Parallel()
+		var failed failedEvents
+		mockWriter := &mocks.MockMessageWriter{WriteErr: errors.New("broker unreachable")}
+		logger := newKafkaLogger(mockWriter, "test-service",
+			WithAsync(AsyncConfig{}),
+			WithSpool(SpoolConfig{Dir: t.TempDir(), ReplayInterval: time.Hour}),
+			WithCompletion(failed.record))
+
+		checkNoError(t, logger.Info("spooled", nil))
+		checkNoError(t, logger.Close())
+
+		if got := failed.messages(); len(got) != 0 {
+			t.Errorf("Expected no failures, got %v", got)
+		}
+	})
+
+	t.Run("Failures reported while closing the writer are spooled", func(t *testing.T) {
+		t.Parallel()
+		var failed failedEvents
+		dir := t.TempDir()
+		mockWriter := &mocks.MockMessageWriter{}
+		logger := newKafkaLogger(mockWriter, "test-service",
+			WithSpool(SpoolConfig{Dir: dir, ReplayInterval: time.Hour}),
+			WithCompletion(failed.record))
+		logger.delivery = &deliveryReporter{spool: logger.spool, completion: failed.record}
+
+		checkNoError(t, logger.Info("last batch", nil))
+		checkNoError(t, logger.Info("after close", nil))
+		messages := mockWriter.Written()
+		mockWriter.CloseFunc = func() error {
+			logger.delivery.failed(messages[:1], errors.New("broker unreachable"))
+			return nil
+		}
+
+		checkNoError(t, logger.Close())
+		logger.delivery.failed(messages[1:], errors.New("broker unreachable"))
+
+		segments, _ := filepath.Glob(filepath.Join(dir, "*"+spoolSuffix))
+		if len(segments) != 1 {
+			t.Fatalf("Expected 1 spool segment, got %d", len(segments))
+		}
+		if records, _ := readSpoolSegment(segments[0]); len(records) != 1 {
+			t.Errorf("Expected the failure during Close to be spooled, got %d records", len(records))
+		}
+		if logger.spool.active != nil {
+			t.Error("Expected no segment to be reopened after Close")
+		}
+		if got := failed.messages(); len(got) != 1 || got[0] != "after close" {
+			t.Errorf("Expected the failure after Close to be reported, got %v", got)
+		}
+		if logger.Dropped() != 1 {
+			t.Errorf("Expected 1 dropped event, got %d", logger.Dropped())
+		}
+	})
+
+	t.Run("Async writer reports failed events", func(t *testing.T) {
+		t.Parallel()
+		var failed failedEvents
+		logger, err := NewKafkaLoggerWithSettings([]string{"localhost:9092"}, "test-topic", "test-service", producer.Settings{
+			Delivery: producer.AllISR,
+			Async:    true,
+		}, WithCompletion(failed.record), WithEncoder(MsgpackCodec{}))
+		checkNoError(t, err)
+		defer logger.Close()
+
+		writer := logger.writer.(*kafka.Writer)
+		if writer.RequiredAcks != kafka.RequireAll || !writer.Async {
+			t.Errorf("Expected an async all-ISR writer, got acks %v async %v", writer.RequiredAcks, writer.Async)
+		}
+
+		// Stand in for the writer, which calls Completion once the broker
+		// has answered.
+		mockWriter := &mocks.MockMessageWriter{}
+		checkNoError(t, newKafkaLogger(mockWriter, "test-service", WithEncoder(MsgpackCodec{})).Error("never delivered", nil))
+		writer.Completion(mockWriter.Messages, nil)
+		writer.Completion(mockWriter.Messages, errors.New("not enough replicas"))
+
+		if got := failed.messages(); len(got) != 1 || got[0] != "never delivered" {
+			t.Errorf("Expected the event to be reported once, got %v", got)
+		}
+		if logger.Dropped() != 1 {
+			t.Errorf("Expected 1 dropped event, got %d", logger.Dropped())
+		}
+	})
+
+	t.Run("Invalid settings", func(t *testing.T) {
+		t.Parallel()
+		_, err := NewKafkaLoggerWithSettings([]string{"localhost:9092"}, "test-topic", "test-service", producer.Settings{
+			Delivery: "exactly-twice",
+		})
+		if err == nil {
+			t.Error("Expected error for unknown delivery profile")
+		}
+	})
+}
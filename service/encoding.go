@@ -0,0 +1,145 @@
+package service
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+
+	"github.com/segmentio/kafka-go"
+)
+
+// ContentTypeHeader is the Kafka header naming the encoding of a LogEvent.
+// Messages without it are treated as JSON, which is what older producers wrote.
+const ContentTypeHeader = "content-type"
+
+const (
+	ContentTypeJSON    = "application/json"
+	ContentTypeLogfmt  = "text/logfmt"
+	ContentTypeMsgpack = "application/msgpack"
+)
+
+type Encoder interface {
+	ContentType() string
+	Encode(event LogEvent) ([]byte, error)
+}
+
+type Decoder interface {
+	ContentType() string
+	Decode(data []byte, event *LogEvent) error
+}
+
+type Codec interface {
+	Encoder
+	Decoder
+}
+
+var (
+	codecsMutex sync.RWMutex
+	codecs      = map[string]Codec{}
+)
+
+func init() {
+	RegisterCodec(JSONCodec{})
+	RegisterCodec(LogfmtCodec{})
+	RegisterCodec(MsgpackCodec{})
+}
+
+// RegisterCodec makes a codec available to consumers by its content type,
+// replacing any codec previously registered for it.
+func RegisterCodec(codec Codec) {
+	codecsMutex.Lock()
+	defer codecsMutex.Unlock()
+	codecs[codec.ContentType()] = codec
+}
+
+func LookupCodec(contentType string) (Codec, bool) {
+	codecsMutex.RLock()
+	defer codecsMutex.RUnlock()
+	codec, ok := codecs[contentType]
+	return codec, ok
+}
+
+var codecNames = map[string]string{
+	"json":    ContentTypeJSON,
+	"logfmt":  ContentTypeLogfmt,
+	"msgpack": ContentTypeMsgpack,
+}
+
+// CodecByName resolves a short name such as "msgpack", or a content type, to
+// a registered codec. It is meant for configuration files.
+func CodecByName(name string) (Codec, error) {
+	contentType := name
+	if full, ok := codecNames[strings.ToLower(name)]; ok {
+		contentType = full
+	}
+	codec, ok := LookupCodec(contentType)
+	if !ok {
+		return nil, fmt.Errorf("unknown encoding %q", name)
+	}
+	return codec, nil
+}
+
+// WithEncoder sets the encoding used for published events. Defaults to JSON.
+func WithEncoder(encoder Encoder) Option {
+	return func(o *loggerOptions) {
+		o.encoder = encoder
+	}
+}
+
+// HeaderValue returns the value of the first header named key.
+func HeaderValue(headers []kafka.Header, key string) (string, bool) {
+	for _, header := range headers {
+		if header.Key == key {
+			return string(header.Value), true
+		}
+	}
+	return "", false
+}
+
+// DecodeMessage decodes a LogEvent with the codec named by the message's
+// content-type header, so topics holding several encodings can be read.
+func DecodeMessage(message kafka.Message) (LogEvent, error) {
+	contentType, ok := HeaderValue(message.Headers, ContentTypeHeader)
+	if !ok {
+		contentType = ContentTypeJSON
+	}
+
+	codec, ok := LookupCodec(contentType)
+	if !ok {
+		return LogEvent{}, fmt.Errorf("no decoder registered for content type %q", contentType)
+	}
+
+	var event LogEvent
+	if err := codec.Decode(message.Value, &event); err != nil {
+		return LogEvent{}, err
+	}
+	return event, nil
+}
+
+type JSONCodec struct{}
+
+func (JSONCodec) ContentType() string {
+	return ContentTypeJSON
+}
+
+func (JSONCodec) Encode(event LogEvent) ([]byte, error) {
+	return json.Marshal(event)
+}
+
+// Decode reads numbers in fields as json.Number rather than float64, so
+// integers beyond 2^53 keep their exact value.
+func (JSONCodec) Decode(data []byte, event *LogEvent) error {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	*event = LogEvent{}
+	if err := decoder.Decode(event); err != nil {
+		return err
+	}
+	if _, err := decoder.Token(); err != io.EOF {
+		return fmt.Errorf("invalid character after top-level value")
+	}
+	return nil
+}
@@ -0,0 +1,278 @@
+package service
+
+import (
+	"kafka-logger/mocks"
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+)
+
+func testEvent() LogEvent {
+	return LogEvent{
+		Timestamp: time.Date(2024, 1, 15, 10, 30, 45, 123456789, time.UTC),
+		Level:     WARN,
+		Message:   "disk almost full",
+		Service:   "storage",
+		Fields: map[string]any{
+			"free_bytes": int64(1 << 40),
+			"ratio":      0.97,
+			"mount":      "/var/lib data",
+			"healthy":    false,
+			"disk": map[string]any{
+				"id": "sda",
+			},
+		},
+		Caller: &Caller{File: "/src/app/disk.go", Line: 42, Function: "main.checkDisk"},
+		Stack:  "main.checkDisk\n\t/src/app/disk.go:42\nmain.main\n\t/src/app/main.go:10\n",
+
+		Error: &ErrorInfo{
+			Message: "check disk: no space left on device",
+			Type:    "*fmt.wrapError",
+			Causes: []*ErrorInfo{
+				{Message: "no space left on device", Type: "syscall.Errno", Stack: "main.write\n\t/src/app/disk.go:12\n"},
+			},
+		},
+
+		ID:         "01890a5d-ac96-774b-bcce-b302099a8057",
+		ProducerID: "01890a5d-ac90-7000-8000-000000000001",
+		Sequence:   1 << 33,
+	}
+}
+
+func TestCodecRoundTrip(t *testing.T) {
+	for _, codec := range []Codec{JSONCodec{}, LogfmtCodec{}, MsgpackCodec{}} {
+		t.Run(codec.ContentType(), func(t *testing.T) {
+			event := testEvent()
+
+			data, err := codec.Encode(event)
+			if err != nil {
+				t.Fatalf("Failed to encode: %v", err)
+			}
+
+			var decoded LogEvent
+			if err := codec.Decode(data, &decoded); err != nil {
+				t.Fatalf("Failed to decode: %v", err)
+			}
+
+			if !decoded.Timestamp.Equal(event.Timestamp) {
+				t.Errorf("Expected timestamp %v, got %v", event.Timestamp, decoded.Timestamp)
+			}
+			if decoded.Level != event.Level || decoded.Message != event.Message || decoded.Service != event.Service {
+				t.Errorf("Expected %+v, got %+v", event, decoded)
+			}
+			if decoded.Fields["mount"] != "/var/lib data" || decoded.Fields["healthy"] != false {
+				t.Errorf("Unexpected fields %v", decoded.Fields)
+			}
+			disk, ok := decoded.Fields["disk"].(map[string]any)
+			if !ok || disk["id"] != "sda" {
+				t.Errorf("Expected nested disk.id 'sda', got %v", decoded.Fields["disk"])
+			}
+			if decoded.Caller == nil || *decoded.Caller != *event.Caller {
+				t.Errorf("Expected caller %+v, got %+v", event.Caller, decoded.Caller)
+			}
+			if decoded.Stack != event.Stack {
+				t.Errorf("Expected stack %q, got %q", event.Stack, decoded.Stack)
+			}
+			if !reflect.DeepEqual(decoded.Error, event.Error) {
+				t.Errorf("Expected error %+v, got %+v", event.Error, decoded.Error)
+			}
+			if decoded.ID != event.ID || decoded.ProducerID != event.ProducerID || decoded.Sequence != event.Sequence {
+				t.Errorf("Expected ID %s from %s #%d, got %s from %s #%d",
+					event.ID, event.ProducerID, event.Sequence, decoded.ID, decoded.ProducerID, decoded.Sequence)
+			}
+		})
+	}
+}
+
+func TestLogfmtCodec(t *testing.T) {
+	t.Run("Output format", func(t *testing.T) {
+		event := LogEvent{
+			Timestamp: time.Date(2024, 1, 15, 10, 30, 45, 0, time.UTC),
+			Level:     INFO,
+			Message:   "user logged in",
+			Service:   "auth",
+			Fields: map[string]any{
+				"user_id": 42,
+				"zip":     "01234",
+				"level":   "admin",
+			},
+		}
+
+		data, err := LogfmtCodec{}.Encode(event)
+		if err != nil {
+			t.Fatalf("Failed to encode: %v", err)
+		}
+
+		expected := `timestamp=2024-01-15T10:30:45Z level=INFO service=auth message="user logged in" fields.level=admin user_id=42 zip="01234"`
+		if string(data) != expected {
+			t.Errorf("Expected '%s', got '%s'", expected, string(data))
+		}
+
+		var decoded LogEvent
+		if err := (LogfmtCodec{}).Decode(data, &decoded); err != nil {
+			t.Fatalf("Failed to decode: %v", err)
+		}
+		if decoded.Level != INFO || decoded.Fields["level"] != "admin" {
+			t.Errorf("Expected reserved-name field to round trip, got %+v", decoded)
+		}
+		if decoded.Fields["zip"] != "01234" || decoded.Fields["user_id"] != int64(42) {
+			t.Errorf("Expected field types to be kept, got %v", decoded.Fields)
+		}
+	})
+
+	t.Run("Malformed input", func(t *testing.T) {
+		var decoded LogEvent
+		if err := (LogfmtCodec{}).Decode([]byte(`level=INFO message="unterminated`), &decoded); err == nil {
+			t.Error("Expected error for unterminated quote")
+		}
+		if err := (LogfmtCodec{}).Decode([]byte(`justakey`), &decoded); err == nil {
+			t.Error("Expected error for missing '='")
+		}
+	})
+}
+
+func TestMsgpackCodec(t *testing.T) {
+	t.Run("Preserves numeric types", func(t *testing.T) {
+		event := LogEvent{
+			Timestamp: time.Unix(1700000000, 5).UTC(),
+			Level:     DEBUG,
+			Service:   "numbers",
+			Fields: map[string]any{
+				"small":     7,
+				"negative":  -5,
+				"int8":      -100,
+				"int16":     -30000,
+				"int32":     -2000000000,
+				"max_int64": int64(math.MaxInt64),
+				"min_int64": int64(math.MinInt64),
+				"max_uint":  uint64(math.MaxUint64),
+				"float32":   float32(1.5),
+				"float":     3.25,
+				"bytes":     []byte{1, 2, 3},
+				"list":      []string{"a", "b"},
+				"when":      time.Date(2020, 2, 29, 12, 0, 0, 0, time.UTC),
+				"long":      strings.Repeat("x", 70000),
+				"null":      nil,
+			},
+		}
+
+		data, err := MsgpackCodec{}.Encode(event)
+		if err != nil {
+			t.Fatalf("Failed to encode: %v", err)
+		}
+
+		var decoded LogEvent
+		if err := (MsgpackCodec{}).Decode(data, &decoded); err != nil {
+			t.Fatalf("Failed to decode: %v", err)
+		}
+
+		expected := map[string]any{
+			"small":     int64(7),
+			"negative":  int64(-5),
+			"int8":      int64(-100),
+			"int16":     int64(-30000),
+			"int32":     int64(-2000000000),
+			"max_int64": int64(math.MaxInt64),
+			"min_int64": int64(math.MinInt64),
+			"max_uint":  uint64(math.MaxUint64),
+			"float32":   1.5,
+			"float":     3.25,
+			"bytes":     []byte{1, 2, 3},
+			"list":      []any{"a", "b"},
+			"when":      time.Date(2020, 2, 29, 12, 0, 0, 0, time.UTC),
+			"long":      strings.Repeat("x", 70000),
+			"null":      nil,
+		}
+		for key, value := range expected {
+			if !reflect.DeepEqual(decoded.Fields[key], value) {
+				t.Errorf("Expected %s to be %T(%v), got %T(%v)", key, value, value, decoded.Fields[key], decoded.Fields[key])
+			}
+		}
+		if !decoded.Timestamp.Equal(event.Timestamp) {
+			t.Errorf("Expected timestamp %v, got %v", event.Timestamp, decoded.Timestamp)
+		}
+	})
+
+	t.Run("Truncated input", func(t *testing.T) {
+		data, err := MsgpackCodec{}.Encode(testEvent())
+		if err != nil {
+			t.Fatalf("Failed to encode: %v", err)
+		}
+
+		var decoded LogEvent
+		if err := (MsgpackCodec{}).Decode(data[:len(data)-3], &decoded); err == nil {
+			t.Error("Expected error for truncated input")
+		}
+	})
+}
+
+func TestDecodeMessage(t *testing.T) {
+	t.Run("Mixed encodings on one topic", func(t *testing.T) {
+		mockWriter := &mocks.MockMessageWriter{}
+		jsonLogger := newKafkaLogger(mockWriter, "mixed")
+		msgpackLogger := newKafkaLogger(mockWriter, "mixed", WithEncoder(MsgpackCodec{}))
+
+		checkNoError(t, jsonLogger.Info("from json", nil))
+		checkNoError(t, msgpackLogger.Info("from msgpack", nil))
+
+		for i, expected := range []string{"from json", "from msgpack"} {
+			event, err := DecodeMessage(mockWriter.Messages[i])
+			if err != nil {
+				t.Fatalf("Failed to decode message %d: %v", i, err)
+			}
+			if event.Message != expected {
+				t.Errorf("Expected message '%s', got '%s'", expected, event.Message)
+			}
+		}
+
+		if contentType, _ := HeaderValue(mockWriter.Messages[1].Headers, ContentTypeHeader); contentType != ContentTypeMsgpack {
+			t.Errorf("Expected content-type %s, got %s", ContentTypeMsgpack, contentType)
+		}
+	})
+
+	t.Run("Missing header falls back to JSON", func(t *testing.T) {
+		event, err := DecodeMessage(kafka.Message{Value: []byte(`{"level":"INFO","message":"legacy"}`)})
+		if err != nil {
+			t.Fatalf("Expected no error, got: %v", err)
+		}
+		if event.Message != "legacy" {
+			t.Errorf("Expected message 'legacy', got '%s'", event.Message)
+		}
+	})
+
+	t.Run("Unknown content type", func(t *testing.T) {
+		_, err := DecodeMessage(kafka.Message{
+			Value:   []byte("?"),
+			Headers: []kafka.Header{{Key: ContentTypeHeader, Value: []byte("application/x-unknown")}},
+		})
+		if err == nil {
+			t.Error("Expected error for unknown content type")
+		}
+	})
+}
+
+func TestCodecByName(t *testing.T) {
+	for name, expected := range map[string]string{
+		"json":             ContentTypeJSON,
+		"MSGPACK":          ContentTypeMsgpack,
+		"logfmt":           ContentTypeLogfmt,
+		ContentTypeMsgpack: ContentTypeMsgpack,
+	} {
+		codec, err := CodecByName(name)
+		if err != nil {
+			t.Errorf("Expected codec for %q, got error: %v", name, err)
+			continue
+		}
+		if codec.ContentType() != expected {
+			t.Errorf("Expected %s for %q, got %s", expected, name, codec.ContentType())
+		}
+	}
+
+	if _, err := CodecByName("avro"); err == nil {
+		t.Error("Expected error for unregistered encoding")
+	}
+}
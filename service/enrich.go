@@ -0,0 +1,237 @@
+package service
+
+import (
+	"context"
+	"kafka-logger/config"
+	"os"
+	"path/filepath"
+	"runtime/debug"
+	"strconv"
+	"strings"
+)
+
+// Hook runs on every event before it is encoded, after bound and context
+// fields are merged and before redaction. It may add, change or remove
+// fields. Returning false vetoes the event, which is then not published.
+type Hook interface {
+	Enrich(ctx context.Context, event *LogEvent) bool
+}
+
+// HookFunc adapts a function to a Hook.
+type HookFunc func(ctx context.Context, event *LogEvent) bool
+
+func (f HookFunc) Enrich(ctx context.Context, event *LogEvent) bool {
+	return f(ctx, event)
+}
+
+// WithHooks appends hooks to the logger's chain. Hooks run in the order
+// they were added, and a veto stops the chain.
+func WithHooks(hooks ...Hook) Option {
+	return func(o *loggerOptions) {
+		o.hooks = append(o.hooks, hooks...)
+	}
+}
+
+// runHooks passes event through the hooks and reports whether it survived.
+// The fields are copied first, since they may still belong to the caller.
+func (kl *KafkaLogger) runHooks(ctx context.Context, event *LogEvent) bool {
+	if len(kl.hooks) == 0 {
+		return true
+	}
+	event.Fields = cloneFields(event.Fields)
+	if event.Fields == nil {
+		event.Fields = make(map[string]any)
+	}
+	for _, hook := range kl.hooks {
+		if !hook.Enrich(ctx, event) {
+			return false
+		}
+	}
+	if len(event.Fields) == 0 {
+		event.Fields = nil
+	}
+	return true
+}
+
+// staticHook adds fixed fields to every event. Fields the event already has
+// are kept, so a caller can override them per event.
+type staticHook map[string]any
+
+func (h staticHook) Enrich(_ context.Context, event *LogEvent) bool {
+	for key, value := range h {
+		if _, ok := event.Fields[key]; !ok {
+			event.Fields[key] = value
+		}
+	}
+	return true
+}
+
+// StaticHook adds the given fields to every event that does not set them.
+func StaticHook(fields map[string]any) Hook {
+	return staticHook(cloneFields(fields))
+}
+
+// HostHook adds the machine's hostname as "host".
+func HostHook() Hook {
+	hostname, err := os.Hostname()
+	if err != nil {
+		return staticHook{}
+	}
+	return staticHook{"host": hostname}
+}
+
+// ProcessHook adds the process ID as "pid" and the executable name as
+// "process".
+func ProcessHook() Hook {
+	return staticHook{
+		"pid":     os.Getpid(),
+		"process": filepath.Base(os.Args[0]),
+	}
+}
+
+// BuildInfoHook adds the main module's "version", the VCS revision as
+// "git_sha" and the toolchain as "go_version", from the build information
+// embedded in the binary. The revision is only recorded by go build in a
+// VCS checkout, and gets a "-dirty" suffix for uncommitted changes.
+func BuildInfoHook() Hook {
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return staticHook{}
+	}
+	return staticHook(buildInfoFields(info))
+}
+
+func buildInfoFields(info *debug.BuildInfo) map[string]any {
+	fields := map[string]any{"go_version": info.GoVersion}
+	if info.Main.Version != "" && info.Main.Version != "(devel)" {
+		fields["version"] = info.Main.Version
+	}
+
+	var revision string
+	var modified bool
+	for _, setting := range info.Settings {
+		switch setting.Key {
+		case "vcs.revision":
+			revision = setting.Value
+		case "vcs.modified":
+			modified = setting.Value == "true"
+		}
+	}
+	if revision != "" {
+		if modified {
+			revision += "-dirty"
+		}
+		fields["git_sha"] = revision
+	}
+	return fields
+}
+
+// ContainerConfig maps field names to where their values are read from.
+// Missing variables and files are skipped, so the same configuration works
+// inside and outside a cluster.
+type ContainerConfig struct {
+	// Env maps field names to environment variables, such as those set from
+	// the Kubernetes downward API with fieldRef.
+	Env map[string]string
+	// Files maps field names to files, such as those mounted by a downward
+	// API volume. Files in the labels and annotations format, with one
+	// key="value" per line, become nested fields.
+	Files map[string]string
+}
+
+// DefaultContainerConfig reads the pod, namespace and node from the
+// environment variables conventionally set from the downward API, and falls
+// back to the namespace file of the pod's service account.
+func DefaultContainerConfig() ContainerConfig {
+	return ContainerConfig{
+		Env: map[string]string{
+			"k8s_pod":       "POD_NAME",
+			"k8s_namespace": "POD_NAMESPACE",
+			"k8s_node":      "NODE_NAME",
+		},
+		Files: map[string]string{
+			"k8s_namespace": "/var/run/secrets/kubernetes.io/serviceaccount/namespace",
+		},
+	}
+}
+
+// ContainerHook adds container and Kubernetes metadata. The values are read
+// once, when the hook is created. A field set by both an environment
+// variable and a file takes the environment variable.
+func ContainerHook(cfg ContainerConfig) Hook {
+	fields := make(staticHook)
+	for field, file := range cfg.Files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			continue
+		}
+		if value := downwardAPIValue(string(data)); value != nil {
+			fields[field] = value
+		}
+	}
+	for field, name := range cfg.Env {
+		if value := os.Getenv(name); value != "" {
+			fields[field] = value
+		}
+	}
+	return fields
+}
+
+// downwardAPIValue reads a downward API file. Label and annotation files
+// hold key="value" lines and become a map; anything else is a plain string.
+func downwardAPIValue(content string) any {
+	content = strings.TrimSpace(content)
+	if content == "" {
+		return nil
+	}
+
+	pairs := make(map[string]any)
+	for _, line := range strings.Split(content, "\n") {
+		key, quoted, ok := strings.Cut(line, "=")
+		if !ok {
+			return content
+		}
+		value, err := strconv.Unquote(quoted)
+		if err != nil {
+			return content
+		}
+		pairs[key] = value
+	}
+	return pairs
+}
+
+// EnrichmentHooks builds the hooks enabled in cfg, in the order static
+// fields, host, process, build info, container.
+func EnrichmentHooks(cfg config.EnrichmentConfig) []Hook {
+	var hooks []Hook
+	if len(cfg.Static) > 0 {
+		static := make(map[string]any, len(cfg.Static))
+		for key, value := range cfg.Static {
+			static[key] = value
+		}
+		hooks = append(hooks, StaticHook(static))
+	}
+	if cfg.Host {
+		hooks = append(hooks, HostHook())
+	}
+	if cfg.Process {
+		hooks = append(hooks, ProcessHook())
+	}
+	if cfg.Build {
+		hooks = append(hooks, BuildInfoHook())
+	}
+	if cfg.Container || len(cfg.Env) > 0 || len(cfg.Files) > 0 {
+		container := ContainerConfig{Env: cfg.Env, Files: cfg.Files}
+		if cfg.Container {
+			container = DefaultContainerConfig()
+			for field, name := range cfg.Env {
+				container.Env[field] = name
+			}
+			for field, file := range cfg.Files {
+				container.Files[field] = file
+			}
+		}
+		hooks = append(hooks, ContainerHook(container))
+	}
+	return hooks
+}
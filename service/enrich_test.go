@@ -0,0 +1,153 @@
+package service
+
+import (
+	"context"
+	"kafka-logger/config"
+	"kafka-logger/mocks"
+	"os"
+	"path/filepath"
+	"runtime/debug"
+	"testing"
+)
+
+func TestHooks(t *testing.T) {
+	t.Run("Hooks add, change and veto", func(t *testing.T) {
+		mockWriter := &mocks.MockMessageWriter{}
+		logger := newKafkaLogger(mockWriter, "test-service",
+			WithHooks(
+				StaticHook(map[string]any{"environment": "staging", "region": "eu-west-1"}),
+				HookFunc(func(ctx context.Context, event *LogEvent) bool {
+					return event.Fields["health_check"] != true
+				}),
+				HookFunc(func(ctx context.Context, event *LogEvent) bool {
+					delete(event.Fields, "region")
+					event.Message = "[" + event.Service + "] " + event.Message
+					return true
+				}),
+			))
+
+		fields := map[string]any{"region": "us-east-1"}
+		checkNoError(t, logger.Info("served", &fields))
+		checkNoError(t, logger.InfoFields("probe", Bool("health_check", true)))
+
+		event := assertLogEvent(t, mockWriter, INFO, "[test-service] served", "test-service")
+		if event.Fields["environment"] != "staging" {
+			t.Errorf("Expected the static field, got %v", event.Fields)
+		}
+		if _, ok := event.Fields["region"]; ok {
+			t.Errorf("Expected the hook to remove region, got %v", event.Fields)
+		}
+		if fields["region"] != "us-east-1" {
+			t.Error("Expected the caller's fields to be left alone")
+		}
+	})
+
+	t.Run("Static fields do not override the event", func(t *testing.T) {
+		mockWriter := &mocks.MockMessageWriter{}
+		logger := newKafkaLogger(mockWriter, "test-service", WithHooks(StaticHook(map[string]any{"environment": "prod"})))
+
+		checkNoError(t, logger.With(map[string]any{"environment": "canary"}).Info("deployed", nil))
+
+		if env := decodeLogEvent(t, mockWriter.Messages[0]).Fields["environment"]; env != "canary" {
+			t.Errorf("Expected the bound field to win, got %v", env)
+		}
+	})
+
+	t.Run("Hook fields are redacted", func(t *testing.T) {
+		redactor, err := NewRedactor(config.RedactionConfig{Rules: []config.RedactionRule{{Field: "k8s_node", Action: "drop"}}})
+		checkNoError(t, err)
+		mockWriter := &mocks.MockMessageWriter{}
+		logger := newKafkaLogger(mockWriter, "test-service",
+			WithHooks(StaticHook(map[string]any{"k8s_node": "node-1"})),
+			WithRedactor(redactor))
+
+		checkNoError(t, logger.Info("scheduled", nil))
+
+		if _, ok := decodeLogEvent(t, mockWriter.Messages[0]).Fields["k8s_node"]; ok {
+			t.Error("Expected the hook's field to be redacted")
+		}
+	})
+
+	t.Run("Vetoed events are not buffered", func(t *testing.T) {
+		mockWriter := &mocks.MockMessageWriter{}
+		logger := newKafkaLogger(mockWriter, "test-service",
+			WithFlightRecorder(FlightRecorderConfig{}),
+			WithHooks(HookFunc(func(ctx context.Context, event *LogEvent) bool {
+				return event.Message != "noise"
+			})))
+		scope := logger.Scope(nil)
+
+		checkNoError(t, scope.Debug("noise", nil))
+		checkNoError(t, scope.Debug("context", nil))
+		checkNoError(t, scope.Error("failed", nil))
+		assertMessages(t, mockWriter, "context", "failed")
+	})
+}
+
+func TestBuiltinHooks(t *testing.T) {
+	t.Run("Host and process", func(t *testing.T) {
+		event := LogEvent{Fields: map[string]any{}}
+		HostHook().Enrich(context.Background(), &event)
+		ProcessHook().Enrich(context.Background(), &event)
+
+		hostname, _ := os.Hostname()
+		if event.Fields["host"] != hostname || event.Fields["pid"] != os.Getpid() || event.Fields["process"] == "" {
+			t.Errorf("Unexpected fields %v", event.Fields)
+		}
+	})
+
+	t.Run("Build info", func(t *testing.T) {
+		fields := buildInfoFields(&debug.BuildInfo{
+			GoVersion: "go1.25.0",
+			Main:      debug.Module{Path: "kafka-logger", Version: "v1.4.0"},
+			Settings: []debug.BuildSetting{
+				{Key: "vcs.revision", Value: "4bd47bd"},
+				{Key: "vcs.modified", Value: "true"},
+			},
+		})
+		if fields["version"] != "v1.4.0" || fields["git_sha"] != "4bd47bd-dirty" || fields["go_version"] != "go1.25.0" {
+			t.Errorf("Unexpected fields %v", fields)
+		}
+
+		fields = buildInfoFields(&debug.BuildInfo{GoVersion: "go1.25.0", Main: debug.Module{Version: "(devel)"}})
+		if _, ok := fields["version"]; ok {
+			t.Errorf("Expected no version for a development build, got %v", fields)
+		}
+	})
+
+	t.Run("Container metadata from env and downward API files", func(t *testing.T) {
+		dir := t.TempDir()
+		writeFile := func(name, content string) string {
+			path := filepath.Join(dir, name)
+			if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+				t.Fatal(err)
+			}
+			return path
+		}
+		t.Setenv("POD_NAME", "api-7d9f-x2x")
+		t.Setenv("POD_NAMESPACE", "")
+
+		hook := EnrichmentHooks(config.EnrichmentConfig{
+			Container: true,
+			Files: map[string]string{
+				"k8s_namespace": writeFile("namespace", "payments\n"),
+				"k8s_labels":    writeFile("labels", "app=\"api\"\ntier=\"backend\"\n"),
+				"missing":       filepath.Join(dir, "missing"),
+			},
+		})[0]
+
+		event := LogEvent{Fields: map[string]any{}}
+		hook.Enrich(context.Background(), &event)
+
+		if event.Fields["k8s_pod"] != "api-7d9f-x2x" || event.Fields["k8s_namespace"] != "payments" {
+			t.Errorf("Unexpected fields %v", event.Fields)
+		}
+		labels, ok := event.Fields["k8s_labels"].(map[string]any)
+		if !ok || labels["app"] != "api" || labels["tier"] != "backend" {
+			t.Errorf("Expected labels as a map, got %v", event.Fields["k8s_labels"])
+		}
+		if _, ok := event.Fields["missing"]; ok {
+			t.Error("Expected missing files to be skipped")
+		}
+	})
+}
@@ -0,0 +1,153 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"runtime"
+	"strings"
+)
+
+// maxErrorDepth bounds how far a chain of wrapped errors is followed.
+const maxErrorDepth = 32
+
+// ErrorInfo is the structured form of an error: its message, its dynamic
+// type, the stack it carries if any, and the errors it wraps. An error
+// wrapped with fmt.Errorf("%w") has one cause; errors.Join and multiple %w
+// verbs give several.
+type ErrorInfo struct {
+	Message string       `json:"message"`
+	Type    string       `json:"type"`
+	Stack   string       `json:"stack,omitempty"`
+	Causes  []*ErrorInfo `json:"causes,omitempty"`
+}
+
+// NewErrorInfo serializes err and the chain of errors it wraps. It returns
+// nil for a nil error.
+func NewErrorInfo(err error) *ErrorInfo {
+	return newErrorInfo(err, 0)
+}
+
+func newErrorInfo(err error, depth int) *ErrorInfo {
+	if err == nil {
+		return nil
+	}
+	info := &ErrorInfo{
+		Message: err.Error(),
+		Type:    fmt.Sprintf("%T", err),
+		Stack:   errorStack(err),
+	}
+	if depth >= maxErrorDepth {
+		return info
+	}
+
+	var causes []error
+	switch e := err.(type) {
+	case interface{ Unwrap() []error }:
+		causes = e.Unwrap()
+	case interface{ Unwrap() error }:
+		causes = []error{e.Unwrap()}
+	}
+	for _, cause := range causes {
+		if c := newErrorInfo(cause, depth+1); c != nil {
+			info.Causes = append(info.Causes, c)
+		}
+	}
+	return info
+}
+
+// RootCause follows the first cause down to an error that wraps nothing.
+func (e *ErrorInfo) RootCause() *ErrorInfo {
+	if e == nil {
+		return nil
+	}
+	for len(e.Causes) > 0 {
+		e = e.Causes[0]
+	}
+	return e
+}
+
+// errorStack returns the stack trace carried by err itself, not by the
+// errors it wraps. It recognizes a Callers() []uintptr method, a StackTrace
+// method returning program counters as in github.com/pkg/errors, and a
+// Stack() []byte method returning a formatted trace.
+func errorStack(err error) string {
+	if e, ok := err.(interface{ Callers() []uintptr }); ok {
+		return formatFrames(e.Callers())
+	}
+	if pcs, ok := reflectedStackTrace(err); ok {
+		return formatFrames(pcs)
+	}
+	if e, ok := err.(interface{ Stack() []byte }); ok {
+		return string(e.Stack())
+	}
+	return ""
+}
+
+// reflectedStackTrace calls a StackTrace method whose result is a slice of
+// an integer type holding program counters, without importing the package
+// that defines the type.
+func reflectedStackTrace(err error) ([]uintptr, bool) {
+	method := reflect.ValueOf(err).MethodByName("StackTrace")
+	if !method.IsValid() || method.Type().NumIn() != 0 || method.Type().NumOut() != 1 {
+		return nil, false
+	}
+	out := method.Type().Out(0)
+	if out.Kind() != reflect.Slice || out.Elem().Kind() != reflect.Uintptr {
+		return nil, false
+	}
+
+	frames := method.Call(nil)[0]
+	pcs := make([]uintptr, frames.Len())
+	for i := range pcs {
+		pcs[i] = uintptr(frames.Index(i).Uint())
+	}
+	return pcs, true
+}
+
+// formatFrames formats program counters the way stackFrom does.
+func formatFrames(pcs []uintptr) string {
+	if len(pcs) == 0 {
+		return ""
+	}
+	var sb strings.Builder
+	frames := runtime.CallersFrames(pcs)
+	for {
+		frame, more := frames.Next()
+		writeFrame(&sb, frame)
+		if !more {
+			break
+		}
+	}
+	return sb.String()
+}
+
+// extractError moves an error value stored under the "error" field into the
+// event's structured Error. Fields are copied before the key is removed,
+// since they may belong to the caller.
+func extractError(event *LogEvent) {
+	err, ok := event.Fields["error"].(error)
+	if !ok {
+		return
+	}
+	if event.Error == nil {
+		event.Error = NewErrorInfo(err)
+	}
+	fields := cloneFields(event.Fields)
+	delete(fields, "error")
+	if len(fields) == 0 {
+		fields = nil
+	}
+	event.Fields = fields
+}
+
+// LogError logs err as the event's structured error, with its wrapped chain
+// and any stack it carries.
+func (kl *KafkaLogger) LogError(ctx context.Context, level LogLevel, message string, err error, fields ...Field) error {
+	return kl.logFields(ctx, level, message, append(fields[:len(fields):len(fields)], Err(err)))
+}
+
+// ErrorErr logs err at ERROR as the event's structured error.
+func (kl *KafkaLogger) ErrorErr(message string, err error, fields ...Field) error {
+	return kl.logFields(context.Background(), ERROR, message, append(fields[:len(fields):len(fields)], Err(err)))
+}
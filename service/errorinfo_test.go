@@ -0,0 +1,135 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"kafka-logger/config"
+	"kafka-logger/mocks"
+	"log/slog"
+	"os"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+// tracedError records where it was created, like errors from
+// github.com/pkg/errors.
+type tracedError struct {
+	msg string
+	pcs []uintptr
+}
+
+type stackTrace []frame
+
+type frame uintptr
+
+func newTracedError(msg string) error {
+	pcs := make([]uintptr, 8)
+	n := runtime.Callers(2, pcs)
+	return &tracedError{msg: msg, pcs: pcs[:n]}
+}
+
+func (e *tracedError) Error() string { return e.msg }
+
+func (e *tracedError) StackTrace() stackTrace {
+	frames := make(stackTrace, len(e.pcs))
+	for i, pc := range e.pcs {
+		frames[i] = frame(pc)
+	}
+	return frames
+}
+
+func TestNewErrorInfo(t *testing.T) {
+	t.Run("Wrapped and joined chain", func(t *testing.T) {
+		_, statErr := os.Stat("/does/not/exist")
+		err := fmt.Errorf("load config: %w", errors.Join(statErr, errors.New("fallback failed")))
+
+		info := NewErrorInfo(err)
+		if info.Type != "*fmt.wrapError" || info.Message != err.Error() {
+			t.Errorf("Unexpected top-level error %+v", info)
+		}
+		if len(info.Causes) != 1 || info.Causes[0].Type != "*errors.joinError" || len(info.Causes[0].Causes) != 2 {
+			t.Fatalf("Expected a join of two errors under the wrapper, got %+v", info.Causes)
+		}
+
+		root := info.RootCause()
+		if root.Type != "syscall.Errno" {
+			t.Errorf("Expected the root cause to be the errno under *fs.PathError, got %s", root.Type)
+		}
+		if info.Causes[0].Causes[0].Type != fmt.Sprintf("%T", &fs.PathError{}) {
+			t.Errorf("Expected *fs.PathError, got %s", info.Causes[0].Causes[0].Type)
+		}
+		if NewErrorInfo(nil) != nil {
+			t.Error("Expected nil for a nil error")
+		}
+	})
+
+	t.Run("Stacks carried by errors", func(t *testing.T) {
+		info := NewErrorInfo(fmt.Errorf("wrapped: %w", newTracedError("boom")))
+		if info.Stack != "" {
+			t.Errorf("Expected no stack on the wrapper, got %s", info.Stack)
+		}
+		if first, _, _ := strings.Cut(info.Causes[0].Stack, "\n"); !strings.HasSuffix(first, "TestNewErrorInfo.func2") {
+			t.Errorf("Expected the stack to start where the error was created, got:\n%s", info.Causes[0].Stack)
+		}
+	})
+}
+
+func TestLogError(t *testing.T) {
+	t.Run("Error-aware methods", func(t *testing.T) {
+		mockWriter := &mocks.MockMessageWriter{}
+		logger := newKafkaLogger(mockWriter, "test-service")
+		cause := errors.New("connection refused")
+
+		checkNoError(t, logger.ErrorErr("save failed", fmt.Errorf("save order: %w", cause), String("order", "o-1")))
+		checkNoError(t, logger.LogError(context.Background(), WARN, "retrying", cause))
+
+		event := decodeLogEvent(t, mockWriter.Messages[0])
+		if event.Error == nil || event.Error.RootCause().Message != "connection refused" {
+			t.Fatalf("Expected the structured error chain, got %+v", event.Error)
+		}
+		if event.Fields["order"] != "o-1" || event.Fields["error"] != nil {
+			t.Errorf("Expected the error to leave the fields, got %v", event.Fields)
+		}
+		assertCaller(t, event.Caller, "errorinfo_test.go", "TestLogError.func1")
+
+		if decodeLogEvent(t, mockWriter.Messages[1]).Error.Type != "*errors.errorString" {
+			t.Error("Expected the WARN event to carry the error")
+		}
+	})
+
+	t.Run("Error values in field maps and slog", func(t *testing.T) {
+		mockWriter := &mocks.MockMessageWriter{}
+		logger := newKafkaLogger(mockWriter, "test-service")
+
+		fields := map[string]any{"error": errors.New("from a map"), "db": "orders"}
+		checkNoError(t, logger.Error("failed", &fields))
+		slog.New(NewSlogHandler(logger, nil)).Error("from slog", "err", errors.New("from slog"))
+
+		if event := decodeLogEvent(t, mockWriter.Messages[0]); event.Error.Message != "from a map" || event.Fields["db"] != "orders" {
+			t.Errorf("Unexpected event %+v", event)
+		}
+		if _, ok := fields["error"]; !ok {
+			t.Error("Expected the caller's map to be left alone")
+		}
+		if decodeLogEvent(t, mockWriter.Messages[1]).Error.Message != "from slog" {
+			t.Error("Expected the slog error to be structured")
+		}
+	})
+
+	t.Run("Error messages are redacted", func(t *testing.T) {
+		redactor, err := NewRedactor(config.RedactionConfig{Rules: []config.RedactionRule{{Value: "email"}}})
+		checkNoError(t, err)
+		mockWriter := &mocks.MockMessageWriter{}
+		logger := newKafkaLogger(mockWriter, "test-service", WithRedactor(redactor))
+
+		checkNoError(t, logger.ErrorErr("signup failed", fmt.Errorf("notify: %w", errors.New("bounce from alice@example.com"))))
+
+		info := decodeLogEvent(t, mockWriter.Messages[0]).Error
+		if strings.Contains(info.Message, "alice") || strings.Contains(info.RootCause().Message, "alice") {
+			t.Errorf("Expected addresses to be masked, got %+v", info)
+		}
+	})
+}
@@ -0,0 +1,147 @@
+package service
+
+import (
+	"context"
+	"math"
+	"time"
+)
+
+type fieldKind uint8
+
+const (
+	anyField fieldKind = iota
+	stringField
+	int64Field
+	float64Field
+	boolField
+	durationField
+	timeField
+	errorField
+)
+
+// Field is a typed key/value pair for the *Fields logging methods. Scalars
+// are stored unboxed, so building fields does not allocate.
+type Field struct {
+	Key     string
+	kind    fieldKind
+	integer int64
+	str     string
+	value   any
+}
+
+func String(key, value string) Field {
+	return Field{Key: key, kind: stringField, str: value}
+}
+
+// Int64 keeps the exact value; consumers decode it without going through
+// float64, so large IDs are not rounded.
+func Int64(key string, value int64) Field {
+	return Field{Key: key, kind: int64Field, integer: value}
+}
+
+func Int(key string, value int) Field {
+	return Int64(key, int64(value))
+}
+
+func Float64(key string, value float64) Field {
+	return Field{Key: key, kind: float64Field, integer: int64(math.Float64bits(value))}
+}
+
+func Bool(key string, value bool) Field {
+	var b int64
+	if value {
+		b = 1
+	}
+	return Field{Key: key, kind: boolField, integer: b}
+}
+
+// Duration is published in time.Duration.String form, such as "1.5s", which
+// time.ParseDuration reads back exactly.
+func Duration(key string, value time.Duration) Field {
+	return Field{Key: key, kind: durationField, integer: int64(value)}
+}
+
+// Time is published in RFC 3339 with nanoseconds and its original offset.
+func Time(key string, value time.Time) Field {
+	return Field{Key: key, kind: timeField, value: value}
+}
+
+// Err sets err as the event's structured Error, with its wrapped chain and
+// any stack it carries. Value returns the error message. A nil error adds
+// nothing.
+func Err(err error) Field {
+	if err == nil {
+		return Field{}
+	}
+	return Field{Key: "error", kind: errorField, value: err}
+}
+
+// Any adds a value of any type, encoded as the logger's encoder sees fit.
+func Any(key string, value any) Field {
+	return Field{Key: key, kind: anyField, value: value}
+}
+
+// Value returns the field's value as it is placed in LogEvent.Fields.
+func (f Field) Value() any {
+	switch f.kind {
+	case stringField:
+		return f.str
+	case int64Field:
+		return f.integer
+	case float64Field:
+		return math.Float64frombits(uint64(f.integer))
+	case boolField:
+		return f.integer == 1
+	case durationField:
+		return time.Duration(f.integer).String()
+	case errorField:
+		return f.value.(error).Error()
+	default:
+		return f.value
+	}
+}
+
+func fieldsMap(fields []Field) map[string]any {
+	if len(fields) == 0 {
+		return nil
+	}
+	m := make(map[string]any, len(fields))
+	for _, f := range fields {
+		if f.Key == "" {
+			continue
+		}
+		if f.kind == errorField {
+			// Kept as an error so it becomes the event's structured Error.
+			m[f.Key] = f.value
+			continue
+		}
+		m[f.Key] = f.Value()
+	}
+	return m
+}
+
+// WithFields is With for typed fields.
+func (kl *KafkaLogger) WithFields(fields ...Field) *KafkaLogger {
+	return kl.With(fieldsMap(fields))
+}
+
+// LogFields logs an event with typed fields at the given level.
+func (kl *KafkaLogger) LogFields(ctx context.Context, level LogLevel, message string, fields ...Field) error {
+	return kl.logFields(ctx, level, message, fields)
+}
+
+func (kl *KafkaLogger) InfoFields(message string, fields ...Field) error {
+	return kl.logFields(context.Background(), INFO, message, fields)
+}
+
+func (kl *KafkaLogger) WarnFields(message string, fields ...Field) error {
+	return kl.logFields(context.Background(), WARN, message, fields)
+}
+
+func (kl *KafkaLogger) ErrorFields(message string, fields ...Field) error {
+	return kl.logFields(context.Background(), ERROR, message, fields)
+}
+
+func (kl *KafkaLogger) DebugFields(message string, fields ...Field) error {
+	return kl.logFields(context.Background(), DEBUG, message, fields)
+}
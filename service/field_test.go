@@ -0,0 +1,132 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"kafka-logger/mocks"
+	"math"
+	"testing"
+	"time"
+)
+
+func TestFieldValues(t *testing.T) {
+	when := time.Date(2024, 1, 15, 10, 30, 45, 123456789, time.FixedZone("CET", 3600))
+
+	tests := []struct {
+		field    Field
+		expected any
+	}{
+		{String("user", "alice"), "alice"},
+		{Int64("id", math.MaxInt64), int64(math.MaxInt64)},
+		{Int("count", 3), int64(3)},
+		{Float64("ratio", 0.25), 0.25},
+		{Bool("ok", true), true},
+		{Bool("ok", false), false},
+		{Duration("latency", 1500*time.Millisecond), "1.5s"},
+		{Time("at", when), when},
+		{Err(errors.New("boom")), "boom"},
+		{Any("tags", []string{"a"}), []string{"a"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.field.Key, func(t *testing.T) {
+			value := tt.field.Value()
+			if tags, ok := tt.expected.([]string); ok {
+				if got, _ := value.([]string); len(got) != 1 || got[0] != tags[0] {
+					t.Errorf("Expected %v, got %v", tt.expected, value)
+				}
+				return
+			}
+			if value != tt.expected {
+				t.Errorf("Expected %T(%v), got %T(%v)", tt.expected, tt.expected, value, value)
+			}
+		})
+	}
+
+	if fields := fieldsMap([]Field{Err(nil), String("a", "b")}); len(fields) != 1 {
+		t.Errorf("Expected a nil error to add no field, got %v", fields)
+	}
+}
+
+func TestFieldsRoundTrip(t *testing.T) {
+	const largeID int64 = 1<<62 + 1
+	latency := 1234567891 * time.Nanosecond
+	when := time.Date(2024, 1, 15, 10, 30, 45, 123456789, time.FixedZone("CET", 3600))
+
+	for _, codec := range []Codec{JSONCodec{}, LogfmtCodec{}, MsgpackCodec{}} {
+		t.Run(codec.ContentType(), func(t *testing.T) {
+			mockWriter := &mocks.MockMessageWriter{}
+			logger := newKafkaLogger(mockWriter, "test-service", WithEncoder(codec)).
+				WithFields(String("tenant", "acme"))
+
+			checkNoError(t, logger.LogFields(context.Background(), WARN, "slow request",
+				Int64("user_id", largeID),
+				Duration("latency", latency),
+				Time("at", when),
+				Err(errors.New("timeout")),
+			))
+
+			event, err := DecodeMessage(mockWriter.Messages[0])
+			checkNoError(t, err)
+
+			if id := fieldInt64(t, event.Fields["user_id"]); id != largeID {
+				t.Errorf("Expected user_id %d, got %d", largeID, id)
+			}
+
+			parsed, err := time.ParseDuration(event.Fields["latency"].(string))
+			checkNoError(t, err)
+			if parsed != latency {
+				t.Errorf("Expected latency %v, got %v", latency, parsed)
+			}
+
+			at := event.Fields["at"]
+			if s, ok := at.(string); ok {
+				at, err = time.Parse(time.RFC3339Nano, s)
+				checkNoError(t, err)
+			}
+			if !at.(time.Time).Equal(when) {
+				t.Errorf("Expected time %v, got %v", when, at)
+			}
+
+			if event.Error == nil || event.Error.Message != "timeout" || event.Fields["tenant"] != "acme" {
+				t.Errorf("Expected the structured error and bound tenant field, got %+v and %v", event.Error, event.Fields)
+			}
+		})
+	}
+}
+
+func fieldInt64(t *testing.T, value any) int64 {
+	t.Helper()
+	switch v := value.(type) {
+	case int64:
+		return v
+	case json.Number:
+		i, err := v.Int64()
+		checkNoError(t, err)
+		return i
+	default:
+		t.Fatalf("Expected an exact integer, got %T(%v)", value, value)
+		return 0
+	}
+}
+
+func TestFieldsMethods(t *testing.T) {
+	mockWriter := &mocks.MockMessageWriter{}
+	logger := newKafkaLogger(mockWriter, "test-service", WithLevel(NewLevelVar(INFO)))
+
+	checkNoError(t, logger.DebugFields("filtered", String("a", "b")))
+	checkNoError(t, logger.InfoFields("info", Int("n", 1)))
+	checkNoError(t, logger.WarnFields("warn"))
+	checkNoError(t, logger.ErrorFields("error", Bool("fatal", false)))
+
+	assertMessages(t, mockWriter, "info", "warn", "error")
+
+	event := decodeLogEvent(t, mockWriter.Messages[0])
+	assertCaller(t, event.Caller, "field_test.go", "TestFieldsMethods")
+	if event.Fields["n"] != float64(1) {
+		t.Errorf("Expected field n, got %v", event.Fields)
+	}
+	if decodeLogEvent(t, mockWriter.Messages[2]).Stack == "" {
+		t.Error("Expected a stack on ErrorFields")
+	}
+}
@@ -0,0 +1,105 @@
+package service
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/hex"
+	"strconv"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+)
+
+// Header names set on every published message so consumers can filter and
+// route events without decoding the value.
+const (
+	LevelHeader         = "level"
+	ServiceHeader       = "service"
+	SchemaVersionHeader = "schema-version"
+	EventIDHeader       = "event-id"
+	ProducerIDHeader    = "producer-id"
+	SequenceHeader      = "sequence"
+)
+
+// SchemaVersion is the version of the LogEvent layout. It only changes when a
+// field is removed or changes meaning; new optional fields keep the version.
+const SchemaVersion = 1
+
+// EventMetadata is what a consumer can learn about an event from the message
+// headers and timestamp alone.
+type EventMetadata struct {
+	Level         LogLevel
+	Service       string
+	ContentType   string
+	SchemaVersion int
+	EventID       string
+	ProducerID    string
+	Sequence      uint64
+	Time          time.Time
+}
+
+// MetadataFromMessage reads event metadata from message headers. ok is false
+// when the message has no level header, as for events written before the
+// headers were introduced; those have to be decoded to be classified.
+func MetadataFromMessage(message kafka.Message) (meta EventMetadata, ok bool) {
+	meta.Time = message.Time
+	for _, header := range message.Headers {
+		value := string(header.Value)
+		switch header.Key {
+		case LevelHeader:
+			meta.Level = LogLevel(value)
+			ok = true
+		case ServiceHeader:
+			meta.Service = value
+		case ContentTypeHeader:
+			meta.ContentType = value
+		case SchemaVersionHeader:
+			meta.SchemaVersion, _ = strconv.Atoi(value)
+		case EventIDHeader:
+			meta.EventID = value
+		case ProducerIDHeader:
+			meta.ProducerID = value
+		case SequenceHeader:
+			meta.Sequence, _ = strconv.ParseUint(value, 10, 64)
+		}
+	}
+	return meta, ok
+}
+
+func eventHeaders(event LogEvent, contentType string) []kafka.Header {
+	headers := []kafka.Header{
+		{Key: ContentTypeHeader, Value: []byte(contentType)},
+		{Key: LevelHeader, Value: []byte(event.Level)},
+		{Key: ServiceHeader, Value: []byte(event.Service)},
+		{Key: SchemaVersionHeader, Value: []byte(strconv.Itoa(SchemaVersion))},
+		{Key: EventIDHeader, Value: []byte(event.ID)},
+	}
+	if event.ProducerID != "" {
+		headers = append(headers,
+			kafka.Header{Key: ProducerIDHeader, Value: []byte(event.ProducerID)},
+			kafka.Header{Key: SequenceHeader, Value: []byte(strconv.FormatUint(event.Sequence, 10))})
+	}
+	return headers
+}
+
+// NewEventID returns a UUIDv7: a millisecond timestamp followed by random
+// bits, so IDs sort roughly by creation time.
+func NewEventID() string {
+	var id [16]byte
+	rand.Read(id[6:])
+	binary.BigEndian.PutUint64(id[:8], uint64(time.Now().UnixMilli())<<16|uint64(binary.BigEndian.Uint16(id[6:8])))
+	id[6] = 0x70 | id[6]&0x0f
+	id[8] = 0x80 | id[8]&0x3f
+
+	var buf [36]byte
+	hex.Encode(buf[0:8], id[0:4])
+	buf[8] = '-'
+	hex.Encode(buf[9:13], id[4:6])
+	buf[13] = '-'
+	hex.Encode(buf[14:18], id[6:8])
+	buf[18] = '-'
+	hex.Encode(buf[19:23], id[8:10])
+	buf[23] = '-'
+	hex.Encode(buf[24:], id[10:])
+	return string(buf[:])
+}
@@ -0,0 +1,66 @@
+package service
+
+import (
+	"kafka-logger/mocks"
+	"regexp"
+	"testing"
+
+	"github.com/segmentio/kafka-go"
+)
+
+func TestEventHeaders(t *testing.T) {
+	t.Run("Published messages carry metadata", func(t *testing.T) {
+		mockWriter := &mocks.MockMessageWriter{}
+		logger := newKafkaLogger(mockWriter, "header-service", WithEncoder(LogfmtCodec{}))
+
+		checkNoError(t, logger.Error("broken", nil))
+
+		msg := mockWriter.Messages[0]
+		event, err := DecodeMessage(msg)
+		checkNoError(t, err)
+
+		meta, ok := MetadataFromMessage(msg)
+		if !ok {
+			t.Fatal("Expected metadata in headers")
+		}
+		if meta.Level != ERROR || meta.Service != "header-service" {
+			t.Errorf("Expected ERROR from header-service, got %+v", meta)
+		}
+		if meta.ContentType != ContentTypeLogfmt || meta.SchemaVersion != SchemaVersion {
+			t.Errorf("Expected logfmt schema version %d, got %+v", SchemaVersion, meta)
+		}
+		if meta.EventID == "" {
+			t.Error("Expected an event ID header")
+		}
+		if !msg.Time.Equal(event.Timestamp) || !meta.Time.Equal(event.Timestamp) {
+			t.Errorf("Expected message time %v, got %v", event.Timestamp, msg.Time)
+		}
+	})
+
+	t.Run("Messages without headers", func(t *testing.T) {
+		if _, ok := MetadataFromMessage(kafka.Message{Value: []byte(`{}`)}); ok {
+			t.Error("Expected no metadata without headers")
+		}
+	})
+}
+
+func TestNewEventID(t *testing.T) {
+	uuidV7 := regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-7[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`)
+
+	seen := make(map[string]bool)
+	previous := ""
+	for range 1000 {
+		id := NewEventID()
+		if !uuidV7.MatchString(id) {
+			t.Fatalf("Expected a UUIDv7, got %s", id)
+		}
+		if seen[id] {
+			t.Fatalf("Duplicate event ID %s", id)
+		}
+		if id[:13] < previous {
+			t.Fatalf("Expected IDs to sort by time, got %s after %s", id, previous)
+		}
+		seen[id] = true
+		previous = id[:13]
+	}
+}
@@ -0,0 +1,88 @@
+package service
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync/atomic"
+)
+
+// LevelVar holds a minimum LogLevel that can be changed at runtime from any
+// goroutine. The zero value is DEBUG.
+type LevelVar struct {
+	severity atomic.Int32
+}
+
+func NewLevelVar(level LogLevel) *LevelVar {
+	v := &LevelVar{}
+	v.Set(level)
+	return v
+}
+
+func (v *LevelVar) Level() LogLevel {
+	switch v.severity.Load() {
+	case 1:
+		return INFO
+	case 2:
+		return WARN
+	case 3:
+		return ERROR
+	default:
+		return DEBUG
+	}
+}
+
+// Set changes the level. Unknown levels are treated as DEBUG.
+func (v *LevelVar) Set(level LogLevel) {
+	v.severity.Store(int32(max(level.Severity(), 0)))
+}
+
+// WithLevel drops events below the level held by v before they are encoded.
+func WithLevel(v *LevelVar) Option {
+	return func(o *loggerOptions) {
+		o.level = v
+	}
+}
+
+type levelResponse struct {
+	Level LogLevel `json:"level"`
+}
+
+// ServeHTTP reports the current level on GET and changes it on PUT or POST.
+// The new level is read from the "level" query parameter or the request body,
+// either as plain text or as {"level": "WARN"}.
+func (v *LevelVar) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodPut, http.MethodPost:
+		raw := r.URL.Query().Get("level")
+		if raw == "" {
+			body, err := io.ReadAll(io.LimitReader(r.Body, 1024))
+			if err != nil {
+				http.Error(w, fmt.Sprintf("failed to read body: %v", err), http.StatusBadRequest)
+				return
+			}
+			raw = string(body)
+			var req levelResponse
+			if strings.HasPrefix(strings.TrimSpace(raw), "{") && json.Unmarshal(body, &req) == nil {
+				raw = string(req.Level)
+			}
+		}
+
+		level, err := ParseLogLevel(raw)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		v.Set(level)
+	default:
+		w.Header().Set("Allow", "GET, PUT, POST")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(levelResponse{Level: v.Level()})
+}
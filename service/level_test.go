@@ -0,0 +1,137 @@
+package service
+
+import (
+	"encoding/json"
+	"kafka-logger/mocks"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestLogLevelOrdering(t *testing.T) {
+	ordered := []LogLevel{DEBUG, INFO, WARN, ERROR}
+	for i := 1; i < len(ordered); i++ {
+		if ordered[i].Severity() <= ordered[i-1].Severity() {
+			t.Errorf("Expected %s to be more severe than %s", ordered[i], ordered[i-1])
+		}
+	}
+
+	if !ERROR.AtLeast(WARN) || WARN.AtLeast(ERROR) || !INFO.AtLeast(INFO) {
+		t.Error("AtLeast does not follow severity order")
+	}
+
+	if LogLevel("TRACE").Severity() >= DEBUG.Severity() {
+		t.Error("Expected unknown level to sort below DEBUG")
+	}
+}
+
+func TestParseLogLevel(t *testing.T) {
+	testCases := []struct {
+		input    string
+		expected LogLevel
+		wantErr  bool
+	}{
+		{"warn", WARN, false},
+		{" ERROR\n", ERROR, false},
+		{"Debug", DEBUG, false},
+		{"verbose", "", true},
+	}
+
+	for _, tc := range testCases {
+		level, err := ParseLogLevel(tc.input)
+		if (err != nil) != tc.wantErr {
+			t.Errorf("ParseLogLevel(%q) error = %v, wantErr %v", tc.input, err, tc.wantErr)
+		}
+		if level != tc.expected {
+			t.Errorf("Expected %q to parse to %s, got %s", tc.input, tc.expected, level)
+		}
+	}
+}
+
+func TestMinimumLevel(t *testing.T) {
+	t.Run("Events below the level are rejected", func(t *testing.T) {
+		mockWriter := &mocks.MockMessageWriter{}
+		level := NewLevelVar(WARN)
+		logger := newKafkaLogger(mockWriter, "test-service", WithLevel(level))
+
+		checkNoError(t, logger.Debug("dropped", nil))
+		checkNoError(t, logger.Info("dropped", nil))
+		checkNoError(t, logger.Warn("kept", nil))
+
+		assertLogEvent(t, mockWriter, WARN, "kept", "test-service")
+	})
+
+	t.Run("Level can change at runtime", func(t *testing.T) {
+		mockWriter := &mocks.MockMessageWriter{}
+		level := NewLevelVar(ERROR)
+		logger := newKafkaLogger(mockWriter, "test-service", WithLevel(level))
+
+		checkNoError(t, logger.Debug("before", nil))
+		level.Set(DEBUG)
+		checkNoError(t, logger.Debug("after", nil))
+
+		assertLogEvent(t, mockWriter, DEBUG, "after", "test-service")
+	})
+
+	t.Run("Concurrent updates", func(t *testing.T) {
+		level := &LevelVar{}
+		var wg sync.WaitGroup
+		for _, l := range []LogLevel{DEBUG, INFO, WARN, ERROR} {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for range 100 {
+					level.Set(l)
+					_ = level.Level()
+				}
+			}()
+		}
+		wg.Wait()
+	})
+}
+
+func TestLevelVarServeHTTP(t *testing.T) {
+	testCases := []struct {
+		name           string
+		method         string
+		target         string
+		body           string
+		expectedStatus int
+		expectedLevel  LogLevel
+	}{
+		{"Get", http.MethodGet, "/level", "", http.StatusOK, INFO},
+		{"Put query", http.MethodPut, "/level?level=error", "", http.StatusOK, ERROR},
+		{"Post text", http.MethodPost, "/level", "warn", http.StatusOK, WARN},
+		{"Put JSON", http.MethodPut, "/level", `{"level":"DEBUG"}`, http.StatusOK, DEBUG},
+		{"Invalid level", http.MethodPut, "/level", "loud", http.StatusBadRequest, INFO},
+		{"Wrong method", http.MethodDelete, "/level", "", http.StatusMethodNotAllowed, INFO},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			level := NewLevelVar(INFO)
+			req := httptest.NewRequest(tc.method, tc.target, strings.NewReader(tc.body))
+			rec := httptest.NewRecorder()
+
+			level.ServeHTTP(rec, req)
+
+			if rec.Code != tc.expectedStatus {
+				t.Errorf("Expected status %d, got %d", tc.expectedStatus, rec.Code)
+			}
+			if level.Level() != tc.expectedLevel {
+				t.Errorf("Expected level %s, got %s", tc.expectedLevel, level.Level())
+			}
+			if rec.Code == http.StatusOK {
+				var resp levelResponse
+				if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+					t.Fatalf("Failed to decode response: %v", err)
+				}
+				if resp.Level != tc.expectedLevel {
+					t.Errorf("Expected response level %s, got %s", tc.expectedLevel, resp.Level)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,295 @@
+package service
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// fieldPrefix marks fields whose names collide with the top-level logfmt keys.
+const fieldPrefix = "fields."
+
+var logfmtReservedKeys = map[string]bool{
+	"timestamp":   true,
+	"level":       true,
+	"service":     true,
+	"message":     true,
+	"caller":      true,
+	"func":        true,
+	"stack":       true,
+	"error":       true,
+	"event_id":    true,
+	"producer_id": true,
+	"sequence":    true,
+}
+
+// LogfmtCodec writes events as key=value pairs. Nested fields are flattened
+// into dotted keys, and non-scalar values are written as quoted JSON, so the
+// encoding is readable but not fully type preserving.
+type LogfmtCodec struct{}
+
+func (LogfmtCodec) ContentType() string {
+	return ContentTypeLogfmt
+}
+
+func (LogfmtCodec) Encode(event LogEvent) ([]byte, error) {
+	var buf bytes.Buffer
+	writeLogfmtPair(&buf, "timestamp", event.Timestamp.Format(time.RFC3339Nano), false)
+	writeLogfmtPair(&buf, "level", string(event.Level), true)
+	writeLogfmtPair(&buf, "service", event.Service, true)
+	writeLogfmtPair(&buf, "message", event.Message, true)
+	if event.Caller != nil {
+		writeLogfmtPair(&buf, "caller", event.Caller.File+":"+strconv.Itoa(event.Caller.Line), true)
+		writeLogfmtPair(&buf, "func", event.Caller.Function, true)
+	}
+	if event.Stack != "" {
+		writeLogfmtPair(&buf, "stack", event.Stack, true)
+	}
+	if event.Error != nil {
+		data, err := json.Marshal(event.Error)
+		if err != nil {
+			return nil, err
+		}
+		writeLogfmtPair(&buf, "error", string(data), true)
+	}
+	if event.ID != "" {
+		writeLogfmtPair(&buf, "event_id", event.ID, true)
+	}
+	if event.ProducerID != "" {
+		writeLogfmtPair(&buf, "producer_id", event.ProducerID, true)
+		writeLogfmtPair(&buf, "sequence", strconv.FormatUint(event.Sequence, 10), false)
+	}
+
+	flat := make(map[string]any)
+	flattenFields(flat, "", event.Fields)
+
+	keys := make([]string, 0, len(flat))
+	for key := range flat {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		name := key
+		if logfmtReservedKeys[name] || strings.HasPrefix(name, fieldPrefix) {
+			name = fieldPrefix + name
+		}
+		if err := writeLogfmtValue(&buf, name, flat[key]); err != nil {
+			return nil, err
+		}
+	}
+
+	return buf.Bytes(), nil
+}
+
+func flattenFields(dst map[string]any, prefix string, fields map[string]any) {
+	for key, value := range fields {
+		if sub, ok := value.(map[string]any); ok && len(sub) > 0 {
+			flattenFields(dst, prefix+key+".", sub)
+			continue
+		}
+		dst[prefix+key] = value
+	}
+}
+
+func writeLogfmtValue(buf *bytes.Buffer, key string, value any) error {
+	switch v := value.(type) {
+	case nil:
+		writeLogfmtPair(buf, key, "null", false)
+	case string:
+		writeLogfmtPair(buf, key, v, true)
+	case bool:
+		writeLogfmtPair(buf, key, strconv.FormatBool(v), false)
+	case int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64:
+		writeLogfmtPair(buf, key, fmt.Sprint(v), false)
+	case float32:
+		writeLogfmtPair(buf, key, strconv.FormatFloat(float64(v), 'g', -1, 32), false)
+	case float64:
+		writeLogfmtPair(buf, key, strconv.FormatFloat(v, 'g', -1, 64), false)
+	case json.Number:
+		writeLogfmtPair(buf, key, v.String(), false)
+	case time.Time:
+		writeLogfmtPair(buf, key, v.Format(time.RFC3339Nano), true)
+	case time.Duration:
+		writeLogfmtPair(buf, key, v.String(), true)
+	case error:
+		writeLogfmtPair(buf, key, v.Error(), true)
+	case fmt.Stringer:
+		writeLogfmtPair(buf, key, v.String(), true)
+	default:
+		data, err := json.Marshal(v)
+		if err != nil {
+			return fmt.Errorf("failed to encode field %s: %w", key, err)
+		}
+		writeLogfmtPair(buf, key, string(data), true)
+	}
+	return nil
+}
+
+// writeLogfmtPair appends key=value. Strings are quoted when they contain
+// spaces or quotes, or when they would otherwise be read back as another type.
+func writeLogfmtPair(buf *bytes.Buffer, key, value string, isString bool) {
+	if buf.Len() > 0 {
+		buf.WriteByte(' ')
+	}
+	buf.WriteString(key)
+	buf.WriteByte('=')
+	if isString && needsLogfmtQuoting(value) {
+		buf.WriteString(strconv.Quote(value))
+		return
+	}
+	buf.WriteString(value)
+}
+
+func needsLogfmtQuoting(value string) bool {
+	if value == "" {
+		return true
+	}
+	for _, r := range value {
+		if r == ' ' || r == '=' || r == '"' || !unicode.IsPrint(r) {
+			return true
+		}
+	}
+	_, isScalar := parseLogfmtScalar(value)
+	return isScalar
+}
+
+// parseLogfmtScalar interprets an unquoted value as null, a bool or a number.
+func parseLogfmtScalar(value string) (any, bool) {
+	switch value {
+	case "null":
+		return nil, true
+	case "true":
+		return true, true
+	case "false":
+		return false, true
+	}
+	if i, err := strconv.ParseInt(value, 10, 64); err == nil {
+		return i, true
+	}
+	if f, err := strconv.ParseFloat(value, 64); err == nil {
+		return f, true
+	}
+	return nil, false
+}
+
+func (LogfmtCodec) Decode(data []byte, event *LogEvent) error {
+	*event = LogEvent{}
+
+	rest := string(data)
+	for {
+		rest = strings.TrimLeft(rest, " \t\r\n")
+		if rest == "" {
+			return nil
+		}
+
+		eq := strings.IndexByte(rest, '=')
+		if eq <= 0 {
+			return fmt.Errorf("logfmt: expected key=value at %q", rest)
+		}
+		key := rest[:eq]
+		rest = rest[eq+1:]
+
+		var value any
+		if strings.HasPrefix(rest, `"`) {
+			quoted, err := strconv.QuotedPrefix(rest)
+			if err != nil {
+				return fmt.Errorf("logfmt: bad quoted value for %s: %w", key, err)
+			}
+			rest = rest[len(quoted):]
+			value, _ = strconv.Unquote(quoted)
+		} else {
+			end := strings.IndexAny(rest, " \t\r\n")
+			if end < 0 {
+				end = len(rest)
+			}
+			raw := rest[:end]
+			rest = rest[end:]
+			if scalar, ok := parseLogfmtScalar(raw); ok {
+				value = scalar
+			} else {
+				value = raw
+			}
+		}
+
+		if err := event.setLogfmtKey(key, value); err != nil {
+			return err
+		}
+	}
+}
+
+func (event *LogEvent) setLogfmtKey(key string, value any) error {
+	if logfmtReservedKeys[key] {
+		s := fmt.Sprint(value)
+		switch key {
+		case "timestamp":
+			ts, err := time.Parse(time.RFC3339Nano, s)
+			if err != nil {
+				return fmt.Errorf("logfmt: bad timestamp: %w", err)
+			}
+			event.Timestamp = ts
+		case "level":
+			event.Level = LogLevel(s)
+		case "service":
+			event.Service = s
+		case "message":
+			event.Message = s
+		case "caller":
+			if event.Caller == nil {
+				event.Caller = &Caller{}
+			}
+			event.Caller.File = s
+			if i := strings.LastIndexByte(s, ':'); i >= 0 {
+				if line, err := strconv.Atoi(s[i+1:]); err == nil {
+					event.Caller.File, event.Caller.Line = s[:i], line
+				}
+			}
+		case "func":
+			if event.Caller == nil {
+				event.Caller = &Caller{}
+			}
+			event.Caller.Function = s
+		case "stack":
+			event.Stack = s
+		case "error":
+			event.Error = &ErrorInfo{}
+			if err := json.Unmarshal([]byte(s), event.Error); err != nil {
+				return fmt.Errorf("logfmt: bad error: %w", err)
+			}
+		case "event_id":
+			event.ID = s
+		case "producer_id":
+			event.ProducerID = s
+		case "sequence":
+			seq, err := strconv.ParseUint(s, 10, 64)
+			if err != nil {
+				return fmt.Errorf("logfmt: bad sequence: %w", err)
+			}
+			event.Sequence = seq
+		}
+		return nil
+	}
+
+	key = strings.TrimPrefix(key, fieldPrefix)
+	if event.Fields == nil {
+		event.Fields = make(map[string]any)
+	}
+
+	parts := strings.Split(key, ".")
+	target := event.Fields
+	for _, part := range parts[:len(parts)-1] {
+		sub, ok := target[part].(map[string]any)
+		if !ok {
+			sub = make(map[string]any)
+			target[part] = sub
+		}
+		target = sub
+	}
+	target[parts[len(parts)-1]] = value
+	return nil
+}
@@ -2,8 +2,10 @@ package service
 
 import (
 	"context"
-	"encoding/json"
+	"errors"
+	"fmt"
 	"kafka-logger/producer"
+	"strings"
 	"time"
 
 	"github.com/segmentio/kafka-go"
@@ -18,69 +20,418 @@ const (
 	DEBUG LogLevel = "DEBUG"
 )
 
+var (
+	ErrLoggerClosed = errors.New("kafka logger is closed")
+	ErrEventDropped = errors.New("log event dropped")
+)
+
+// Severity orders levels from least (DEBUG) to most (ERROR) severe. Unknown
+// levels sort below DEBUG.
+func (l LogLevel) Severity() int {
+	switch l {
+	case DEBUG:
+		return 0
+	case INFO:
+		return 1
+	case WARN:
+		return 2
+	case ERROR:
+		return 3
+	default:
+		return -1
+	}
+}
+
+// AtLeast reports whether l is as severe as min or more.
+func (l LogLevel) AtLeast(min LogLevel) bool {
+	return l.Severity() >= min.Severity()
+}
+
+func ParseLogLevel(s string) (LogLevel, error) {
+	level := LogLevel(strings.ToUpper(strings.TrimSpace(s)))
+	if level.Severity() < 0 {
+		return "", fmt.Errorf("unknown log level %q", s)
+	}
+	return level, nil
+}
+
 type LogEvent struct {
 	Timestamp time.Time      `json:"timestamp"`
 	Level     LogLevel       `json:"level"`
 	Message   string         `json:"message"`
 	Service   string         `json:"service"`
 	Fields    map[string]any `json:"fields,omitempty"`
+	Caller    *Caller        `json:"caller,omitempty"`
+	Stack     string         `json:"stack,omitempty"`
+	// Error is set from an error value logged under the "error" field, as
+	// with Err.
+	Error *ErrorInfo `json:"error,omitempty"`
+
+	// ID is unique per event and is kept when the event is retried, so
+	// consumers can drop redelivered copies.
+	ID string `json:"event_id,omitempty"`
+	// ProducerID and Sequence identify the logger that published the event
+	// and its position in that logger's output. Sequence numbers start at 1
+	// and have no holes unless events were lost.
+	ProducerID string `json:"producer_id,omitempty"`
+	Sequence   uint64 `json:"sequence,omitempty"`
 }
 
 type KafkaLogger struct {
-	writer  producer.MessageWriter
-	service string
+	writer   producer.MessageWriter
+	service  string
+	async    *asyncQueue
+	spool    *diskSpool
+	fields   map[string]any
+	level    *LevelVar
+	encoder  Encoder
+	registry *RegistryClient
+	subject  string
+	keys     KeyStrategy
+	redactor *Redactor
+	delivery *deliveryReporter
+	recorder *FlightRecorderConfig
+	scope    *flightScope
+	sequence *producerSequence
+	hooks    []Hook
+	sampler  *sampler
+
+	stackLevels []LogLevel
 }
 
-func NewKafkaLogger(brokers []string, topic, serviceName string) *KafkaLogger {
-	return &KafkaLogger{
-		writer:  producer.NewProducer(brokers, topic),
-		service: serviceName,
+// Option configures optional KafkaLogger behavior.
+type Option func(*loggerOptions)
+
+type loggerOptions struct {
+	async      *AsyncConfig
+	spool      *SpoolConfig
+	level      *LevelVar
+	encoder    Encoder
+	registry   *RegistryClient
+	subject    string
+	keys       KeyStrategy
+	balancer   kafka.Balancer
+	redactor   *Redactor
+	completion func(failed []LogEvent, err error)
+	recorder   *FlightRecorderConfig
+	hooks      []Hook
+	sampling   *SamplingConfig
+
+	stackLevels    []LogLevel
+	stackLevelsSet bool
+}
+
+// NewKafkaLogger returns a logger that writes to topic. Options that
+// NewKafkaLoggerWithSettings would reject, such as a schema registry with a
+// non-JSON encoder, make every event fail with the same error instead.
+func NewKafkaLogger(brokers []string, topic, serviceName string, opts ...Option) *KafkaLogger {
+	// Zero settings are always valid.
+	kl, _ := newKafkaLoggerWithSettings(brokers, topic, serviceName, producer.Settings{}, opts...)
+	return kl
+}
+
+func newKafkaLogger(writer producer.MessageWriter, serviceName string, opts ...Option) *KafkaLogger {
+	var o loggerOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	if o.level == nil {
+		o.level = &LevelVar{}
+	}
+	if !o.stackLevelsSet {
+		o.stackLevels = []LogLevel{ERROR}
+	}
+
+	kl := &KafkaLogger{
+		writer:   writer,
+		service:  serviceName,
+		level:    o.level,
+		encoder:  o.encoder,
+		registry: o.registry,
+		subject:  o.subject,
+		keys:     o.keys,
+		redactor: o.redactor,
+		recorder: o.recorder,
+		sequence: newProducerSequence(),
+		hooks:    o.hooks,
+
+		stackLevels: o.stackLevels,
+	}
+	if o.spool != nil {
+		kl.spool = newDiskSpool(writer, *o.spool)
 	}
+	if o.async != nil {
+		kl.async = newAsyncQueue(writer, kl.spool, *o.async)
+		kl.async.completion = o.completion
+	}
+	if o.sampling != nil {
+		kl.sampler = newSampler(*o.sampling)
+		kl.startSummaries()
+	}
+	return kl
+}
+
+// With returns a child logger that adds fields to every event it logs. The
+// child shares the parent's writer, so only the root logger should be closed.
+func (kl *KafkaLogger) With(fields map[string]any) *KafkaLogger {
+	child := *kl
+	child.fields = cloneFields(kl.fields)
+	if child.fields == nil && len(fields) > 0 {
+		child.fields = make(map[string]any, len(fields))
+	}
+	mergeFields(child.fields, cloneFields(fields))
+	return &child
 }
 
-func (kl *KafkaLogger) log(level LogLevel, message string, fields *map[string]any) error {
+func (kl *KafkaLogger) log(ctx context.Context, level LogLevel, message string, fields *map[string]any) error {
+	if !kl.Enabled(level) {
+		return nil
+	}
+
 	var f map[string]any
 	if fields != nil {
 		f = *fields
 	}
 
+	// Skip log and the exported method that called it.
+	return kl.publish(ctx, kl.newEvent(level, message, f, callerAt(2)))
+}
+
+func (kl *KafkaLogger) logFields(ctx context.Context, level LogLevel, message string, fields []Field) error {
+	if !kl.Enabled(level) {
+		return nil
+	}
+
+	// Skip logFields and the exported method that called it.
+	return kl.publish(ctx, kl.newEvent(level, message, fieldsMap(fields), callerAt(2)))
+}
+
+func (kl *KafkaLogger) newEvent(level LogLevel, message string, fields map[string]any, caller *Caller) LogEvent {
 	event := LogEvent{
 		Timestamp: time.Now().UTC(),
 		Level:     level,
 		Message:   message,
 		Service:   kl.service,
-		Fields:    f,
+		Fields:    fields,
+		Caller:    caller,
+	}
+	if kl.wantsStack(level) {
+		event.Stack = stackFrom(caller)
+	}
+	return event
+}
+
+func (kl *KafkaLogger) Service() string {
+	return kl.service
+}
+
+// LevelVar returns the logger's minimum level holder, shared with all child
+// loggers, so it can be adjusted at runtime.
+func (kl *KafkaLogger) LevelVar() *LevelVar {
+	return kl.level
+}
+
+// Enabled reports whether events at level are published, either because
+// they pass the logger's minimum level or because a flight recorder scope
+// buffers them.
+func (kl *KafkaLogger) Enabled(level LogLevel) bool {
+	return kl.passesLevel(level) || kl.scope.buffers(level)
+}
+
+func (kl *KafkaLogger) passesLevel(level LogLevel) bool {
+	return kl.level == nil || level.AtLeast(kl.level.Level())
+}
+
+func (kl *KafkaLogger) publish(ctx context.Context, event LogEvent) error {
+	// Sample before preparing the event, so suppressed events cost little.
+	if !kl.scope.buffers(event.Level) && kl.passesLevel(event.Level) && !kl.sampler.allow(event.Level, event.Message) {
+		return nil
+	}
+	if !kl.prepare(ctx, &event) {
+		return nil
+	}
+
+	if kl.scope.buffers(event.Level) {
+		kl.scope.record(event)
+		return nil
 	}
 
-	data, err := json.Marshal(event)
+	var errs []error
+	if kl.scope.flushedBy(event.Level) {
+		for _, buffered := range kl.scope.drain() {
+			errs = append(errs, kl.send(ctx, buffered))
+		}
+	}
+
+	if kl.passesLevel(event.Level) {
+		errs = append(errs, kl.send(ctx, event))
+	}
+	return errors.Join(errs...)
+}
+
+// prepare finalizes the event's fields while the context is at hand: it
+// merges bound and context fields, moves an error value into the structured
+// Error, runs the hooks and redacts the result.
+// It returns false if a hook vetoed the event.
+func (kl *KafkaLogger) prepare(ctx context.Context, event *LogEvent) bool {
+	event.Fields = kl.eventFields(ctx, event.Fields)
+	extractError(event)
+	if !kl.runHooks(ctx, event) {
+		return false
+	}
+	event.Fields = kl.redactor.Redact(event.Fields)
+	event.Error = kl.redactor.RedactError(event.Error)
+	return true
+}
+
+// send encodes an event whose fields are final and writes it to Kafka.
+func (kl *KafkaLogger) send(ctx context.Context, event LogEvent) error {
+	kl.sequence.stamp(&event)
+
+	encoder := kl.encoder
+	if encoder == nil {
+		encoder = JSONCodec{}
+	}
+
+	data, err := encoder.Encode(event)
 	if err != nil {
 		return err
 	}
 
-	return kl.writer.WriteMessages(context.Background(),
-		kafka.Message{
-			Key:   []byte(kl.service),
-			Value: data,
-		},
-	)
+	if kl.registry != nil {
+		if err := CheckRegistryEncoder(encoder); err != nil {
+			return err
+		}
+		schemaID, err := kl.registry.Register(ctx, kl.subject, Schema{Schema: LogEventSchema, SchemaType: SchemaTypeJSON})
+		if err != nil {
+			return err
+		}
+		data = EncodeWireFormat(schemaID, data)
+	}
+
+	keys := kl.keys
+	if keys == nil {
+		keys = KeyByService()
+	}
+
+	msg := kafka.Message{
+		Key:     keys.Key(event),
+		Value:   data,
+		Headers: eventHeaders(event, encoder.ContentType()),
+		Time:    event.Timestamp,
+	}
+	if failed, ok := ctx.Value(failedLineKey{}).(*failedLine); ok {
+		msg.WriterData = failed
+	}
+
+	if kl.async != nil {
+		return kl.async.enqueue(event.Level, msg)
+	}
+
+	if kl.spool != nil && kl.spool.pending() {
+		// Older events are waiting in the spool; queue behind them so the
+		// topic keeps the order they were logged in.
+		return kl.spool.enqueue(msg)
+	}
+	err = kl.writer.WriteMessages(ctx, msg)
+	if err != nil && kl.spool != nil {
+		if spoolErr := kl.spool.append(msg); spoolErr != nil {
+			return errors.Join(err, spoolErr)
+		}
+		return nil
+	}
+	return err
+}
+
+// eventFields combines bound fields, registered context values and the
+// fields passed to the call, in increasing order of precedence.
+func (kl *KafkaLogger) eventFields(ctx context.Context, fields map[string]any) map[string]any {
+	ctxFields := contextFields(ctx)
+	if len(kl.fields) == 0 && len(ctxFields) == 0 {
+		return fields
+	}
+
+	merged := cloneFields(kl.fields)
+	if merged == nil {
+		merged = make(map[string]any, len(ctxFields)+len(fields))
+	}
+	mergeFields(merged, ctxFields)
+	mergeFields(merged, cloneFields(fields))
+	return merged
 }
 
 func (kl *KafkaLogger) Info(message string, fields *map[string]any) error {
-	return kl.log(INFO, message, fields)
+	return kl.log(context.Background(), INFO, message, fields)
 }
 
 func (kl *KafkaLogger) Warn(message string, fields *map[string]any) error {
-	return kl.log(WARN, message, fields)
+	return kl.log(context.Background(), WARN, message, fields)
 }
 
 func (kl *KafkaLogger) Error(message string, fields *map[string]any) error {
-	return kl.log(ERROR, message, fields)
+	return kl.log(context.Background(), ERROR, message, fields)
 }
 
 func (kl *KafkaLogger) Debug(message string, fields *map[string]any) error {
-	return kl.log(DEBUG, message, fields)
+	return kl.log(context.Background(), DEBUG, message, fields)
+}
+
+func (kl *KafkaLogger) InfoContext(ctx context.Context, message string, fields *map[string]any) error {
+	return kl.log(ctx, INFO, message, fields)
+}
+
+func (kl *KafkaLogger) WarnContext(ctx context.Context, message string, fields *map[string]any) error {
+	return kl.log(ctx, WARN, message, fields)
+}
+
+func (kl *KafkaLogger) ErrorContext(ctx context.Context, message string, fields *map[string]any) error {
+	return kl.log(ctx, ERROR, message, fields)
 }
 
+func (kl *KafkaLogger) DebugContext(ctx context.Context, message string, fields *map[string]any) error {
+	return kl.log(ctx, DEBUG, message, fields)
+}
+
+// Dropped returns the number of events lost in async mode, either to the
+// overflow policy or because they could not be written, plus any events
+// evicted from the spool.
+func (kl *KafkaLogger) Dropped() uint64 {
+	var dropped uint64
+	if kl.async != nil {
+		dropped += kl.async.dropped.Load()
+	}
+	if kl.spool != nil {
+		dropped += kl.spool.evicted.Load()
+	}
+	if kl.delivery != nil {
+		dropped += kl.delivery.dropped.Load()
+	}
+	return dropped
+}
+
+// Close flushes any queued events and closes the underlying writer. It
+// returns an error wrapping ErrEventDropped if events were lost.
 func (kl *KafkaLogger) Close() error {
-	return kl.writer.Close()
+	var errs []error
+	kl.stopSummaries()
+	if kl.async != nil {
+		kl.async.close()
+	}
+	// An async writer reports its last failures while closing, so the
+	// spool is closed after it, and dropped events are counted afterwards.
+	if kl.spool != nil {
+		kl.spool.stopReplay()
+	}
+	writerErr := kl.writer.Close()
+	if kl.spool != nil {
+		kl.spool.close()
+	}
+	if dropped := kl.Dropped(); dropped > 0 {
+		errs = append(errs, fmt.Errorf("%w (%d in total)", ErrEventDropped, dropped))
+	}
+	if writerErr != nil {
+		errs = append(errs, writerErr)
+	}
+	return errors.Join(errs...)
 }
@@ -271,3 +271,13 @@ func assertLogEvent(t *testing.T, mockWriter *mocks.MockMessageWriter, expectedL
 
 	return logEvent
 }
+
+func decodeLogEvent(t *testing.T, msg kafka.Message) LogEvent {
+	t.Helper()
+
+	var logEvent LogEvent
+	if err := json.Unmarshal(msg.Value, &logEvent); err != nil {
+		t.Fatalf("Failed to unmarshal log event: %v", err)
+	}
+	return logEvent
+}
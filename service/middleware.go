@@ -0,0 +1,266 @@
+package service
+
+import (
+	"bufio"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"runtime/debug"
+	"strings"
+	"time"
+)
+
+const (
+	RequestIDHeader   = "X-Request-ID"
+	TraceparentHeader = "traceparent"
+)
+
+// maxRequestIDLength bounds request IDs taken from clients, which end up in
+// every event logged for the request.
+const maxRequestIDLength = 128
+
+// Middleware wraps next so every request is logged when it completes, with
+// its method, path, status, bytes written, latency, remote address and user
+// agent. 5xx responses are logged at ERROR, 4xx at WARN and the rest at
+// INFO.
+//
+// The request ID is taken from the X-Request-ID header or generated, and is
+// echoed in the response. The trace is continued from a valid traceparent
+// header or started, with a new span ID for this request. Both are bound to
+// a scoped child logger, which handlers get with LoggerFromContext, and the
+// trace and span IDs are also set on the context for the *Context methods.
+//
+// A panic in next is logged at ERROR with its stack trace and answered with
+// 500 if nothing was written yet. http.ErrAbortHandler is passed on, since
+// it is the documented way to abort a response.
+func (kl *KafkaLogger) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+
+		requestID := r.Header.Get(RequestIDHeader)
+		if !validRequestID(requestID) {
+			requestID = NewEventID()
+		}
+		trace := continueTrace(r.Header.Get(TraceparentHeader))
+		w.Header().Set(RequestIDHeader, requestID)
+
+		reqLogger := kl.Scope(map[string]any{
+			"request_id": requestID,
+			"trace_id":   trace.traceID,
+			"span_id":    trace.spanID,
+		})
+		defer reqLogger.EndScope()
+
+		ctx := r.Context()
+		ctx = ContextWithLogger(ctx, reqLogger)
+		ctx = ContextWithTraceID(ctx, trace.traceID)
+		ctx = ContextWithSpanID(ctx, trace.spanID)
+		ctx = context.WithValue(ctx, requestIDKey, requestID)
+		ctx = context.WithValue(ctx, traceparentKey, trace.String())
+		r = r.WithContext(ctx)
+
+		rec := &responseRecorder{ResponseWriter: w}
+		defer func() {
+			if p := recover(); p != nil {
+				if p == http.ErrAbortHandler {
+					panic(p)
+				}
+				fields := []Field{String("panic", fmt.Sprint(p))}
+				if err, ok := p.(error); ok {
+					fields = append(fields, Err(err))
+				}
+				if reqLogger.Enabled(ERROR) {
+					// A panic is useless without its stack, so it is kept
+					// whatever levels WithStackTraces is set to.
+					reqLogger.publish(ctx, LogEvent{
+						Timestamp: time.Now().UTC(),
+						Level:     ERROR,
+						Message:   "panic serving request",
+						Service:   reqLogger.service,
+						Fields:    fieldsMap(fields),
+						Caller:    panicCaller(),
+						Stack:     string(debug.Stack()),
+					})
+				}
+				if !rec.wroteHeader {
+					http.Error(rec, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+				} else {
+					rec.status = http.StatusInternalServerError
+				}
+			}
+			logRequest(ctx, reqLogger, r, rec, time.Since(start))
+		}()
+
+		next.ServeHTTP(rec, r)
+	})
+}
+
+func logRequest(ctx context.Context, logger *KafkaLogger, r *http.Request, rec *responseRecorder, latency time.Duration) {
+	status := rec.status
+	if status == 0 {
+		status = http.StatusOK
+	}
+
+	level := INFO
+	switch {
+	case status >= 500:
+		level = ERROR
+	case status >= 400:
+		level = WARN
+	}
+
+	logger.LogFields(ctx, level, "request completed",
+		String("method", r.Method),
+		String("path", r.URL.Path),
+		Int("status", status),
+		Int64("bytes", rec.bytes),
+		Duration("latency", latency),
+		String("remote_addr", r.RemoteAddr),
+		String("user_agent", r.UserAgent()),
+	)
+}
+
+// RequestIDFromContext returns the request ID set by Middleware, or "".
+func RequestIDFromContext(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey).(string)
+	return id
+}
+
+// TraceparentFromContext returns the traceparent header value for the
+// request's span, to be sent on outgoing requests, or "".
+func TraceparentFromContext(ctx context.Context) string {
+	traceparent, _ := ctx.Value(traceparentKey).(string)
+	return traceparent
+}
+
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+	for _, c := range id {
+		if c <= ' ' || c > '~' {
+			return false
+		}
+	}
+	return true
+}
+
+// traceContext is the part of a W3C trace context the middleware uses.
+type traceContext struct {
+	traceID string
+	spanID  string
+	flags   string
+}
+
+func (tc traceContext) String() string {
+	return "00-" + tc.traceID + "-" + tc.spanID + "-" + tc.flags
+}
+
+// continueTrace returns the trace context for a request: the incoming trace
+// with a new span ID, or a new sampled trace if header is missing or
+// invalid.
+func continueTrace(header string) traceContext {
+	if tc, err := parseTraceparent(header); err == nil {
+		tc.spanID = randomHex(8)
+		return tc
+	}
+	return traceContext{traceID: randomHex(16), spanID: randomHex(8), flags: "01"}
+}
+
+var errBadTraceparent = errors.New("malformed traceparent")
+
+// parseTraceparent parses a version-00 traceparent header. Later versions
+// are read the same way, ignoring anything after the flags, as the
+// specification asks.
+func parseTraceparent(header string) (traceContext, error) {
+	parts := strings.Split(strings.TrimSpace(header), "-")
+	if len(parts) < 4 {
+		return traceContext{}, errBadTraceparent
+	}
+	version, traceID, spanID, flags := parts[0], parts[1], parts[2], parts[3]
+	if !isLowerHex(version, 2) || version == "ff" || (version == "00" && len(parts) != 4) {
+		return traceContext{}, errBadTraceparent
+	}
+	if !isLowerHex(traceID, 32) || traceID == strings.Repeat("0", 32) {
+		return traceContext{}, errBadTraceparent
+	}
+	if !isLowerHex(spanID, 16) || spanID == strings.Repeat("0", 16) {
+		return traceContext{}, errBadTraceparent
+	}
+	if !isLowerHex(flags, 2) {
+		return traceContext{}, errBadTraceparent
+	}
+	return traceContext{traceID: traceID, spanID: spanID, flags: flags}, nil
+}
+
+func isLowerHex(s string, length int) bool {
+	if len(s) != length {
+		return false
+	}
+	for _, c := range s {
+		if (c < '0' || c > '9') && (c < 'a' || c > 'f') {
+			return false
+		}
+	}
+	return true
+}
+
+func randomHex(n int) string {
+	b := make([]byte, n)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// responseRecorder records the status and size of a response.
+type responseRecorder struct {
+	http.ResponseWriter
+	status      int
+	bytes       int64
+	wroteHeader bool
+}
+
+func (rec *responseRecorder) WriteHeader(status int) {
+	// Informational responses are followed by the real status.
+	if !rec.wroteHeader && status >= 200 {
+		rec.status = status
+		rec.wroteHeader = true
+	}
+	rec.ResponseWriter.WriteHeader(status)
+}
+
+func (rec *responseRecorder) Write(b []byte) (int, error) {
+	if !rec.wroteHeader {
+		rec.WriteHeader(http.StatusOK)
+	}
+	n, err := rec.ResponseWriter.Write(b)
+	rec.bytes += int64(n)
+	return n, err
+}
+
+// Flush lets streaming handlers flush through the recorder.
+func (rec *responseRecorder) Flush() {
+	if !rec.wroteHeader {
+		rec.WriteHeader(http.StatusOK)
+	}
+	http.NewResponseController(rec.ResponseWriter).Flush()
+}
+
+// Hijack lets websocket upgrades take over the connection through the
+// recorder. The response is then logged as 101 Switching Protocols.
+func (rec *responseRecorder) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	conn, rw, err := http.NewResponseController(rec.ResponseWriter).Hijack()
+	if err == nil && !rec.wroteHeader {
+		rec.status = http.StatusSwitchingProtocols
+		rec.wroteHeader = true
+	}
+	return conn, rw, err
+}
+
+// Unwrap gives http.ResponseController access to the underlying writer.
+func (rec *responseRecorder) Unwrap() http.ResponseWriter {
+	return rec.ResponseWriter
+}
@@ -0,0 +1,225 @@
+package service
+
+import (
+	"bufio"
+	"errors"
+	"kafka-logger/mocks"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestMiddleware(t *testing.T) {
+	t.Run("Access log with request ID and trace", func(t *testing.T) {
+		mockWriter := &mocks.MockMessageWriter{}
+		logger := newKafkaLogger(mockWriter, "test-service")
+
+		var handlerRequestID, handlerTraceparent string
+		handler := logger.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			handlerRequestID = RequestIDFromContext(r.Context())
+			handlerTraceparent = TraceparentFromContext(r.Context())
+			checkNoError(t, LoggerFromContext(r.Context(), nil).InfoContext(r.Context(), "handling", nil))
+			w.WriteHeader(http.StatusTeapot)
+			w.Write([]byte("short and stout"))
+		}))
+
+		req := httptest.NewRequest(http.MethodPost, "/brew?size=large", nil)
+		req.Header.Set(RequestIDHeader, "req-42")
+		req.Header.Set(TraceparentHeader, "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+		req.Header.Set("User-Agent", "curl/8.0")
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+
+		if rec.Header().Get(RequestIDHeader) != "req-42" || handlerRequestID != "req-42" {
+			t.Errorf("Expected the incoming request ID, got %q", rec.Header().Get(RequestIDHeader))
+		}
+		if !strings.HasPrefix(handlerTraceparent, "00-4bf92f3577b34da6a3ce929d0e0e4736-") ||
+			strings.Contains(handlerTraceparent, "00f067aa0ba902b7") || !strings.HasSuffix(handlerTraceparent, "-01") {
+			t.Errorf("Expected the trace to continue with a new span, got %s", handlerTraceparent)
+		}
+
+		assertMessages(t, mockWriter, "handling", "request completed")
+		for _, msg := range mockWriter.Messages {
+			event := decodeLogEvent(t, msg)
+			if event.Fields["request_id"] != "req-42" || event.Fields["trace_id"] != "4bf92f3577b34da6a3ce929d0e0e4736" {
+				t.Errorf("Expected request fields on %q, got %v", event.Message, event.Fields)
+			}
+		}
+
+		access := decodeLogEvent(t, mockWriter.Messages[1])
+		if access.Level != WARN {
+			t.Errorf("Expected WARN for a 4xx, got %s", access.Level)
+		}
+		expected := map[string]any{
+			"method":      "POST",
+			"path":        "/brew",
+			"status":      float64(http.StatusTeapot),
+			"bytes":       float64(len("short and stout")),
+			"remote_addr": req.RemoteAddr,
+			"user_agent":  "curl/8.0",
+		}
+		for key, value := range expected {
+			if access.Fields[key] != value {
+				t.Errorf("Expected %s=%v, got %v", key, value, access.Fields[key])
+			}
+		}
+		if _, ok := access.Fields["latency"].(string); !ok {
+			t.Errorf("Expected a latency, got %v", access.Fields["latency"])
+		}
+	})
+
+	t.Run("Generated request ID and trace", func(t *testing.T) {
+		mockWriter := &mocks.MockMessageWriter{}
+		logger := newKafkaLogger(mockWriter, "test-service")
+
+		var traceparent string
+		handler := logger.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			traceparent = TraceparentFromContext(r.Context())
+		}))
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Header.Set(RequestIDHeader, "has spaces")
+		req.Header.Set(TraceparentHeader, "00-00000000000000000000000000000000-00f067aa0ba902b7-01")
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+
+		if id := rec.Header().Get(RequestIDHeader); id == "" || id == "has spaces" {
+			t.Errorf("Expected a generated request ID, got %q", id)
+		}
+		if _, err := parseTraceparent(traceparent); err != nil || strings.Contains(traceparent, "00000000000000000000000000000000") {
+			t.Errorf("Expected a new valid trace, got %q", traceparent)
+		}
+		access := decodeLogEvent(t, mockWriter.Messages[0])
+		if access.Level != INFO || access.Fields["status"] != float64(http.StatusOK) {
+			t.Errorf("Expected an INFO 200, got %s %v", access.Level, access.Fields["status"])
+		}
+	})
+
+	t.Run("Panics become ERROR events and 500", func(t *testing.T) {
+		mockWriter := &mocks.MockMessageWriter{}
+		logger := newKafkaLogger(mockWriter, "test-service", WithFlightRecorder(FlightRecorderConfig{}))
+
+		handler := logger.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			LoggerFromContext(r.Context(), logger).Debug("about to fail", nil)
+			panic(errors.New("nil map"))
+		}))
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/orders", nil))
+
+		if rec.Code != http.StatusInternalServerError {
+			t.Errorf("Expected 500, got %d", rec.Code)
+		}
+		assertMessages(t, mockWriter, "about to fail", "panic serving request", "request completed")
+
+		panicEvent := decodeLogEvent(t, mockWriter.Messages[1])
+		if panicEvent.Level != ERROR || panicEvent.Fields["panic"] != "nil map" || panicEvent.Error == nil {
+			t.Errorf("Unexpected panic event %+v", panicEvent)
+		}
+		if !strings.Contains(panicEvent.Stack, "TestMiddleware.func3.1") {
+			t.Errorf("Expected the stack to reach the panicking handler, got:\n%s", panicEvent.Stack)
+		}
+		assertCaller(t, panicEvent.Caller, "middleware_test.go", "TestMiddleware.func3.1")
+		if access := decodeLogEvent(t, mockWriter.Messages[2]); access.Level != ERROR || access.Fields["status"] != float64(500) {
+			t.Errorf("Expected an ERROR 500 access log, got %s %v", access.Level, access.Fields["status"])
+		}
+	})
+
+	t.Run("Panics keep their stack without stack trace levels", func(t *testing.T) {
+		mockWriter := &mocks.MockMessageWriter{}
+		logger := newKafkaLogger(mockWriter, "test-service", WithStackTraces())
+
+		handler := logger.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			panic("out of range")
+		}))
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/orders", nil))
+
+		assertMessages(t, mockWriter, "panic serving request", "request completed")
+		panicEvent := decodeLogEvent(t, mockWriter.Messages[0])
+		if !strings.Contains(panicEvent.Stack, "TestMiddleware.func4.1") {
+			t.Errorf("Expected the stack to reach the panicking handler, got:\n%s", panicEvent.Stack)
+		}
+		if access := decodeLogEvent(t, mockWriter.Messages[1]); access.Stack != "" {
+			t.Errorf("Expected no stack on the access log, got:\n%s", access.Stack)
+		}
+	})
+
+	t.Run("Faults point at the faulting handler", func(t *testing.T) {
+		mockWriter := &mocks.MockMessageWriter{}
+		logger := newKafkaLogger(mockWriter, "test-service")
+
+		handler := logger.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			var orders map[string]int
+			orders["new"]++
+		}))
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/orders", nil))
+
+		assertCaller(t, decodeLogEvent(t, mockWriter.Messages[0]).Caller, "middleware_test.go", "TestMiddleware.func5.1")
+	})
+
+	t.Run("Hijacked connections", func(t *testing.T) {
+		mockWriter := &mocks.MockMessageWriter{}
+		logger := newKafkaLogger(mockWriter, "test-service")
+
+		handler := logger.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if _, _, err := http.NewResponseController(w).Hijack(); err != nil {
+				t.Errorf("Expected the connection to be hijacked, got: %v", err)
+			}
+		}))
+		rec := &hijackRecorder{ResponseRecorder: httptest.NewRecorder()}
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/ws", nil))
+
+		if !rec.hijacked {
+			t.Error("Expected Hijack to reach the underlying writer")
+		}
+		if access := decodeLogEvent(t, mockWriter.Messages[0]); access.Fields["status"] != float64(http.StatusSwitchingProtocols) {
+			t.Errorf("Expected status 101 in the access log, got %v", access.Fields["status"])
+		}
+	})
+
+	t.Run("Aborted handlers are not recovered", func(t *testing.T) {
+		logger := newKafkaLogger(&mocks.MockMessageWriter{}, "test-service")
+		handler := logger.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			panic(http.ErrAbortHandler)
+		}))
+
+		defer func() {
+			if p := recover(); p != http.ErrAbortHandler {
+				t.Errorf("Expected ErrAbortHandler to propagate, got %v", p)
+			}
+		}()
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+	})
+}
+
+func TestParseTraceparent(t *testing.T) {
+	tests := []struct {
+		header string
+		valid  bool
+	}{
+		{"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", true},
+		{"01-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-00-future", true},
+		{"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-extra", false},
+		{"ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", false},
+		{"00-4BF92F3577B34DA6A3CE929D0E0E4736-00f067aa0ba902b7-01", false},
+		{"00-4bf92f3577b34da6a3ce929d0e0e4736-0000000000000000-01", false},
+		{"00-4bf92f35-00f067aa0ba902b7-01", false},
+		{"", false},
+	}
+	for _, tt := range tests {
+		if _, err := parseTraceparent(tt.header); (err == nil) != tt.valid {
+			t.Errorf("parseTraceparent(%q): expected valid=%v, got %v", tt.header, tt.valid, err)
+		}
+	}
+}
+
+// hijackRecorder is a ResponseRecorder that supports http.Hijacker.
+type hijackRecorder struct {
+	*httptest.ResponseRecorder
+	hijacked bool
+}
+
+func (rec *hijackRecorder) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	rec.hijacked = true
+	return nil, nil, nil
+}
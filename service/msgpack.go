@@ -0,0 +1,576 @@
+package service
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+	"time"
+)
+
+// MsgpackCodec writes events as a MessagePack map. Integers, floats and
+// timestamps keep their types, unlike JSON where every number is a float64.
+type MsgpackCodec struct{}
+
+const msgpackTimestampExt = -1
+
+var errMsgpackShort = errors.New("msgpack: unexpected end of data")
+
+func (MsgpackCodec) ContentType() string {
+	return ContentTypeMsgpack
+}
+
+func (MsgpackCodec) Encode(event LogEvent) ([]byte, error) {
+	size := 4
+	if len(event.Fields) > 0 {
+		size++
+	}
+	if event.Caller != nil {
+		size++
+	}
+	if event.Stack != "" {
+		size++
+	}
+	if event.Error != nil {
+		size++
+	}
+	if event.ID != "" {
+		size++
+	}
+	if event.ProducerID != "" {
+		size += 2
+	}
+
+	e := &msgpackEncoder{buf: make([]byte, 0, 128)}
+	e.writeMapHeader(size)
+	e.writeString("timestamp")
+	e.writeTime(event.Timestamp)
+	e.writeString("level")
+	e.writeString(string(event.Level))
+	e.writeString("message")
+	e.writeString(event.Message)
+	e.writeString("service")
+	e.writeString(event.Service)
+	if len(event.Fields) > 0 {
+		e.writeString("fields")
+		if err := e.writeValue(event.Fields); err != nil {
+			return nil, err
+		}
+	}
+	if event.Caller != nil {
+		e.writeString("caller")
+		e.writeMapHeader(3)
+		e.writeString("file")
+		e.writeString(event.Caller.File)
+		e.writeString("line")
+		if err := e.writeValue(int64(event.Caller.Line)); err != nil {
+			return nil, err
+		}
+		e.writeString("function")
+		e.writeString(event.Caller.Function)
+	}
+	if event.Stack != "" {
+		e.writeString("stack")
+		e.writeString(event.Stack)
+	}
+	if event.Error != nil {
+		e.writeString("error")
+		if err := e.writeValue(errorInfoMap(event.Error)); err != nil {
+			return nil, err
+		}
+	}
+	if event.ID != "" {
+		e.writeString("event_id")
+		e.writeString(event.ID)
+	}
+	if event.ProducerID != "" {
+		e.writeString("producer_id")
+		e.writeString(event.ProducerID)
+		e.writeString("sequence")
+		e.writeUint(event.Sequence)
+	}
+	return e.buf, nil
+}
+
+func (MsgpackCodec) Decode(data []byte, event *LogEvent) error {
+	d := &msgpackDecoder{data: data}
+	value, err := d.readValue()
+	if err != nil {
+		return err
+	}
+	if d.pos != len(data) {
+		return fmt.Errorf("msgpack: %d trailing bytes", len(data)-d.pos)
+	}
+
+	m, ok := value.(map[string]any)
+	if !ok {
+		return fmt.Errorf("msgpack: expected map, got %T", value)
+	}
+
+	*event = LogEvent{}
+	if ts, ok := m["timestamp"].(time.Time); ok {
+		event.Timestamp = ts
+	}
+	level, _ := m["level"].(string)
+	event.Level = LogLevel(level)
+	event.Message, _ = m["message"].(string)
+	event.Service, _ = m["service"].(string)
+	if fields, ok := m["fields"].(map[string]any); ok {
+		event.Fields = fields
+	}
+	if caller, ok := m["caller"].(map[string]any); ok {
+		event.Caller = &Caller{}
+		event.Caller.File, _ = caller["file"].(string)
+		event.Caller.Function, _ = caller["function"].(string)
+		if line, ok := caller["line"].(int64); ok {
+			event.Caller.Line = int(line)
+		}
+	}
+	event.Stack, _ = m["stack"].(string)
+	if info, ok := m["error"].(map[string]any); ok {
+		event.Error = errorInfoFromMap(info)
+	}
+	event.ID, _ = m["event_id"].(string)
+	event.ProducerID, _ = m["producer_id"].(string)
+	switch seq := m["sequence"].(type) {
+	case int64:
+		event.Sequence = uint64(seq)
+	case uint64:
+		event.Sequence = seq
+	}
+	return nil
+}
+
+func errorInfoMap(info *ErrorInfo) map[string]any {
+	m := map[string]any{"message": info.Message, "type": info.Type}
+	if info.Stack != "" {
+		m["stack"] = info.Stack
+	}
+	if len(info.Causes) > 0 {
+		causes := make([]any, len(info.Causes))
+		for i, cause := range info.Causes {
+			causes[i] = errorInfoMap(cause)
+		}
+		m["causes"] = causes
+	}
+	return m
+}
+
+func errorInfoFromMap(m map[string]any) *ErrorInfo {
+	info := &ErrorInfo{}
+	info.Message, _ = m["message"].(string)
+	info.Type, _ = m["type"].(string)
+	info.Stack, _ = m["stack"].(string)
+	causes, _ := m["causes"].([]any)
+	for _, cause := range causes {
+		if c, ok := cause.(map[string]any); ok {
+			info.Causes = append(info.Causes, errorInfoFromMap(c))
+		}
+	}
+	return info
+}
+
+type msgpackEncoder struct {
+	buf []byte
+}
+
+func (e *msgpackEncoder) writeValue(value any) error {
+	switch v := value.(type) {
+	case nil:
+		e.buf = append(e.buf, 0xc0)
+	case bool:
+		if v {
+			e.buf = append(e.buf, 0xc3)
+		} else {
+			e.buf = append(e.buf, 0xc2)
+		}
+	case int:
+		e.writeInt(int64(v))
+	case int8:
+		e.writeInt(int64(v))
+	case int16:
+		e.writeInt(int64(v))
+	case int32:
+		e.writeInt(int64(v))
+	case int64:
+		e.writeInt(v)
+	case uint:
+		e.writeUint(uint64(v))
+	case uint8:
+		e.writeUint(uint64(v))
+	case uint16:
+		e.writeUint(uint64(v))
+	case uint32:
+		e.writeUint(uint64(v))
+	case uint64:
+		e.writeUint(v)
+	case float32:
+		e.buf = append(e.buf, 0xca)
+		e.buf = binary.BigEndian.AppendUint32(e.buf, math.Float32bits(v))
+	case float64:
+		e.buf = append(e.buf, 0xcb)
+		e.buf = binary.BigEndian.AppendUint64(e.buf, math.Float64bits(v))
+	case string:
+		e.writeString(v)
+	case []byte:
+		e.writeBinary(v)
+	case time.Time:
+		e.writeTime(v)
+	case time.Duration:
+		e.writeString(v.String())
+	case json.Number:
+		if i, err := v.Int64(); err == nil {
+			e.writeInt(i)
+		} else if f, err := v.Float64(); err == nil {
+			return e.writeValue(f)
+		} else {
+			e.writeString(v.String())
+		}
+	case error:
+		e.writeString(v.Error())
+	case map[string]any:
+		e.writeMapHeader(len(v))
+		for key, item := range v {
+			e.writeString(key)
+			if err := e.writeValue(item); err != nil {
+				return err
+			}
+		}
+	case []any:
+		e.writeArrayHeader(len(v))
+		for _, item := range v {
+			if err := e.writeValue(item); err != nil {
+				return err
+			}
+		}
+	default:
+		return e.writeReflected(value)
+	}
+	return nil
+}
+
+// writeReflected handles slices, maps and structs by converting them to
+// generic JSON values first.
+func (e *msgpackEncoder) writeReflected(value any) error {
+	rv := reflect.ValueOf(value)
+	if rv.Kind() == reflect.Slice || rv.Kind() == reflect.Array {
+		items := make([]any, rv.Len())
+		for i := range items {
+			items[i] = rv.Index(i).Interface()
+		}
+		return e.writeValue(items)
+	}
+
+	data, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Errorf("msgpack: cannot encode %T: %w", value, err)
+	}
+	var generic any
+	if err := json.Unmarshal(data, &generic); err != nil {
+		return fmt.Errorf("msgpack: cannot encode %T: %w", value, err)
+	}
+	return e.writeValue(generic)
+}
+
+func (e *msgpackEncoder) writeInt(v int64) {
+	switch {
+	case v >= 0:
+		e.writeUint(uint64(v))
+	case v >= -32:
+		e.buf = append(e.buf, byte(v))
+	case v >= math.MinInt8:
+		e.buf = append(e.buf, 0xd0, byte(v))
+	case v >= math.MinInt16:
+		e.buf = append(e.buf, 0xd1)
+		e.buf = binary.BigEndian.AppendUint16(e.buf, uint16(v))
+	case v >= math.MinInt32:
+		e.buf = append(e.buf, 0xd2)
+		e.buf = binary.BigEndian.AppendUint32(e.buf, uint32(v))
+	default:
+		e.buf = append(e.buf, 0xd3)
+		e.buf = binary.BigEndian.AppendUint64(e.buf, uint64(v))
+	}
+}
+
+func (e *msgpackEncoder) writeUint(v uint64) {
+	switch {
+	case v <= 0x7f:
+		e.buf = append(e.buf, byte(v))
+	case v <= math.MaxUint8:
+		e.buf = append(e.buf, 0xcc, byte(v))
+	case v <= math.MaxUint16:
+		e.buf = append(e.buf, 0xcd)
+		e.buf = binary.BigEndian.AppendUint16(e.buf, uint16(v))
+	case v <= math.MaxUint32:
+		e.buf = append(e.buf, 0xce)
+		e.buf = binary.BigEndian.AppendUint32(e.buf, uint32(v))
+	default:
+		e.buf = append(e.buf, 0xcf)
+		e.buf = binary.BigEndian.AppendUint64(e.buf, v)
+	}
+}
+
+func (e *msgpackEncoder) writeString(s string) {
+	n := len(s)
+	switch {
+	case n < 32:
+		e.buf = append(e.buf, 0xa0|byte(n))
+	case n <= math.MaxUint8:
+		e.buf = append(e.buf, 0xd9, byte(n))
+	case n <= math.MaxUint16:
+		e.buf = append(e.buf, 0xda)
+		e.buf = binary.BigEndian.AppendUint16(e.buf, uint16(n))
+	default:
+		e.buf = append(e.buf, 0xdb)
+		e.buf = binary.BigEndian.AppendUint32(e.buf, uint32(n))
+	}
+	e.buf = append(e.buf, s...)
+}
+
+func (e *msgpackEncoder) writeBinary(b []byte) {
+	n := len(b)
+	switch {
+	case n <= math.MaxUint8:
+		e.buf = append(e.buf, 0xc4, byte(n))
+	case n <= math.MaxUint16:
+		e.buf = append(e.buf, 0xc5)
+		e.buf = binary.BigEndian.AppendUint16(e.buf, uint16(n))
+	default:
+		e.buf = append(e.buf, 0xc6)
+		e.buf = binary.BigEndian.AppendUint32(e.buf, uint32(n))
+	}
+	e.buf = append(e.buf, b...)
+}
+
+func (e *msgpackEncoder) writeMapHeader(n int) {
+	switch {
+	case n < 16:
+		e.buf = append(e.buf, 0x80|byte(n))
+	case n <= math.MaxUint16:
+		e.buf = append(e.buf, 0xde)
+		e.buf = binary.BigEndian.AppendUint16(e.buf, uint16(n))
+	default:
+		e.buf = append(e.buf, 0xdf)
+		e.buf = binary.BigEndian.AppendUint32(e.buf, uint32(n))
+	}
+}
+
+func (e *msgpackEncoder) writeArrayHeader(n int) {
+	switch {
+	case n < 16:
+		e.buf = append(e.buf, 0x90|byte(n))
+	case n <= math.MaxUint16:
+		e.buf = append(e.buf, 0xdc)
+		e.buf = binary.BigEndian.AppendUint16(e.buf, uint16(n))
+	default:
+		e.buf = append(e.buf, 0xdd)
+		e.buf = binary.BigEndian.AppendUint32(e.buf, uint32(n))
+	}
+}
+
+// writeTime uses the 96-bit form of the timestamp extension type, which
+// covers every time.Time. Time zones are not part of the format.
+func (e *msgpackEncoder) writeTime(t time.Time) {
+	e.buf = append(e.buf, 0xc7, 12, 0xff) // extension type -1
+	e.buf = binary.BigEndian.AppendUint32(e.buf, uint32(t.Nanosecond()))
+	e.buf = binary.BigEndian.AppendUint64(e.buf, uint64(t.Unix()))
+}
+
+type msgpackDecoder struct {
+	data []byte
+	pos  int
+}
+
+func (d *msgpackDecoder) next(n int) ([]byte, error) {
+	if n < 0 || d.pos+n > len(d.data) {
+		return nil, errMsgpackShort
+	}
+	b := d.data[d.pos : d.pos+n]
+	d.pos += n
+	return b, nil
+}
+
+func (d *msgpackDecoder) readUint(n int) (uint64, error) {
+	b, err := d.next(n)
+	if err != nil {
+		return 0, err
+	}
+	switch n {
+	case 1:
+		return uint64(b[0]), nil
+	case 2:
+		return uint64(binary.BigEndian.Uint16(b)), nil
+	case 4:
+		return uint64(binary.BigEndian.Uint32(b)), nil
+	default:
+		return binary.BigEndian.Uint64(b), nil
+	}
+}
+
+// readValue decodes one value. Integers decode as int64, or uint64 when they
+// do not fit.
+func (d *msgpackDecoder) readValue() (any, error) {
+	b, err := d.next(1)
+	if err != nil {
+		return nil, err
+	}
+	c := b[0]
+
+	switch {
+	case c <= 0x7f:
+		return int64(c), nil
+	case c >= 0xe0:
+		return int64(int8(c)), nil
+	case c&0xf0 == 0x80:
+		return d.readMap(int(c & 0x0f))
+	case c&0xf0 == 0x90:
+		return d.readArray(int(c & 0x0f))
+	case c&0xe0 == 0xa0:
+		return d.readString(int(c & 0x1f))
+	}
+
+	switch c {
+	case 0xc0:
+		return nil, nil
+	case 0xc2:
+		return false, nil
+	case 0xc3:
+		return true, nil
+	case 0xc4, 0xc5, 0xc6:
+		n, err := d.readUint(1 << (c - 0xc4))
+		if err != nil {
+			return nil, err
+		}
+		raw, err := d.next(int(n))
+		if err != nil {
+			return nil, err
+		}
+		return append([]byte(nil), raw...), nil
+	case 0xc7, 0xc8, 0xc9:
+		n, err := d.readUint(1 << (c - 0xc7))
+		if err != nil {
+			return nil, err
+		}
+		return d.readExt(int(n))
+	case 0xca:
+		v, err := d.readUint(4)
+		return float64(math.Float32frombits(uint32(v))), err
+	case 0xcb:
+		v, err := d.readUint(8)
+		return math.Float64frombits(v), err
+	case 0xcc, 0xcd, 0xce, 0xcf:
+		v, err := d.readUint(1 << (c - 0xcc))
+		if err != nil {
+			return nil, err
+		}
+		if v > math.MaxInt64 {
+			return v, nil
+		}
+		return int64(v), nil
+	case 0xd0:
+		v, err := d.readUint(1)
+		return int64(int8(v)), err
+	case 0xd1:
+		v, err := d.readUint(2)
+		return int64(int16(v)), err
+	case 0xd2:
+		v, err := d.readUint(4)
+		return int64(int32(v)), err
+	case 0xd3:
+		v, err := d.readUint(8)
+		return int64(v), err
+	case 0xd4, 0xd5, 0xd6, 0xd7, 0xd8:
+		return d.readExt(1 << (c - 0xd4))
+	case 0xd9, 0xda, 0xdb:
+		n, err := d.readUint(1 << (c - 0xd9))
+		if err != nil {
+			return nil, err
+		}
+		return d.readString(int(n))
+	case 0xdc, 0xdd:
+		n, err := d.readUint(2 << (c - 0xdc))
+		if err != nil {
+			return nil, err
+		}
+		return d.readArray(int(n))
+	case 0xde, 0xdf:
+		n, err := d.readUint(2 << (c - 0xde))
+		if err != nil {
+			return nil, err
+		}
+		return d.readMap(int(n))
+	}
+
+	return nil, fmt.Errorf("msgpack: unsupported type byte 0x%02x", c)
+}
+
+func (d *msgpackDecoder) readString(n int) (string, error) {
+	b, err := d.next(n)
+	if err != nil {
+		return "", err
+	}
+	return string(b), nil
+}
+
+func (d *msgpackDecoder) readArray(n int) ([]any, error) {
+	if n > len(d.data)-d.pos {
+		return nil, errMsgpackShort
+	}
+	items := make([]any, n)
+	for i := range items {
+		item, err := d.readValue()
+		if err != nil {
+			return nil, err
+		}
+		items[i] = item
+	}
+	return items, nil
+}
+
+func (d *msgpackDecoder) readMap(n int) (map[string]any, error) {
+	if n > len(d.data)-d.pos {
+		return nil, errMsgpackShort
+	}
+	m := make(map[string]any, n)
+	for range n {
+		key, err := d.readValue()
+		if err != nil {
+			return nil, err
+		}
+		value, err := d.readValue()
+		if err != nil {
+			return nil, err
+		}
+		m[fmt.Sprint(key)] = value
+	}
+	return m, nil
+}
+
+func (d *msgpackDecoder) readExt(n int) (any, error) {
+	typ, err := d.next(1)
+	if err != nil {
+		return nil, err
+	}
+	payload, err := d.next(n)
+	if err != nil {
+		return nil, err
+	}
+	if int8(typ[0]) != msgpackTimestampExt {
+		return append([]byte(nil), payload...), nil
+	}
+
+	switch n {
+	case 4:
+		return time.Unix(int64(binary.BigEndian.Uint32(payload)), 0).UTC(), nil
+	case 8:
+		v := binary.BigEndian.Uint64(payload)
+		return time.Unix(int64(v&0x3ffffffff), int64(v>>34)).UTC(), nil
+	case 12:
+		nsec := binary.BigEndian.Uint32(payload[:4])
+		sec := int64(binary.BigEndian.Uint64(payload[4:]))
+		return time.Unix(sec, int64(nsec)).UTC(), nil
+	}
+	return nil, fmt.Errorf("msgpack: bad timestamp length %d", n)
+}
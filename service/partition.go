@@ -0,0 +1,134 @@
+package service
+
+import (
+	"fmt"
+	"hash/fnv"
+	"strconv"
+	"strings"
+
+	"github.com/segmentio/kafka-go"
+)
+
+// KeyStrategy chooses the Kafka message key for an event. Together with a
+// key-aware balancer it decides which partition, and so which ordering, an
+// event gets. A nil key leaves the choice to the balancer.
+type KeyStrategy interface {
+	Key(event LogEvent) []byte
+}
+
+// KeyFunc adapts a function to KeyStrategy.
+type KeyFunc func(event LogEvent) []byte
+
+func (f KeyFunc) Key(event LogEvent) []byte {
+	return f(event)
+}
+
+// KeyByService keys events by service name. This is the default.
+func KeyByService() KeyStrategy {
+	return KeyFunc(func(event LogEvent) []byte {
+		return []byte(event.Service)
+	})
+}
+
+// KeyByField keys events by the value of a field, such as request_id or
+// trace_id, so all events sharing it land on one partition in order. Events
+// without the field fall back to the service name.
+func KeyByField(name string) KeyStrategy {
+	return KeyFunc(func(event LogEvent) []byte {
+		value, ok := lookupField(event.Fields, name)
+		if !ok {
+			return []byte(event.Service)
+		}
+		return []byte(fieldString(value))
+	})
+}
+
+// KeyByFieldHash keys events by a hash over several fields, for ordering on a
+// combination such as tenant and user. Missing fields hash as empty.
+func KeyByFieldHash(names ...string) KeyStrategy {
+	return KeyFunc(func(event LogEvent) []byte {
+		h := fnv.New64a()
+		for _, name := range names {
+			if value, ok := lookupField(event.Fields, name); ok {
+				h.Write([]byte(fieldString(value)))
+			}
+			h.Write([]byte{0})
+		}
+		return []byte(strconv.FormatUint(h.Sum64(), 16))
+	})
+}
+
+// NoKey leaves messages unkeyed so the balancer spreads them evenly.
+func NoKey() KeyStrategy {
+	return KeyFunc(func(LogEvent) []byte {
+		return nil
+	})
+}
+
+// ParseKeyStrategy reads a key strategy from configuration: "service",
+// "none", "field:<name>" or "hash:<name>,<name>...".
+func ParseKeyStrategy(spec string) (KeyStrategy, error) {
+	kind, arg, _ := strings.Cut(strings.TrimSpace(spec), ":")
+	switch strings.ToLower(kind) {
+	case "", "service":
+		return KeyByService(), nil
+	case "none":
+		return NoKey(), nil
+	case "field":
+		if arg == "" {
+			return nil, fmt.Errorf("partition key %q needs a field name", spec)
+		}
+		return KeyByField(arg), nil
+	case "hash":
+		names := strings.Split(arg, ",")
+		for i, name := range names {
+			names[i] = strings.TrimSpace(name)
+			if names[i] == "" {
+				return nil, fmt.Errorf("partition key %q has an empty field name", spec)
+			}
+		}
+		return KeyByFieldHash(names...), nil
+	default:
+		return nil, fmt.Errorf("unknown partition key strategy %q", spec)
+	}
+}
+
+// WithKeyStrategy sets how message keys are chosen.
+func WithKeyStrategy(strategy KeyStrategy) Option {
+	return func(o *loggerOptions) {
+		o.keys = strategy
+	}
+}
+
+// WithBalancer sets the partition balancer of the writer created by
+// NewKafkaLogger. Pair key strategies with a key-aware balancer such as
+// kafka.Hash or kafka.Murmur2Balancer.
+func WithBalancer(balancer kafka.Balancer) Option {
+	return func(o *loggerOptions) {
+		o.balancer = balancer
+	}
+}
+
+// lookupField finds a field by name, following dots into nested maps when
+// there is no top-level field with the full name.
+func lookupField(fields map[string]any, name string) (any, bool) {
+	if value, ok := fields[name]; ok {
+		return value, true
+	}
+	head, rest, found := strings.Cut(name, ".")
+	if !found {
+		return nil, false
+	}
+	sub, ok := fields[head].(map[string]any)
+	if !ok {
+		return nil, false
+	}
+	return lookupField(sub, rest)
+}
+
+func fieldString(value any) string {
+	if s, ok := value.(string); ok {
+		return s
+	}
+	return fmt.Sprint(value)
+}
@@ -0,0 +1,117 @@
+package service
+
+import (
+	"context"
+	"kafka-logger/mocks"
+	"testing"
+
+	"github.com/segmentio/kafka-go"
+)
+
+func TestKeyStrategies(t *testing.T) {
+	event := LogEvent{
+		Service: "checkout",
+		Fields: map[string]any{
+			"request_id": "req-1",
+			"tenant":     "acme",
+			"user_id":    42,
+			"http":       map[string]any{"route": "/cart"},
+		},
+	}
+
+	tests := []struct {
+		name     string
+		strategy KeyStrategy
+		expected string
+	}{
+		{"service", KeyByService(), "checkout"},
+		{"field", KeyByField("request_id"), "req-1"},
+		{"non-string field", KeyByField("user_id"), "42"},
+		{"nested field", KeyByField("http.route"), "/cart"},
+		{"missing field falls back to service", KeyByField("trace_id"), "checkout"},
+		{"none", NoKey(), ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if key := string(tt.strategy.Key(event)); key != tt.expected {
+				t.Errorf("Expected key '%s', got '%s'", tt.expected, key)
+			}
+		})
+	}
+
+	t.Run("hash", func(t *testing.T) {
+		strategy := KeyByFieldHash("tenant", "user_id")
+		key := string(strategy.Key(event))
+		if key == "" {
+			t.Fatal("Expected a non-empty key")
+		}
+
+		same := LogEvent{Service: "other", Fields: map[string]any{"tenant": "acme", "user_id": 42}}
+		if string(strategy.Key(same)) != key {
+			t.Error("Expected the same fields to give the same key")
+		}
+
+		swapped := LogEvent{Fields: map[string]any{"tenant": "42", "user_id": "acme"}}
+		if string(strategy.Key(swapped)) == key {
+			t.Error("Expected field order to matter")
+		}
+	})
+}
+
+func TestParseKeyStrategy(t *testing.T) {
+	event := LogEvent{Service: "checkout", Fields: map[string]any{"trace_id": "t-1"}}
+
+	for spec, expected := range map[string]string{
+		"":               "checkout",
+		"service":        "checkout",
+		"none":           "",
+		"field:trace_id": "t-1",
+	} {
+		strategy, err := ParseKeyStrategy(spec)
+		if err != nil {
+			t.Errorf("Expected %q to parse, got: %v", spec, err)
+			continue
+		}
+		if key := string(strategy.Key(event)); key != expected {
+			t.Errorf("Expected key '%s' for %q, got '%s'", expected, spec, key)
+		}
+	}
+
+	if _, err := ParseKeyStrategy("hash:tenant, user_id"); err != nil {
+		t.Errorf("Expected hash strategy to parse, got: %v", err)
+	}
+	for _, spec := range []string{"field:", "hash:a,,b", "random"} {
+		if _, err := ParseKeyStrategy(spec); err == nil {
+			t.Errorf("Expected error for %q", spec)
+		}
+	}
+}
+
+func TestLoggerKeyStrategy(t *testing.T) {
+	mockWriter := &mocks.MockMessageWriter{}
+	logger := newKafkaLogger(mockWriter, "checkout", WithKeyStrategy(KeyByField("trace_id")))
+
+	ctx := ContextWithTraceID(context.Background(), "trace-abc")
+	checkNoError(t, logger.InfoContext(ctx, "from context", nil))
+	checkNoError(t, logger.With(map[string]any{"trace_id": "trace-bound"}).Info("bound", nil))
+	checkNoError(t, logger.Info("no trace", nil))
+
+	for i, expected := range []string{"trace-abc", "trace-bound", "checkout"} {
+		if key := string(mockWriter.Messages[i].Key); key != expected {
+			t.Errorf("Expected key '%s' for message %d, got '%s'", expected, i, key)
+		}
+	}
+}
+
+func TestWithBalancer(t *testing.T) {
+	logger := NewKafkaLogger([]string{"localhost:9092"}, "test-topic", "checkout", WithBalancer(&kafka.Hash{}))
+	defer logger.Close()
+
+	writer, ok := logger.writer.(*kafka.Writer)
+	if !ok {
+		t.Fatalf("Expected a *kafka.Writer, got %T", logger.writer)
+	}
+	if _, ok := writer.Balancer.(*kafka.Hash); !ok {
+		t.Errorf("Expected the hash balancer, got %T", writer.Balancer)
+	}
+}
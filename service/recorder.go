@@ -0,0 +1,134 @@
+package service
+
+import "sync"
+
+const defaultRecorderSize = 100
+
+// FlightRecorderConfig configures flight recorder mode. Inside a scope
+// created with Scope, low-level events are kept in memory instead of being
+// published. When an event at FlushOn or above is logged in the scope, the
+// buffered events are published first, so the error arrives with the context
+// that led up to it. Events still buffered when the scope ends are discarded.
+type FlightRecorderConfig struct {
+	// Size is the number of events kept per scope; older events are
+	// overwritten. Defaults to 100.
+	Size int
+	// BufferBelow is the level below which events are buffered. Defaults to
+	// INFO, so only DEBUG is buffered.
+	BufferBelow LogLevel
+	// FlushOn is the level that flushes the buffer. Defaults to ERROR.
+	FlushOn LogLevel
+}
+
+// WithFlightRecorder enables flight recorder mode for scopes created from
+// the logger. Buffered events are kept even if the minimum level would
+// filter them, since that is the point of recording them.
+func WithFlightRecorder(cfg FlightRecorderConfig) Option {
+	if cfg.Size <= 0 {
+		cfg.Size = defaultRecorderSize
+	}
+	if cfg.BufferBelow == "" {
+		cfg.BufferBelow = INFO
+	}
+	if cfg.FlushOn == "" {
+		cfg.FlushOn = ERROR
+	}
+	return func(o *loggerOptions) {
+		o.recorder = &cfg
+	}
+}
+
+// Scope returns a child logger with fields bound, as With does, and its own
+// flight recorder buffer shared by all loggers derived from it. Call EndScope
+// when the unit of work, such as a request, is finished. Without
+// WithFlightRecorder, Scope is the same as With.
+func (kl *KafkaLogger) Scope(fields map[string]any) *KafkaLogger {
+	child := kl.With(fields)
+	if kl.recorder != nil {
+		child.scope = newFlightScope(*kl.recorder)
+	}
+	return child
+}
+
+// EndScope discards the events still buffered for the scope. Afterwards the
+// logger publishes events as if it had no scope.
+func (kl *KafkaLogger) EndScope() {
+	kl.scope.end()
+}
+
+// flightScope is a ring buffer of events for one scope. A nil scope buffers
+// nothing, so loggers outside a scope can call its methods freely.
+type flightScope struct {
+	cfg FlightRecorderConfig
+
+	mu     sync.Mutex
+	events []LogEvent
+	start  int
+	count  int
+	ended  bool
+}
+
+func newFlightScope(cfg FlightRecorderConfig) *flightScope {
+	return &flightScope{cfg: cfg, events: make([]LogEvent, cfg.Size)}
+}
+
+func (s *flightScope) active() bool {
+	if s == nil {
+		return false
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return !s.ended
+}
+
+func (s *flightScope) buffers(level LogLevel) bool {
+	return s.active() && !level.AtLeast(s.cfg.BufferBelow)
+}
+
+func (s *flightScope) flushedBy(level LogLevel) bool {
+	return s.active() && level.AtLeast(s.cfg.FlushOn)
+}
+
+func (s *flightScope) record(event LogEvent) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.ended {
+		return
+	}
+
+	end := (s.start + s.count) % len(s.events)
+	s.events[end] = event
+	if s.count < len(s.events) {
+		s.count++
+	} else {
+		s.start = (s.start + 1) % len(s.events)
+	}
+}
+
+// drain returns the buffered events, oldest first, and empties the buffer.
+func (s *flightScope) drain() []LogEvent {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	drained := make([]LogEvent, s.count)
+	for i := range drained {
+		drained[i] = s.events[(s.start+i)%len(s.events)]
+	}
+	s.reset()
+	return drained
+}
+
+func (s *flightScope) end() {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.ended = true
+	s.reset()
+}
+
+func (s *flightScope) reset() {
+	clear(s.events)
+	s.start, s.count = 0, 0
+}
@@ -0,0 +1,96 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"kafka-logger/mocks"
+	"log/slog"
+	"testing"
+)
+
+func TestFlightRecorder(t *testing.T) {
+	t.Run("ERROR flushes the scope's buffer first", func(t *testing.T) {
+		mockWriter := &mocks.MockMessageWriter{}
+		logger := newKafkaLogger(mockWriter, "test-service",
+			WithLevel(NewLevelVar(INFO)),
+			WithFlightRecorder(FlightRecorderConfig{Size: 3}))
+
+		scope := logger.Scope(map[string]any{"request_id": "req-1"})
+		defer scope.EndScope()
+
+		for i := range 5 {
+			checkNoError(t, scope.Debug(fmt.Sprintf("step %d", i), nil))
+		}
+		checkNoError(t, scope.Info("shipped right away", nil))
+		assertMessages(t, mockWriter, "shipped right away")
+
+		checkNoError(t, scope.With(map[string]any{"db": "orders"}).Error("failed", nil))
+		assertMessages(t, mockWriter, "shipped right away", "step 2", "step 3", "step 4", "failed")
+
+		for _, msg := range mockWriter.Messages[1:] {
+			if decodeLogEvent(t, msg).Fields["request_id"] != "req-1" {
+				t.Errorf("Expected flushed events to keep the scope fields")
+			}
+		}
+
+		checkNoError(t, scope.Error("again", nil))
+		assertMessages(t, mockWriter, "shipped right away", "step 2", "step 3", "step 4", "failed", "again")
+	})
+
+	t.Run("Buffered events are discarded when the scope ends", func(t *testing.T) {
+		mockWriter := &mocks.MockMessageWriter{}
+		logger := newKafkaLogger(mockWriter, "test-service", WithFlightRecorder(FlightRecorderConfig{}))
+
+		scope := logger.Scope(nil)
+		checkNoError(t, scope.Debug("discarded", nil))
+		scope.EndScope()
+
+		checkNoError(t, scope.Error("after the scope", nil))
+		checkNoError(t, scope.Debug("published normally", nil))
+		assertMessages(t, mockWriter, "after the scope", "published normally")
+	})
+
+	t.Run("Scopes are independent", func(t *testing.T) {
+		mockWriter := &mocks.MockMessageWriter{}
+		logger := newKafkaLogger(mockWriter, "test-service", WithFlightRecorder(FlightRecorderConfig{}))
+
+		first := logger.Scope(map[string]any{"request_id": "a"})
+		second := logger.Scope(map[string]any{"request_id": "b"})
+		checkNoError(t, first.Debug("from a", nil))
+		checkNoError(t, second.Debug("from b", nil))
+
+		checkNoError(t, second.Error("b failed", nil))
+		assertMessages(t, mockWriter, "from b", "b failed")
+
+		checkNoError(t, logger.Debug("unscoped", nil))
+		assertMessages(t, mockWriter, "from b", "b failed", "unscoped")
+	})
+
+	t.Run("Context fields and slog records are buffered", func(t *testing.T) {
+		mockWriter := &mocks.MockMessageWriter{}
+		logger := newKafkaLogger(mockWriter, "test-service",
+			WithLevel(NewLevelVar(ERROR)),
+			WithFlightRecorder(FlightRecorderConfig{BufferBelow: ERROR}))
+		scope := logger.Scope(nil)
+		slogger := slog.New(NewSlogHandler(scope, nil))
+
+		ctx := ContextWithTraceID(context.Background(), "trace-1")
+		slogger.InfoContext(ctx, "from slog")
+		checkNoError(t, scope.WarnContext(ctx, "warned", nil))
+		assertMessages(t, mockWriter)
+
+		slogger.Error("boom")
+		assertMessages(t, mockWriter, "from slog", "warned", "boom")
+		if decodeLogEvent(t, mockWriter.Messages[0]).Fields["trace_id"] != "trace-1" {
+			t.Error("Expected context fields captured when the event was buffered")
+		}
+	})
+
+	t.Run("Scope without a flight recorder", func(t *testing.T) {
+		mockWriter := &mocks.MockMessageWriter{}
+		scope := newKafkaLogger(mockWriter, "test-service").Scope(map[string]any{"k": "v"})
+		checkNoError(t, scope.Debug("direct", nil))
+		scope.EndScope()
+		assertMessages(t, mockWriter, "direct")
+	})
+}
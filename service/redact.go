@@ -0,0 +1,307 @@
+package service
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"kafka-logger/config"
+	"os"
+	"path"
+	"regexp"
+	"strings"
+)
+
+// RedactedValue replaces masked values and masked parts of strings.
+const RedactedValue = "[REDACTED]"
+
+type RedactAction int
+
+const (
+	RedactDrop RedactAction = iota
+	RedactMask
+	RedactHash
+)
+
+func parseRedactAction(s string) (RedactAction, error) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "drop":
+		return RedactDrop, nil
+	case "mask", "":
+		return RedactMask, nil
+	case "hash":
+		return RedactHash, nil
+	default:
+		return 0, fmt.Errorf("unknown redaction action %q", s)
+	}
+}
+
+type valuePattern struct {
+	pattern *regexp.Regexp
+	// valid filters regexp matches, e.g. by checksum, to avoid false positives.
+	valid func(match string) bool
+}
+
+var builtinValuePatterns = map[string]valuePattern{
+	"credit_card": {regexp.MustCompile(`\b(?:\d[ -]?){12,18}\d\b`), luhnValid},
+	"jwt":         {regexp.MustCompile(`\beyJ[A-Za-z0-9_-]+\.eyJ[A-Za-z0-9_-]+\.[A-Za-z0-9_-]*`), nil},
+	"email":       {regexp.MustCompile(`[A-Za-z0-9._%+-]+@[A-Za-z0-9.-]+\.[A-Za-z]{2,}`), nil},
+	"bearer":      {regexp.MustCompile(`(?i)\bbearer\s+[A-Za-z0-9._~+/-]+=*`), nil},
+}
+
+// luhnValid reports whether the digits in s pass the Luhn checksum used by
+// payment card numbers.
+func luhnValid(s string) bool {
+	sum, double := 0, false
+	for i := len(s) - 1; i >= 0; i-- {
+		c := s[i]
+		if c < '0' || c > '9' {
+			continue
+		}
+		d := int(c - '0')
+		if double {
+			d *= 2
+			if d > 9 {
+				d -= 9
+			}
+		}
+		sum += d
+		double = !double
+	}
+	return sum%10 == 0
+}
+
+type redactionRule struct {
+	// field restricts the rule to matching field names; nil matches all.
+	field func(name, path string) bool
+	// value restricts the rule to matching parts of string values; nil
+	// applies the action to the whole field.
+	value  *valuePattern
+	action RedactAction
+}
+
+// Redactor rewrites event fields according to redaction rules before they
+// are encoded. Fields are matched by name at any depth, using either the
+// field's own name or its dotted path, such as "user.email".
+type Redactor struct {
+	rules []redactionRule
+	key   []byte
+}
+
+// NewRedactor compiles the redaction rules from the configuration.
+func NewRedactor(cfg config.RedactionConfig) (*Redactor, error) {
+	r := &Redactor{key: []byte(cfg.HMACKey)}
+	// An unset or empty variable leaves HMACKey in place.
+	if key := os.Getenv(cfg.HMACKeyEnv); cfg.HMACKeyEnv != "" && key != "" {
+		r.key = []byte(key)
+	}
+
+	for i, rc := range cfg.Rules {
+		rule, err := compileRedactionRule(rc)
+		if err != nil {
+			return nil, fmt.Errorf("redaction rule %d: %w", i+1, err)
+		}
+		if rule.action == RedactHash && len(r.key) == 0 {
+			return nil, fmt.Errorf("redaction rule %d: the hash action needs an HMAC key", i+1)
+		}
+		r.rules = append(r.rules, rule)
+	}
+	return r, nil
+}
+
+func compileRedactionRule(rc config.RedactionRule) (redactionRule, error) {
+	var rule redactionRule
+	var err error
+
+	if rule.action, err = parseRedactAction(rc.Action); err != nil {
+		return rule, err
+	}
+
+	switch {
+	case rc.Field != "" && rc.FieldRegex != "":
+		return rule, fmt.Errorf("set only one of field and field_regex")
+	case rc.Field != "":
+		pattern := strings.ToLower(rc.Field)
+		if _, err := path.Match(pattern, ""); err != nil {
+			return rule, fmt.Errorf("bad field glob %q: %w", rc.Field, err)
+		}
+		rule.field = func(name, fieldPath string) bool {
+			return globMatch(pattern, strings.ToLower(name)) || globMatch(pattern, strings.ToLower(fieldPath))
+		}
+	case rc.FieldRegex != "":
+		re, err := regexp.Compile(rc.FieldRegex)
+		if err != nil {
+			return rule, fmt.Errorf("bad field_regex: %w", err)
+		}
+		rule.field = func(name, fieldPath string) bool {
+			return re.MatchString(name) || re.MatchString(fieldPath)
+		}
+	}
+
+	switch {
+	case rc.Value != "" && rc.ValueRegex != "":
+		return rule, fmt.Errorf("set only one of value and value_regex")
+	case rc.Value != "":
+		builtin, ok := builtinValuePatterns[strings.ToLower(rc.Value)]
+		if !ok {
+			return rule, fmt.Errorf("unknown value pattern %q", rc.Value)
+		}
+		rule.value = &builtin
+	case rc.ValueRegex != "":
+		re, err := regexp.Compile(rc.ValueRegex)
+		if err != nil {
+			return rule, fmt.Errorf("bad value_regex: %w", err)
+		}
+		rule.value = &valuePattern{pattern: re}
+	}
+
+	if rule.field == nil && rule.value == nil {
+		return rule, fmt.Errorf("needs a field or value matcher")
+	}
+	return rule, nil
+}
+
+func globMatch(pattern, name string) bool {
+	matched, _ := path.Match(pattern, name)
+	return matched
+}
+
+// WithRedactor applies redaction rules to the fields of every event.
+func WithRedactor(redactor *Redactor) Option {
+	return func(o *loggerOptions) {
+		o.redactor = redactor
+	}
+}
+
+// Redact returns a redacted copy of fields. The input is never modified,
+// since it may belong to the caller.
+func (r *Redactor) Redact(fields map[string]any) map[string]any {
+	if r == nil || len(r.rules) == 0 || len(fields) == 0 {
+		return fields
+	}
+	return r.redactMap(fields, "")
+}
+
+// RedactError returns a copy of info with the value rules applied to every
+// message in the chain. A message that a drop rule matches is masked
+// instead, since the error would be unreadable without it.
+func (r *Redactor) RedactError(info *ErrorInfo) *ErrorInfo {
+	if r == nil || len(r.rules) == 0 || info == nil {
+		return info
+	}
+	redacted := *info
+	if message, keep := r.redactString("message", "error.message", info.Message); keep {
+		redacted.Message = message.(string)
+	} else {
+		redacted.Message = RedactedValue
+	}
+	redacted.Causes = make([]*ErrorInfo, len(info.Causes))
+	for i, cause := range info.Causes {
+		redacted.Causes[i] = r.RedactError(cause)
+	}
+	return &redacted
+}
+
+func (r *Redactor) redactMap(fields map[string]any, prefix string) map[string]any {
+	redacted := make(map[string]any, len(fields))
+	for key, value := range fields {
+		if v, keep := r.redactValue(key, prefix+key, value); keep {
+			redacted[key] = v
+		}
+	}
+	return redacted
+}
+
+// redactValue applies the rules to one value. keep is false when the value
+// should be dropped.
+func (r *Redactor) redactValue(name, fieldPath string, value any) (redacted any, keep bool) {
+	for _, rule := range r.rules {
+		if rule.value != nil || !rule.field(name, fieldPath) {
+			continue
+		}
+		switch rule.action {
+		case RedactDrop:
+			return nil, false
+		case RedactMask:
+			return RedactedValue, true
+		case RedactHash:
+			return r.hash(value), true
+		}
+	}
+
+	switch v := value.(type) {
+	case string:
+		return r.redactString(name, fieldPath, v)
+	case map[string]any:
+		return r.redactMap(v, fieldPath+"."), true
+	case map[string]string:
+		nested := make(map[string]any, len(v))
+		for key, s := range v {
+			nested[key] = s
+		}
+		return r.redactMap(nested, fieldPath+"."), true
+	case []any:
+		return r.redactSlice(name, fieldPath, v), true
+	case []string:
+		items := make([]any, len(v))
+		for i, s := range v {
+			items[i] = s
+		}
+		return r.redactSlice(name, fieldPath, items), true
+	default:
+		return value, true
+	}
+}
+
+func (r *Redactor) redactSlice(name, fieldPath string, items []any) []any {
+	redacted := make([]any, 0, len(items))
+	for _, item := range items {
+		if v, keep := r.redactValue(name, fieldPath, item); keep {
+			redacted = append(redacted, v)
+		}
+	}
+	return redacted
+}
+
+func (r *Redactor) redactString(name, fieldPath, s string) (any, bool) {
+	for _, rule := range r.rules {
+		if rule.value == nil || (rule.field != nil && !rule.field(name, fieldPath)) {
+			continue
+		}
+
+		found := false
+		s = rule.value.pattern.ReplaceAllStringFunc(s, func(match string) string {
+			if rule.value.valid != nil && !rule.value.valid(match) {
+				return match
+			}
+			found = true
+			switch rule.action {
+			case RedactHash:
+				return r.hash(match)
+			default:
+				return RedactedValue
+			}
+		})
+		if found && rule.action == RedactDrop {
+			return nil, false
+		}
+	}
+	return s, true
+}
+
+// hash replaces a value with a keyed HMAC-SHA256 so equal values can still
+// be correlated across events without being recoverable.
+func (r *Redactor) hash(value any) string {
+	s, ok := value.(string)
+	if !ok {
+		data, err := json.Marshal(value)
+		if err != nil {
+			data = []byte(fmt.Sprint(value))
+		}
+		s = string(data)
+	}
+	mac := hmac.New(sha256.New, r.key)
+	mac.Write([]byte(s))
+	return "hmac-sha256:" + hex.EncodeToString(mac.Sum(nil))
+}
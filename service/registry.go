@@ -0,0 +1,220 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+
+	"github.com/segmentio/kafka-go"
+)
+
+// wireFormatMagicByte starts every value written in the schema registry wire
+// format: magic byte, 4-byte big-endian schema ID, then the encoded payload.
+const wireFormatMagicByte = 0
+
+const wireFormatHeaderSize = 5
+
+const registryContentType = "application/vnd.schemaregistry.v1+json"
+
+// SchemaTypeJSON is the registry schema type for JSON Schema documents.
+// Registries treat an empty schema type as Avro.
+const SchemaTypeJSON = "JSON"
+
+// LogEventSchema is the JSON Schema registered for LogEvent values.
+const LogEventSchema = `{"$schema":"http://json-schema.org/draft-07/schema#","title":"LogEvent","type":"object","properties":{"timestamp":{"type":"string","format":"date-time"},"level":{"type":"string","enum":["DEBUG","INFO","WARN","ERROR"]},"message":{"type":"string"},"service":{"type":"string"},"fields":{"type":"object"},"caller":{"type":"object"},"stack":{"type":"string"},"error":{"$ref":"#/definitions/error"},"event_id":{"type":"string"},"producer_id":{"type":"string"},"sequence":{"type":"integer","minimum":1}},"required":["timestamp","level","message","service"],"definitions":{"error":{"type":"object","properties":{"message":{"type":"string"},"type":{"type":"string"},"stack":{"type":"string"},"causes":{"type":"array","items":{"$ref":"#/definitions/error"}}},"required":["message","type"]}}}`
+
+var (
+	ErrNotWireFormat = errors.New("value is not in schema registry wire format")
+	ErrUnknownSchema = errors.New("schema not found in registry")
+)
+
+type Schema struct {
+	Schema     string `json:"schema"`
+	SchemaType string `json:"schemaType,omitempty"`
+}
+
+// TopicSubject returns the registry subject for values written to topic,
+// following the registry's default topic name strategy.
+func TopicSubject(topic string) string {
+	return topic + "-value"
+}
+
+// EncodeWireFormat prefixes payload with the magic byte and schema ID.
+func EncodeWireFormat(schemaID int, payload []byte) []byte {
+	data := make([]byte, wireFormatHeaderSize+len(payload))
+	data[0] = wireFormatMagicByte
+	binary.BigEndian.PutUint32(data[1:wireFormatHeaderSize], uint32(schemaID))
+	copy(data[wireFormatHeaderSize:], payload)
+	return data
+}
+
+// DecodeWireFormat splits a wire format value into its schema ID and payload.
+// None of the built-in encodings can start with a zero byte, so values written
+// without the prefix are reported as ErrNotWireFormat.
+func DecodeWireFormat(data []byte) (int, []byte, error) {
+	if len(data) < wireFormatHeaderSize || data[0] != wireFormatMagicByte {
+		return 0, nil, ErrNotWireFormat
+	}
+	return int(binary.BigEndian.Uint32(data[1:wireFormatHeaderSize])), data[wireFormatHeaderSize:], nil
+}
+
+// RegistryClient talks to a schema registry over its REST API. Registrations
+// and lookups are cached for the lifetime of the client, since schema IDs
+// never change once assigned.
+type RegistryClient struct {
+	baseURL    string
+	httpClient *http.Client
+
+	mutex   sync.RWMutex
+	ids     map[string]int
+	schemas map[int]Schema
+}
+
+// NewRegistryClient creates a client for the registry at baseURL. A nil
+// httpClient uses http.DefaultClient.
+func NewRegistryClient(baseURL string, httpClient *http.Client) *RegistryClient {
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	return &RegistryClient{
+		baseURL:    strings.TrimRight(baseURL, "/"),
+		httpClient: httpClient,
+		ids:        make(map[string]int),
+		schemas:    make(map[int]Schema),
+	}
+}
+
+// Register registers schema under subject and returns its ID. Registering a
+// schema the subject already has returns the existing ID.
+func (c *RegistryClient) Register(ctx context.Context, subject string, schema Schema) (int, error) {
+	cacheKey := subject + "\x00" + schema.SchemaType + "\x00" + schema.Schema
+
+	c.mutex.RLock()
+	id, ok := c.ids[cacheKey]
+	c.mutex.RUnlock()
+	if ok {
+		return id, nil
+	}
+
+	var response struct {
+		ID int `json:"id"`
+	}
+	path := "/subjects/" + url.PathEscape(subject) + "/versions"
+	if err := c.do(ctx, http.MethodPost, path, schema, &response); err != nil {
+		return 0, fmt.Errorf("failed to register schema for subject %s: %w", subject, err)
+	}
+
+	c.mutex.Lock()
+	c.ids[cacheKey] = response.ID
+	c.schemas[response.ID] = schema
+	c.mutex.Unlock()
+	return response.ID, nil
+}
+
+// SchemaByID looks up the schema registered under id.
+func (c *RegistryClient) SchemaByID(ctx context.Context, id int) (Schema, error) {
+	c.mutex.RLock()
+	schema, ok := c.schemas[id]
+	c.mutex.RUnlock()
+	if ok {
+		return schema, nil
+	}
+
+	if err := c.do(ctx, http.MethodGet, fmt.Sprintf("/schemas/ids/%d", id), nil, &schema); err != nil {
+		return Schema{}, fmt.Errorf("failed to look up schema %d: %w", id, err)
+	}
+
+	c.mutex.Lock()
+	c.schemas[id] = schema
+	c.mutex.Unlock()
+	return schema, nil
+}
+
+// DecodeMessage resolves the schema ID of a wire format message and decodes
+// the payload. Messages without the wire format prefix are decoded as is.
+func (c *RegistryClient) DecodeMessage(ctx context.Context, message kafka.Message) (LogEvent, error) {
+	schemaID, payload, err := DecodeWireFormat(message.Value)
+	if err != nil {
+		return DecodeMessage(message)
+	}
+
+	schema, err := c.SchemaByID(ctx, schemaID)
+	if err != nil {
+		return LogEvent{}, err
+	}
+	if schema.SchemaType != SchemaTypeJSON {
+		return LogEvent{}, fmt.Errorf("schema %d has unsupported type %q", schemaID, schema.SchemaType)
+	}
+
+	message.Value = payload
+	return DecodeMessage(message)
+}
+
+type registryError struct {
+	ErrorCode int    `json:"error_code"`
+	Message   string `json:"message"`
+}
+
+func (c *RegistryClient) do(ctx context.Context, method, path string, body, result any) error {
+	var reqBody io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reqBody = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, c.baseURL+path, reqBody)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", registryContentType)
+	if body != nil {
+		req.Header.Set("Content-Type", registryContentType)
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		var regErr registryError
+		json.NewDecoder(resp.Body).Decode(&regErr)
+		if resp.StatusCode == http.StatusNotFound {
+			return fmt.Errorf("%w: %s", ErrUnknownSchema, regErr.Message)
+		}
+		return fmt.Errorf("registry returned %s: %s", resp.Status, regErr.Message)
+	}
+
+	return json.NewDecoder(resp.Body).Decode(result)
+}
+
+// WithSchemaRegistry writes values in the registry wire format, registering
+// LogEventSchema under subject on first use. LogEventSchema describes the
+// JSON encoding, so the option cannot be combined with another encoder.
+func WithSchemaRegistry(client *RegistryClient, subject string) Option {
+	return func(o *loggerOptions) {
+		o.registry = client
+		o.subject = subject
+	}
+}
+
+// CheckRegistryEncoder returns an error if events encoded by encoder cannot
+// be registered under LogEventSchema. A nil encoder means JSON.
+func CheckRegistryEncoder(encoder Encoder) error {
+	if encoder != nil && encoder.ContentType() != ContentTypeJSON {
+		return fmt.Errorf("schema registry requires JSON encoding, got %s", encoder.ContentType())
+	}
+	return nil
+}
@@ -0,0 +1,167 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"kafka-logger/mocks"
+	"kafka-logger/producer"
+	"testing"
+
+	"github.com/segmentio/kafka-go"
+)
+
+func TestWireFormat(t *testing.T) {
+	t.Run("Round trip", func(t *testing.T) {
+		data := EncodeWireFormat(258, []byte(`{}`))
+
+		expected := []byte{0, 0, 0, 1, 2, '{', '}'}
+		if !bytes.Equal(data, expected) {
+			t.Errorf("Expected %v, got %v", expected, data)
+		}
+
+		schemaID, payload, err := DecodeWireFormat(data)
+		checkNoError(t, err)
+		if schemaID != 258 || string(payload) != `{}` {
+			t.Errorf("Expected schema 258 with payload {}, got %d with %s", schemaID, payload)
+		}
+	})
+
+	t.Run("Plain payloads", func(t *testing.T) {
+		for _, codec := range []Codec{JSONCodec{}, LogfmtCodec{}, MsgpackCodec{}} {
+			data, err := codec.Encode(testEvent())
+			checkNoError(t, err)
+			if _, _, err := DecodeWireFormat(data); !errors.Is(err, ErrNotWireFormat) {
+				t.Errorf("Expected ErrNotWireFormat for %s, got %v", codec.ContentType(), err)
+			}
+		}
+		if _, _, err := DecodeWireFormat([]byte{0, 1}); !errors.Is(err, ErrNotWireFormat) {
+			t.Errorf("Expected ErrNotWireFormat for short value, got %v", err)
+		}
+	})
+}
+
+func TestRegistryClient(t *testing.T) {
+	ctx := context.Background()
+	schema := Schema{Schema: LogEventSchema, SchemaType: SchemaTypeJSON}
+
+	t.Run("Register and look up with caching", func(t *testing.T) {
+		registry := mocks.NewSchemaRegistry()
+		defer registry.Close()
+		client := NewRegistryClient(registry.URL, nil)
+
+		id, err := client.Register(ctx, "logs-value", schema)
+		checkNoError(t, err)
+		again, err := client.Register(ctx, "logs-value", schema)
+		checkNoError(t, err)
+		if id != again {
+			t.Errorf("Expected the same ID for the same schema, got %d and %d", id, again)
+		}
+
+		found, err := client.SchemaByID(ctx, id)
+		checkNoError(t, err)
+		if found != schema {
+			t.Errorf("Expected %+v, got %+v", schema, found)
+		}
+		if registry.RequestCount() != 1 {
+			t.Errorf("Expected 1 registry request, got %d", registry.RequestCount())
+		}
+
+		other := NewRegistryClient(registry.URL, nil)
+		for range 2 {
+			found, err = other.SchemaByID(ctx, id)
+			checkNoError(t, err)
+		}
+		if found != schema {
+			t.Errorf("Expected %+v, got %+v", schema, found)
+		}
+		if registry.RequestCount() != 2 {
+			t.Errorf("Expected lookups to be cached, got %d registry requests", registry.RequestCount())
+		}
+	})
+
+	t.Run("Unknown schema", func(t *testing.T) {
+		registry := mocks.NewSchemaRegistry()
+		defer registry.Close()
+
+		_, err := NewRegistryClient(registry.URL, nil).SchemaByID(ctx, 42)
+		if !errors.Is(err, ErrUnknownSchema) {
+			t.Errorf("Expected ErrUnknownSchema, got %v", err)
+		}
+	})
+
+	t.Run("Invalid schema", func(t *testing.T) {
+		registry := mocks.NewSchemaRegistry()
+		defer registry.Close()
+
+		if _, err := NewRegistryClient(registry.URL, nil).Register(ctx, "logs-value", Schema{}); err == nil {
+			t.Error("Expected error registering an empty schema")
+		}
+	})
+}
+
+func TestLoggerWithSchemaRegistry(t *testing.T) {
+	registry := mocks.NewSchemaRegistry()
+	defer registry.Close()
+
+	mockWriter := &mocks.MockMessageWriter{}
+	logger := newKafkaLogger(mockWriter, "registry-service",
+		WithSchemaRegistry(NewRegistryClient(registry.URL, nil), TopicSubject("logs")))
+
+	checkNoError(t, logger.Info("first", nil))
+	checkNoError(t, logger.Warn("second", nil))
+
+	if len(mockWriter.Messages) != 2 {
+		t.Fatalf("Expected 2 messages, got %d", len(mockWriter.Messages))
+	}
+	if registry.RequestCount() != 1 {
+		t.Errorf("Expected the schema to be registered once, got %d registry requests", registry.RequestCount())
+	}
+
+	schemaID, _, err := DecodeWireFormat(mockWriter.Messages[0].Value)
+	checkNoError(t, err)
+	if schemaID != 1 {
+		t.Errorf("Expected schema ID 1, got %d", schemaID)
+	}
+
+	consumerClient := NewRegistryClient(registry.URL, nil)
+	for i, expected := range []string{"first", "second"} {
+		event, err := consumerClient.DecodeMessage(context.Background(), mockWriter.Messages[i])
+		checkNoError(t, err)
+		if event.Message != expected {
+			t.Errorf("Expected message '%s', got '%s'", expected, event.Message)
+		}
+	}
+
+	if _, err := consumerClient.DecodeMessage(context.Background(), kafka.Message{Value: EncodeWireFormat(99, []byte(`{}`))}); !errors.Is(err, ErrUnknownSchema) {
+		t.Errorf("Expected ErrUnknownSchema, got %v", err)
+	}
+
+	event, err := consumerClient.DecodeMessage(context.Background(), kafka.Message{Value: []byte(`{"message":"plain"}`)})
+	checkNoError(t, err)
+	if event.Message != "plain" {
+		t.Errorf("Expected plain JSON to decode, got '%s'", event.Message)
+	}
+}
+
+func TestSchemaRegistryRequiresJSON(t *testing.T) {
+	registry := mocks.NewSchemaRegistry()
+	defer registry.Close()
+	opts := []Option{
+		WithSchemaRegistry(NewRegistryClient(registry.URL, nil), TopicSubject("logs")),
+		WithEncoder(MsgpackCodec{}),
+	}
+
+	if _, err := NewKafkaLoggerWithSettings([]string{"localhost:9092"}, "logs", "registry-service", producer.Settings{}, opts...); err == nil {
+		t.Error("Expected a schema registry with msgpack encoding to be rejected")
+	}
+
+	mockWriter := &mocks.MockMessageWriter{}
+	logger := newKafkaLogger(mockWriter, "registry-service", opts...)
+	if err := logger.Info("not registered", nil); err == nil {
+		t.Error("Expected logging to fail")
+	}
+	if len(mockWriter.Messages) != 0 || registry.RequestCount() != 0 {
+		t.Errorf("Expected nothing written or registered, got %d messages and %d registry requests", len(mockWriter.Messages), registry.RequestCount())
+	}
+}
@@ -0,0 +1,279 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	defaultSampleInterval  = time.Second
+	defaultSummaryInterval = time.Minute
+	// maxSampledKeys bounds the number of message templates tracked at
+	// once. Events with new templates beyond it are only rate limited.
+	maxSampledKeys = 10000
+)
+
+// SamplingConfig limits how many events a logger publishes. Sampling is
+// keyed by level and message, which is the template for events logged with
+// fields: in each Interval the first First events with a key are published,
+// then every Thereafter-th. The rate limit is a token bucket shared by the
+// logger and its children, applied to events that survive sampling.
+//
+// Suppressed events are counted per key and reported every SummaryInterval
+// as an event at the same level, such as `suppressed 48211 events like
+// "cache miss"`, so nothing disappears without a trace.
+type SamplingConfig struct {
+	// First is the number of events per key published in each interval.
+	// Zero disables sampling.
+	First int
+	// Thereafter publishes every Thereafter-th event past First. Zero
+	// suppresses all of them.
+	Thereafter int
+	// Interval defaults to one second.
+	Interval time.Duration
+	// RateLimit is the sustained number of events per second. Zero
+	// disables the limit.
+	RateLimit float64
+	// Burst is the bucket size. Defaults to RateLimit, and at least 1.
+	Burst int
+	// SummaryInterval defaults to one minute.
+	SummaryInterval time.Duration
+}
+
+// WithSampling enables sampling and rate limiting. Events buffered by a
+// flight recorder scope are not sampled, and neither are the buffered
+// events published when the scope is flushed.
+func WithSampling(cfg SamplingConfig) Option {
+	if cfg.Interval <= 0 {
+		cfg.Interval = defaultSampleInterval
+	}
+	if cfg.Burst <= 0 {
+		cfg.Burst = max(1, int(math.Ceil(cfg.RateLimit)))
+	}
+	if cfg.SummaryInterval <= 0 {
+		cfg.SummaryInterval = defaultSummaryInterval
+	}
+	return func(o *loggerOptions) {
+		o.sampling = &cfg
+	}
+}
+
+type sampleKey struct {
+	level   LogLevel
+	message string
+}
+
+type sampleCounter struct {
+	windowStart time.Time
+	seen        int
+	suppressed  uint64
+}
+
+// sampler decides which events are published. A nil sampler allows
+// everything.
+type sampler struct {
+	cfg SamplingConfig
+	now func() time.Time
+
+	mu       sync.Mutex
+	counters map[sampleKey]*sampleCounter
+	// other counts rate limited events whose key could not be tracked.
+	other      map[LogLevel]uint64
+	tokens     float64
+	lastRefill time.Time
+
+	suppressed atomic.Uint64
+	stop       chan struct{}
+	done       chan struct{}
+	// stopOnce guards stop, since child loggers share the sampler and
+	// Close may be called on any of them, or more than once.
+	stopOnce sync.Once
+}
+
+func newSampler(cfg SamplingConfig) *sampler {
+	return &sampler{
+		cfg:      cfg,
+		now:      time.Now,
+		counters: make(map[sampleKey]*sampleCounter),
+		other:    make(map[LogLevel]uint64),
+		tokens:   float64(cfg.Burst),
+	}
+}
+
+// allow reports whether an event is published, counting it as suppressed if
+// not.
+func (s *sampler) allow(level LogLevel, message string) bool {
+	if s == nil {
+		return true
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := s.now()
+
+	key := sampleKey{level: level, message: message}
+	counter := s.counters[key]
+	if counter == nil && len(s.counters) < maxSampledKeys {
+		counter = &sampleCounter{windowStart: now}
+		s.counters[key] = counter
+	}
+
+	if s.cfg.First > 0 && counter != nil {
+		if now.Sub(counter.windowStart) >= s.cfg.Interval {
+			counter.windowStart = now
+			counter.seen = 0
+		}
+		counter.seen++
+		if past := counter.seen - s.cfg.First; past > 0 && (s.cfg.Thereafter <= 0 || past%s.cfg.Thereafter != 0) {
+			s.suppress(counter, level)
+			return false
+		}
+	}
+
+	if s.cfg.RateLimit > 0 && !s.take(now) {
+		s.suppress(counter, level)
+		return false
+	}
+	return true
+}
+
+func (s *sampler) suppress(counter *sampleCounter, level LogLevel) {
+	if counter != nil {
+		counter.suppressed++
+	} else {
+		s.other[level]++
+	}
+	s.suppressed.Add(1)
+}
+
+// take removes a token from the bucket after refilling it for the time
+// since the last call.
+func (s *sampler) take(now time.Time) bool {
+	if !s.lastRefill.IsZero() {
+		elapsed := now.Sub(s.lastRefill).Seconds()
+		s.tokens = math.Min(float64(s.cfg.Burst), s.tokens+elapsed*s.cfg.RateLimit)
+	}
+	s.lastRefill = now
+	if s.tokens < 1 {
+		return false
+	}
+	s.tokens--
+	return true
+}
+
+// suppressedSummary is the number of events suppressed for one key since
+// the last summary. An empty message stands for untracked keys.
+type suppressedSummary struct {
+	key   sampleKey
+	count uint64
+}
+
+// takeSummaries returns the suppressed counts since the last call, ordered
+// by level and message, and forgets keys whose interval has passed.
+func (s *sampler) takeSummaries() []suppressedSummary {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := s.now()
+
+	var summaries []suppressedSummary
+	for key, counter := range s.counters {
+		if counter.suppressed > 0 {
+			summaries = append(summaries, suppressedSummary{key: key, count: counter.suppressed})
+			counter.suppressed = 0
+		}
+		if now.Sub(counter.windowStart) >= s.cfg.Interval {
+			delete(s.counters, key)
+		}
+	}
+	for level, count := range s.other {
+		summaries = append(summaries, suppressedSummary{key: sampleKey{level: level}, count: count})
+	}
+	clear(s.other)
+
+	sort.Slice(summaries, func(i, j int) bool {
+		a, b := summaries[i].key, summaries[j].key
+		if a.level != b.level {
+			return a.level.Severity() > b.level.Severity()
+		}
+		return a.message < b.message
+	})
+	return summaries
+}
+
+// Suppressed returns the number of events suppressed by sampling and rate
+// limiting. Unlike dropped events, they are accounted for in summaries.
+func (kl *KafkaLogger) Suppressed() uint64 {
+	if kl.sampler == nil {
+		return 0
+	}
+	return kl.sampler.suppressed.Load()
+}
+
+// startSummaries reports suppressed events every SummaryInterval until
+// stopSummaries is called.
+func (kl *KafkaLogger) startSummaries() {
+	s := kl.sampler
+	s.stop = make(chan struct{})
+	s.done = make(chan struct{})
+	go func() {
+		defer close(s.done)
+		ticker := time.NewTicker(s.cfg.SummaryInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				kl.reportSuppressed()
+			case <-s.stop:
+				return
+			}
+		}
+	}()
+}
+
+// stopSummaries stops the summary goroutine and reports what was
+// suppressed since its last run. Later calls do nothing.
+func (kl *KafkaLogger) stopSummaries() {
+	s := kl.sampler
+	if s == nil || s.stop == nil {
+		return
+	}
+	s.stopOnce.Do(func() {
+		close(s.stop)
+		<-s.done
+		kl.reportSuppressed()
+	})
+}
+
+// reportSuppressed publishes a summary event per key with suppressed
+// events. Summaries bypass sampling, so they are never suppressed
+// themselves.
+func (kl *KafkaLogger) reportSuppressed() {
+	for _, summary := range kl.sampler.takeSummaries() {
+		message := fmt.Sprintf("suppressed %d events like %q", summary.count, summary.key.message)
+		fields := map[string]any{"suppressed": summary.count}
+		if summary.key.message == "" {
+			message = fmt.Sprintf("suppressed %d other events", summary.count)
+		} else {
+			fields["sampled_message"] = summary.key.message
+		}
+
+		ctx := context.Background()
+		event := LogEvent{
+			Timestamp: time.Now().UTC(),
+			Level:     summary.key.level,
+			Message:   message,
+			Service:   kl.service,
+			Fields:    fields,
+		}
+		if !kl.prepare(ctx, &event) {
+			continue
+		}
+		if err := kl.send(ctx, event); err != nil {
+			internalLog.Printf("Failed to publish suppressed event summary: %v", err)
+		}
+	}
+}
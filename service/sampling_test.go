@@ -0,0 +1,127 @@
+package service
+
+import (
+	"fmt"
+	"kafka-logger/mocks"
+	"testing"
+	"time"
+)
+
+// fakeClock is a settable time source for the sampler.
+type fakeClock struct{ now time.Time }
+
+func (c *fakeClock) Now() time.Time          { return c.now }
+func (c *fakeClock) Advance(d time.Duration) { c.now = c.now.Add(d) }
+
+func newSampledLogger(mockWriter *mocks.MockMessageWriter, cfg SamplingConfig) (*KafkaLogger, *fakeClock) {
+	cfg.SummaryInterval = time.Hour
+	logger := newKafkaLogger(mockWriter, "test-service", WithSampling(cfg))
+	clock := &fakeClock{now: time.Date(2024, 1, 15, 10, 0, 0, 0, time.UTC)}
+	logger.sampler.now = clock.Now
+	return logger, clock
+}
+
+func TestSampling(t *testing.T) {
+	t.Run("First N per interval, then 1 in M", func(t *testing.T) {
+		mockWriter := &mocks.MockMessageWriter{}
+		logger, clock := newSampledLogger(mockWriter, SamplingConfig{First: 2, Thereafter: 3, Interval: time.Second})
+
+		for i := range 8 {
+			checkNoError(t, logger.Warn("retrying", &map[string]any{"attempt": i}))
+		}
+		checkNoError(t, logger.Warn("other message", nil))
+		checkNoError(t, logger.Error("retrying", nil))
+		assertMessages(t, mockWriter, "retrying", "retrying", "retrying", "retrying", "other message", "retrying")
+
+		clock.Advance(time.Second)
+		checkNoError(t, logger.Warn("retrying", nil))
+		if got := len(mockWriter.Messages); got != 7 {
+			t.Errorf("Expected a new interval to publish again, got %d messages", got)
+		}
+		if got := logger.Suppressed(); got != 4 {
+			t.Errorf("Expected 4 suppressed events, got %d", got)
+		}
+	})
+
+	t.Run("Rate limit refills over time", func(t *testing.T) {
+		mockWriter := &mocks.MockMessageWriter{}
+		logger, clock := newSampledLogger(mockWriter, SamplingConfig{RateLimit: 2, Burst: 3})
+
+		for i := range 5 {
+			checkNoError(t, logger.Info(fmt.Sprintf("event %d", i), nil))
+		}
+		assertMessages(t, mockWriter, "event 0", "event 1", "event 2")
+
+		clock.Advance(500 * time.Millisecond)
+		checkNoError(t, logger.Info("event 5", nil))
+		checkNoError(t, logger.Info("event 6", nil))
+		assertMessages(t, mockWriter, "event 0", "event 1", "event 2", "event 5")
+	})
+
+	t.Run("Filtered and buffered events are not counted", func(t *testing.T) {
+		mockWriter := &mocks.MockMessageWriter{}
+		logger, _ := newSampledLogger(mockWriter, SamplingConfig{RateLimit: 1})
+		logger.level = NewLevelVar(INFO)
+
+		checkNoError(t, logger.Debug("below the level", nil))
+		checkNoError(t, logger.Info("published", nil))
+		if got := logger.Suppressed(); got != 0 {
+			t.Errorf("Expected nothing suppressed, got %d", got)
+		}
+	})
+
+	t.Run("Summaries report suppressed events", func(t *testing.T) {
+		mockWriter := &mocks.MockMessageWriter{}
+		logger, _ := newSampledLogger(mockWriter, SamplingConfig{First: 1})
+		logger = logger.With(map[string]any{"component": "cache"})
+
+		for range 4 {
+			checkNoError(t, logger.Warn("cache miss", nil))
+		}
+		checkNoError(t, logger.Error("lookup failed", nil))
+		checkNoError(t, logger.Error("lookup failed", nil))
+		logger.reportSuppressed()
+
+		assertMessages(t, mockWriter, "cache miss", "lookup failed",
+			`suppressed 1 events like "lookup failed"`, `suppressed 3 events like "cache miss"`)
+		event := decodeLogEvent(t, mockWriter.Messages[3])
+		if event.Level != WARN || event.Fields["sampled_message"] != "cache miss" || event.Fields["suppressed"] != float64(3) {
+			t.Errorf("Unexpected summary event %+v", event)
+		}
+
+		logger.reportSuppressed()
+		if got := len(mockWriter.Messages); got != 4 {
+			t.Errorf("Expected no summary without new suppressions, got %d messages", got)
+		}
+	})
+
+	t.Run("Close reports the last summary", func(t *testing.T) {
+		mockWriter := &mocks.MockMessageWriter{}
+		logger, _ := newSampledLogger(mockWriter, SamplingConfig{First: 1})
+
+		checkNoError(t, logger.Info("tick", nil))
+		checkNoError(t, logger.Info("tick", nil))
+		checkNoError(t, logger.Close())
+		assertMessages(t, mockWriter, "tick", `suppressed 1 events like "tick"`)
+	})
+
+	t.Run("Close twice", func(t *testing.T) {
+		mockWriter := &mocks.MockMessageWriter{}
+		logger, _ := newSampledLogger(mockWriter, SamplingConfig{First: 1})
+		child := logger.With(map[string]any{"request_id": "abc"})
+
+		checkNoError(t, logger.Info("tick", nil))
+		checkNoError(t, logger.Info("tick", nil))
+		checkNoError(t, child.Close())
+		checkNoError(t, logger.Close())
+		checkNoError(t, logger.Close())
+		assertMessages(t, mockWriter, "tick", `suppressed 1 events like "tick"`)
+	})
+
+	t.Run("Nil sampler allows everything", func(t *testing.T) {
+		var s *sampler
+		if !s.allow(ERROR, "anything") {
+			t.Errorf("Expected a nil sampler to allow events")
+		}
+	})
+}
@@ -0,0 +1,38 @@
+package service
+
+import "sync/atomic"
+
+// producerSequence numbers the events published by one root logger and the
+// child loggers derived from it. The producer ID is new for every logger, so
+// a restarted process starts a new sequence instead of appearing to go back.
+type producerSequence struct {
+	id   string
+	last atomic.Uint64
+}
+
+func newProducerSequence() *producerSequence {
+	return &producerSequence{id: NewEventID()}
+}
+
+// stamp gives event an ID if it has none and the next sequence number. It
+// is called only for events that are actually sent, so events dropped by
+// level or discarded by a flight recorder scope do not show up as gaps.
+func (s *producerSequence) stamp(event *LogEvent) {
+	if event.ID == "" {
+		event.ID = NewEventID()
+	}
+	if s == nil {
+		return
+	}
+	event.ProducerID = s.id
+	event.Sequence = s.last.Add(1)
+}
+
+// ProducerID returns the ID stamped on events from this logger and its
+// children, or "" for a logger without one.
+func (kl *KafkaLogger) ProducerID() string {
+	if kl.sequence == nil {
+		return ""
+	}
+	return kl.sequence.id
+}
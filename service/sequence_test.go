@@ -0,0 +1,94 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"kafka-logger/mocks"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+)
+
+func TestEventSequence(t *testing.T) {
+	t.Run("Child loggers share the producer sequence", func(t *testing.T) {
+		mockWriter := &mocks.MockMessageWriter{}
+		logger := newKafkaLogger(mockWriter, "test-service")
+		child := logger.With(map[string]any{"k": "v"})
+
+		checkNoError(t, logger.Info("first", nil))
+		checkNoError(t, child.Info("second", nil))
+		checkNoError(t, logger.InfoFields("third"))
+
+		ids := make(map[string]bool)
+		for i, msg := range mockWriter.Messages {
+			event := decodeLogEvent(t, msg)
+			if event.ProducerID == "" || event.ProducerID != logger.ProducerID() {
+				t.Errorf("Expected producer %s, got %q", logger.ProducerID(), event.ProducerID)
+			}
+			if event.Sequence != uint64(i+1) {
+				t.Errorf("Expected sequence %d, got %d", i+1, event.Sequence)
+			}
+			if event.ID == "" || ids[event.ID] {
+				t.Errorf("Expected a unique event ID, got %q", event.ID)
+			}
+			ids[event.ID] = true
+
+			meta, _ := MetadataFromMessage(msg)
+			if meta.EventID != event.ID || meta.ProducerID != event.ProducerID || meta.Sequence != event.Sequence {
+				t.Errorf("Expected headers to match the event, got %+v", meta)
+			}
+		}
+
+		if newKafkaLogger(mockWriter, "test-service").ProducerID() == logger.ProducerID() {
+			t.Error("Expected each root logger to get its own producer ID")
+		}
+	})
+
+	t.Run("Unpublished events take no sequence number", func(t *testing.T) {
+		mockWriter := &mocks.MockMessageWriter{}
+		logger := newKafkaLogger(mockWriter, "test-service",
+			WithLevel(NewLevelVar(INFO)),
+			WithFlightRecorder(FlightRecorderConfig{}))
+		scope := logger.Scope(nil)
+
+		checkNoError(t, logger.Debug("filtered", nil))
+		checkNoError(t, scope.Debug("discarded", nil))
+		scope.EndScope()
+		checkNoError(t, logger.Info("published", nil))
+
+		if seq := decodeLogEvent(t, mockWriter.Messages[0]).Sequence; seq != 1 {
+			t.Errorf("Expected sequence 1, got %d", seq)
+		}
+	})
+
+	t.Run("Retried events keep their ID", func(t *testing.T) {
+		var attempted []kafka.Message
+		var brokerDown atomic.Bool
+		brokerDown.Store(true)
+		mockWriter := &mocks.MockMessageWriter{
+			WriteFunc: func(ctx context.Context, msgs ...kafka.Message) error {
+				if brokerDown.Load() {
+					attempted = append(attempted, msgs...)
+					return errors.New("broker unreachable")
+				}
+				return nil
+			},
+		}
+		logger := newKafkaLogger(mockWriter, "test-service",
+			WithSpool(SpoolConfig{Dir: t.TempDir(), ReplayInterval: 5 * time.Millisecond}))
+
+		checkNoError(t, logger.Info("retried", nil))
+		brokerDown.Store(false)
+		waitForMessages(t, mockWriter, 1)
+		checkNoError(t, logger.Close())
+
+		first := decodeLogEvent(t, attempted[0])
+		replayed := decodeLogEvent(t, mockWriter.Messages[0])
+		if first.ID != replayed.ID || first.Sequence != replayed.Sequence {
+			t.Errorf("Expected the replayed event to keep ID %s #%d, got %s #%d",
+				first.ID, first.Sequence, replayed.ID, replayed.Sequence)
+		}
+	})
+}
@@ -0,0 +1,228 @@
+package service
+
+import (
+	"context"
+	"log/slog"
+	"time"
+)
+
+// SlogOptions configures a SlogHandler.
+type SlogOptions struct {
+	// Level is the minimum slog level that is published. Defaults to slog.LevelDebug.
+	Level slog.Leveler
+}
+
+// SlogHandler is a slog.Handler that publishes records through a KafkaLogger.
+// Attributes become LogEvent fields and groups become nested maps.
+type SlogHandler struct {
+	logger *KafkaLogger
+	level  slog.Leveler
+	attrs  map[string]any
+	groups []string
+}
+
+func NewSlogHandler(logger *KafkaLogger, opts *SlogOptions) *SlogHandler {
+	h := &SlogHandler{
+		logger: logger,
+		level:  slog.LevelDebug,
+	}
+	if opts != nil && opts.Level != nil {
+		h.level = opts.Level
+	}
+	return h
+}
+
+func (h *SlogHandler) Enabled(_ context.Context, level slog.Level) bool {
+	return level >= h.level.Level() && h.logger.Enabled(LevelFromSlog(level))
+}
+
+// Handle publishes r. It follows testing/slogtest except that a record
+// without a time gets the current time, since every LogEvent has one.
+func (h *SlogHandler) Handle(ctx context.Context, r slog.Record) error {
+	var errInfo *ErrorInfo
+	attrs := make([]slog.Attr, 0, r.NumAttrs())
+	r.Attrs(func(a slog.Attr) bool {
+		// slog.Any("err", err) and "error" become the structured error,
+		// unless a group is open, where they stay with the group.
+		a.Value = a.Value.Resolve()
+		if err, ok := a.Value.Any().(error); ok && errInfo == nil && len(h.groups) == 0 && (a.Key == "err" || a.Key == "error") {
+			errInfo = NewErrorInfo(err)
+			return true
+		}
+		attrs = append(attrs, a)
+		return true
+	})
+
+	fields := h.fieldsWith(attrs)
+	if len(fields) == 0 {
+		fields = nil
+	}
+
+	timestamp := r.Time
+	if timestamp.IsZero() {
+		timestamp = time.Now()
+	}
+
+	event := LogEvent{
+		Timestamp: timestamp.UTC(),
+		Level:     LevelFromSlog(r.Level),
+		Message:   r.Message,
+		Service:   h.logger.service,
+		Fields:    fields,
+		Caller:    callerFromPC(r.PC),
+		Error:     errInfo,
+	}
+	if h.logger.wantsStack(event.Level) {
+		event.Stack = stackFrom(event.Caller)
+	}
+	return h.logger.publish(ctx, event)
+}
+
+func (h *SlogHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	if len(attrs) == 0 {
+		return h
+	}
+	h2 := *h
+	h2.attrs = h.fieldsWith(attrs)
+	return &h2
+}
+
+func (h *SlogHandler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+	h2 := *h
+	h2.groups = append(h.groups[:len(h.groups):len(h.groups)], name)
+	return &h2
+}
+
+// fieldsWith returns a copy of the handler's bound attributes with attrs added
+// under the currently open groups. Groups that end up empty are omitted.
+func (h *SlogHandler) fieldsWith(attrs []slog.Attr) map[string]any {
+	fields := cloneFields(h.attrs)
+
+	leaf := make(map[string]any)
+	for _, a := range attrs {
+		addSlogAttr(leaf, a)
+	}
+	if len(leaf) == 0 {
+		return fields
+	}
+
+	if fields == nil {
+		fields = make(map[string]any)
+	}
+	target := fields
+	for _, g := range h.groups {
+		sub, ok := target[g].(map[string]any)
+		if !ok {
+			sub = make(map[string]any)
+			target[g] = sub
+		}
+		target = sub
+	}
+	mergeFields(target, leaf)
+
+	return fields
+}
+
+func addSlogAttr(dst map[string]any, a slog.Attr) {
+	a.Value = a.Value.Resolve()
+	if a.Equal(slog.Attr{}) {
+		return
+	}
+
+	if a.Value.Kind() != slog.KindGroup {
+		dst[a.Key] = slogValue(a.Value)
+		return
+	}
+
+	group := a.Value.Group()
+	if len(group) == 0 {
+		return
+	}
+	if a.Key == "" {
+		for _, ga := range group {
+			addSlogAttr(dst, ga)
+		}
+		return
+	}
+
+	sub, ok := dst[a.Key].(map[string]any)
+	if !ok {
+		sub = make(map[string]any)
+	}
+	for _, ga := range group {
+		addSlogAttr(sub, ga)
+	}
+	if len(sub) > 0 {
+		dst[a.Key] = sub
+	}
+}
+
+func slogValue(v slog.Value) any {
+	switch v.Kind() {
+	case slog.KindString:
+		return v.String()
+	case slog.KindInt64:
+		return v.Int64()
+	case slog.KindUint64:
+		return v.Uint64()
+	case slog.KindFloat64:
+		return v.Float64()
+	case slog.KindBool:
+		return v.Bool()
+	case slog.KindDuration:
+		return v.Duration().String()
+	case slog.KindTime:
+		return v.Time().UTC()
+	default:
+		if err, ok := v.Any().(error); ok {
+			return err.Error()
+		}
+		return v.Any()
+	}
+}
+
+// LevelFromSlog maps a slog level onto the closest LogLevel at or below it.
+func LevelFromSlog(level slog.Level) LogLevel {
+	switch {
+	case level >= slog.LevelError:
+		return ERROR
+	case level >= slog.LevelWarn:
+		return WARN
+	case level >= slog.LevelInfo:
+		return INFO
+	default:
+		return DEBUG
+	}
+}
+
+// cloneFields deep-copies nested field maps so they can be extended without
+// mutating maps shared with other loggers or handlers.
+func cloneFields(fields map[string]any) map[string]any {
+	if fields == nil {
+		return nil
+	}
+	clone := make(map[string]any, len(fields))
+	for k, v := range fields {
+		if sub, ok := v.(map[string]any); ok {
+			v = cloneFields(sub)
+		}
+		clone[k] = v
+	}
+	return clone
+}
+
+// mergeFields copies src into dst, merging nested maps present in both.
+func mergeFields(dst, src map[string]any) {
+	for k, v := range src {
+		if sub, ok := v.(map[string]any); ok {
+			if existing, ok := dst[k].(map[string]any); ok {
+				mergeFields(existing, sub)
+				continue
+			}
+		}
+		dst[k] = v
+	}
+}
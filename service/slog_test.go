@@ -0,0 +1,237 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"kafka-logger/mocks"
+	"log/slog"
+	"strings"
+	"testing"
+	"testing/slogtest"
+	"time"
+)
+
+func TestSlogHandler(t *testing.T) {
+	t.Parallel()
+
+	t.Run("Publishes record with attributes", func(t *testing.T) {
+		mockWriter := &mocks.MockMessageWriter{}
+		logger := &KafkaLogger{
+			writer:  mockWriter,
+			service: "test-service",
+		}
+
+		slog.New(NewSlogHandler(logger, nil)).Info("user logged in",
+			"user_id", 123,
+			"admin", true,
+			"latency", 150*time.Millisecond,
+			"err", errors.New("boom"),
+		)
+
+		logEvent := assertLogEvent(t, mockWriter, INFO, "user logged in", "test-service")
+
+		if logEvent.Fields["user_id"] != float64(123) {
+			t.Errorf("Expected user_id 123, got %v", logEvent.Fields["user_id"])
+		}
+		if logEvent.Fields["admin"] != true {
+			t.Errorf("Expected admin true, got %v", logEvent.Fields["admin"])
+		}
+		if logEvent.Fields["latency"] != "150ms" {
+			t.Errorf("Expected latency '150ms', got %v", logEvent.Fields["latency"])
+		}
+		if logEvent.Error == nil || logEvent.Error.Message != "boom" || logEvent.Fields["err"] != nil {
+			t.Errorf("Expected err as the structured error, got %+v and fields %v", logEvent.Error, logEvent.Fields)
+		}
+	})
+
+	t.Run("Nested groups", func(t *testing.T) {
+		mockWriter := &mocks.MockMessageWriter{}
+		logger := &KafkaLogger{
+			writer:  mockWriter,
+			service: "test-service",
+		}
+
+		slogger := slog.New(NewSlogHandler(logger, nil)).
+			With("request_id", "req-1").
+			WithGroup("http").
+			With(slog.Group("request", "method", "GET"))
+
+		slogger.Warn("slow request", slog.Group("request", "path", "/api/users"), "status", 200)
+
+		logEvent := assertLogEvent(t, mockWriter, WARN, "slow request", "test-service")
+
+		if logEvent.Fields["request_id"] != "req-1" {
+			t.Errorf("Expected request_id 'req-1', got %v", logEvent.Fields["request_id"])
+		}
+
+		httpGroup, ok := logEvent.Fields["http"].(map[string]any)
+		if !ok {
+			t.Fatalf("Expected http group, got %v", logEvent.Fields)
+		}
+		if httpGroup["status"] != float64(200) {
+			t.Errorf("Expected http.status 200, got %v", httpGroup["status"])
+		}
+
+		request, ok := httpGroup["request"].(map[string]any)
+		if !ok {
+			t.Fatalf("Expected http.request group, got %v", httpGroup)
+		}
+		if request["method"] != "GET" || request["path"] != "/api/users" {
+			t.Errorf("Expected merged request group, got %v", request)
+		}
+	})
+
+	t.Run("Empty groups are omitted", func(t *testing.T) {
+		mockWriter := &mocks.MockMessageWriter{}
+		logger := &KafkaLogger{
+			writer:  mockWriter,
+			service: "test-service",
+		}
+
+		slog.New(NewSlogHandler(logger, nil)).WithGroup("empty").Error("no attrs", slog.Group("nothing"))
+
+		logEvent := assertLogEvent(t, mockWriter, ERROR, "no attrs", "test-service")
+
+		if logEvent.Fields != nil {
+			t.Errorf("Expected nil fields, got %v", logEvent.Fields)
+		}
+	})
+
+	t.Run("Derived handlers do not share state", func(t *testing.T) {
+		mockWriter := &mocks.MockMessageWriter{}
+		logger := &KafkaLogger{
+			writer:  mockWriter,
+			service: "test-service",
+		}
+
+		base := slog.New(NewSlogHandler(logger, nil)).With(slog.Group("ctx", "a", 1))
+		base.With(slog.Group("ctx", "b", 2)).Info("child")
+		base.Info("parent")
+
+		var parent LogEvent
+		if err := json.Unmarshal(mockWriter.Messages[1].Value, &parent); err != nil {
+			t.Fatalf("Failed to unmarshal log event: %v", err)
+		}
+
+		group := parent.Fields["ctx"].(map[string]any)
+		if _, ok := group["b"]; ok {
+			t.Errorf("Expected parent handler to be unaffected by child attrs, got %v", group)
+		}
+	})
+
+	t.Run("Minimum level", func(t *testing.T) {
+		mockWriter := &mocks.MockMessageWriter{}
+		logger := &KafkaLogger{
+			writer:  mockWriter,
+			service: "test-service",
+		}
+
+		handler := NewSlogHandler(logger, &SlogOptions{Level: slog.LevelWarn})
+		if handler.Enabled(context.Background(), slog.LevelInfo) {
+			t.Error("Expected INFO to be disabled")
+		}
+		if !handler.Enabled(context.Background(), slog.LevelError) {
+			t.Error("Expected ERROR to be enabled")
+		}
+	})
+
+	t.Run("Errors in groups stay in the group", func(t *testing.T) {
+		mockWriter := &mocks.MockMessageWriter{}
+		logger := &KafkaLogger{
+			writer:  mockWriter,
+			service: "test-service",
+		}
+
+		slogger := slog.New(NewSlogHandler(logger, nil))
+		slogger.WithGroup("req").Error("upstream failed", "err", errors.New("timeout"))
+		slogger.Error("lookup failed", "error", errorValuer{errors.New("not found")})
+
+		grouped := decodeLogEvent(t, mockWriter.Messages[0])
+		if req, _ := grouped.Fields["req"].(map[string]any); grouped.Error != nil || req["err"] != "timeout" {
+			t.Errorf("Expected req.err to stay a field, got %+v and fields %v", grouped.Error, grouped.Fields)
+		}
+		resolved := decodeLogEvent(t, mockWriter.Messages[1])
+		if resolved.Error == nil || resolved.Error.Message != "not found" {
+			t.Errorf("Expected the resolved error as the structured error, got %+v", resolved.Error)
+		}
+	})
+
+	t.Run("Record timestamp", func(t *testing.T) {
+		mockWriter := &mocks.MockMessageWriter{}
+		logger := &KafkaLogger{
+			writer:  mockWriter,
+			service: "test-service",
+		}
+
+		recordTime := time.Date(2024, 1, 15, 10, 30, 45, 0, time.FixedZone("CET", 3600))
+		record := slog.NewRecord(recordTime, slog.LevelInfo, "at a fixed time", 0)
+
+		if err := NewSlogHandler(logger, nil).Handle(context.Background(), record); err != nil {
+			t.Fatalf("Expected no error, got: %v", err)
+		}
+
+		var logEvent LogEvent
+		if err := json.Unmarshal(mockWriter.Messages[0].Value, &logEvent); err != nil {
+			t.Fatalf("Failed to unmarshal log event: %v", err)
+		}
+		if !logEvent.Timestamp.Equal(recordTime) {
+			t.Errorf("Expected timestamp %v, got %v", recordTime, logEvent.Timestamp)
+		}
+	})
+}
+
+func TestLevelFromSlog(t *testing.T) {
+	testCases := []struct {
+		level    slog.Level
+		expected LogLevel
+	}{
+		{slog.LevelDebug - 4, DEBUG},
+		{slog.LevelDebug, DEBUG},
+		{slog.LevelInfo, INFO},
+		{slog.LevelInfo + 2, INFO},
+		{slog.LevelWarn, WARN},
+		{slog.LevelError, ERROR},
+		{slog.LevelError + 4, ERROR},
+	}
+
+	for _, tc := range testCases {
+		if result := LevelFromSlog(tc.level); result != tc.expected {
+			t.Errorf("Expected %s for %v, got %s", tc.expected, tc.level, result)
+		}
+	}
+}
+
+// errorValuer logs as the error it wraps.
+type errorValuer struct{ err error }
+
+func (v errorValuer) LogValue() slog.Value {
+	return slog.AnyValue(v.err)
+}
+
+func TestSlogHandlerConformance(t *testing.T) {
+	var mockWriter *mocks.MockMessageWriter
+	newHandler := func(t *testing.T) slog.Handler {
+		if strings.HasSuffix(t.Name(), "/zero-time") {
+			t.Skip("Records without a time get the current time, since every LogEvent has one")
+		}
+		mockWriter = &mocks.MockMessageWriter{}
+		return NewSlogHandler(newKafkaLogger(mockWriter, "test-service"), nil)
+	}
+	result := func(t *testing.T) map[string]any {
+		if len(mockWriter.Messages) != 1 {
+			t.Fatalf("Expected 1 message, got %d", len(mockWriter.Messages))
+		}
+		event := decodeLogEvent(t, mockWriter.Messages[0])
+		m := map[string]any{
+			slog.TimeKey:    event.Timestamp,
+			slog.LevelKey:   event.Level,
+			slog.MessageKey: event.Message,
+		}
+		for k, v := range event.Fields {
+			m[k] = v
+		}
+		return m
+	}
+	slogtest.Run(t, newHandler, result)
+}
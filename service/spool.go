@@ -0,0 +1,465 @@
+package service
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"kafka-logger/producer"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+)
+
+const (
+	spoolSuffix = ".spool"
+
+	defaultSpoolMaxBytes       = 64 << 20
+	defaultSpoolMaxAge         = 24 * time.Hour
+	defaultSpoolSegmentBytes   = 4 << 20
+	defaultSpoolReplayInterval = 5 * time.Second
+	spoolReplayBatchSize       = 100
+)
+
+var errSpoolClosed = errors.New("spool is closed")
+
+// SpoolConfig configures the on-disk spool that keeps events the writer
+// could not deliver.
+type SpoolConfig struct {
+	Dir string
+	// MaxBytes caps the total spool size; the oldest segments are evicted first.
+	MaxBytes int64
+	// MaxAge evicts segments older than this.
+	MaxAge         time.Duration
+	SegmentBytes   int64
+	ReplayInterval time.Duration
+}
+
+// WithSpool appends events that fail to write to segment files under
+// cfg.Dir. A background replayer resends them in order once the writer
+// recovers. Segments left over from a previous process are replayed too.
+// Events logged while the spool is not empty are appended behind the older
+// ones rather than written directly, so they never overtake them.
+func WithSpool(cfg SpoolConfig) Option {
+	return func(o *loggerOptions) {
+		o.spool = &cfg
+	}
+}
+
+// spoolRecord is one line in a segment file.
+type spoolRecord struct {
+	Key     []byte         `json:"key,omitempty"`
+	Value   []byte         `json:"value"`
+	Headers []kafka.Header `json:"headers,omitempty"`
+	Time    time.Time      `json:"time"`
+}
+
+type diskSpool struct {
+	cfg    SpoolConfig
+	writer producer.MessageWriter
+
+	mu         sync.Mutex
+	active     *os.File
+	activeName string
+	activeSize int64
+	lastSeq    int64
+	// replaying is the segment being resent, which eviction leaves alone so
+	// its events are not counted as lost while they are delivered.
+	replaying string
+	// closed is set by close; later appends fail rather than reopen a
+	// segment nothing would close.
+	closed bool
+
+	// backlog is set while events are waiting to be replayed; see pending.
+	backlog atomic.Bool
+	evicted atomic.Uint64
+	wake    chan struct{}
+	stop    chan struct{}
+	done    chan struct{}
+}
+
+func newDiskSpool(writer producer.MessageWriter, cfg SpoolConfig) *diskSpool {
+	if cfg.MaxBytes <= 0 {
+		cfg.MaxBytes = defaultSpoolMaxBytes
+	}
+	if cfg.MaxAge <= 0 {
+		cfg.MaxAge = defaultSpoolMaxAge
+	}
+	if cfg.SegmentBytes <= 0 {
+		cfg.SegmentBytes = defaultSpoolSegmentBytes
+	}
+	if cfg.ReplayInterval <= 0 {
+		cfg.ReplayInterval = defaultSpoolReplayInterval
+	}
+
+	s := &diskSpool{
+		cfg:    cfg,
+		writer: writer,
+		wake:   make(chan struct{}, 1),
+		stop:   make(chan struct{}),
+		done:   make(chan struct{}),
+	}
+	s.backlog.Store(len(s.sealedSegments()) > 0)
+	go s.run()
+	return s
+}
+
+func (s *diskSpool) append(msgs ...kafka.Message) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.closed {
+		return errSpoolClosed
+	}
+	if s.active == nil {
+		if err := s.openSegment(); err != nil {
+			return err
+		}
+	}
+
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	for _, msg := range msgs {
+		record := spoolRecord{
+			Key:     msg.Key,
+			Value:   msg.Value,
+			Headers: msg.Headers,
+			Time:    msg.Time,
+		}
+		if err := encoder.Encode(record); err != nil {
+			return fmt.Errorf("failed to encode spool record: %w", err)
+		}
+	}
+
+	n, err := s.active.Write(buf.Bytes())
+	s.activeSize += int64(n)
+	if err != nil {
+		return fmt.Errorf("failed to write spool segment %s: %w", s.activeName, err)
+	}
+	if err := s.active.Sync(); err != nil {
+		return fmt.Errorf("failed to sync spool segment %s: %w", s.activeName, err)
+	}
+
+	s.backlog.Store(true)
+
+	if s.activeSize >= s.cfg.SegmentBytes {
+		s.sealActive()
+	}
+	s.enforceLimits()
+	return nil
+}
+
+// pending reports whether events are waiting to be replayed. New events must
+// then go through enqueue rather than straight to the writer, or they would
+// overtake the older ones.
+func (s *diskSpool) pending() bool {
+	return s.backlog.Load()
+}
+
+// enqueue appends msgs behind the events already spooled and wakes the
+// replayer, so they are sent as soon as the writer accepts them.
+func (s *diskSpool) enqueue(msgs ...kafka.Message) error {
+	if err := s.append(msgs...); err != nil {
+		return err
+	}
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+// openSegment starts a new active segment. Segment names are creation times in
+// nanoseconds so that lexical order is replay order and the age is known.
+func (s *diskSpool) openSegment() error {
+	// Create directory with 0755 (rwxr-xr-x) - owner: read/write/execute, group/others: read/execute
+	if err := os.MkdirAll(s.cfg.Dir, 0755); err != nil {
+		return fmt.Errorf("failed to create spool directory %s: %w", s.cfg.Dir, err)
+	}
+
+	seq := time.Now().UnixNano()
+	if seq <= s.lastSeq {
+		seq = s.lastSeq + 1
+	}
+	s.lastSeq = seq
+
+	name := filepath.Join(s.cfg.Dir, fmt.Sprintf("%020d%s", seq, spoolSuffix))
+	// Open file with 0644 (rw-r--r--) - owner: read/write, group/others: read-only
+	file, err := os.OpenFile(name, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open spool segment %s: %w", name, err)
+	}
+
+	s.active = file
+	s.activeName = name
+	s.activeSize = 0
+	return nil
+}
+
+func (s *diskSpool) sealActive() {
+	if s.active == nil {
+		return
+	}
+	s.active.Close()
+	s.active = nil
+	s.activeName = ""
+	s.activeSize = 0
+}
+
+// sealedSegments lists the segments that are not being appended to, oldest first.
+func (s *diskSpool) sealedSegments() []string {
+	entries, err := os.ReadDir(s.cfg.Dir)
+	if err != nil {
+		return nil
+	}
+
+	var segments []string
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), spoolSuffix) {
+			continue
+		}
+		name := filepath.Join(s.cfg.Dir, entry.Name())
+		if name != s.activeName {
+			segments = append(segments, name)
+		}
+	}
+	sort.Strings(segments)
+	return segments
+}
+
+// enforceLimits evicts sealed segments that are too old or push the spool
+// over MaxBytes. Must be called with s.mu held.
+func (s *diskSpool) enforceLimits() {
+	segments := s.sealedSegments()
+
+	total := s.activeSize
+	sizes := make([]int64, len(segments))
+	for i, name := range segments {
+		if info, err := os.Stat(name); err == nil {
+			sizes[i] = info.Size()
+			total += sizes[i]
+		}
+	}
+
+	cutoff := time.Now().Add(-s.cfg.MaxAge)
+	for i, name := range segments {
+		if total <= s.cfg.MaxBytes && !segmentCreated(name).Before(cutoff) {
+			break
+		}
+		if name == s.replaying {
+			continue
+		}
+		s.evict(name)
+		total -= sizes[i]
+	}
+}
+
+func (s *diskSpool) evict(name string) {
+	data, err := os.ReadFile(name)
+	if err != nil {
+		return
+	}
+	if err := os.Remove(name); err != nil {
+		return
+	}
+	count := uint64(bytes.Count(data, []byte("\n")))
+	s.evicted.Add(count)
+	internalLog.Printf("Evicted spool segment %s with %d log events", name, count)
+}
+
+func segmentCreated(name string) time.Time {
+	seq, err := strconv.ParseInt(strings.TrimSuffix(filepath.Base(name), spoolSuffix), 10, 64)
+	if err != nil {
+		return time.Time{}
+	}
+	return time.Unix(0, seq)
+}
+
+func (s *diskSpool) run() {
+	defer close(s.done)
+
+	ticker := time.NewTicker(s.cfg.ReplayInterval)
+	defer ticker.Stop()
+
+	// After a failed replay, enqueued events wait for the next tick instead
+	// of retrying the writer on every event.
+	paused := false
+	for {
+		select {
+		case <-s.stop:
+			return
+		case <-ticker.C:
+			paused = !s.replay()
+		case <-s.wake:
+			if !paused {
+				paused = !s.replay()
+			}
+		}
+	}
+}
+
+// replay resends spooled segments oldest first and stops at the first
+// failure so that order is preserved. It reports whether it did not fail.
+func (s *diskSpool) replay() bool {
+	for {
+		s.mu.Lock()
+		s.enforceLimits()
+		segments := s.sealedSegments()
+		if len(segments) == 0 && s.active != nil {
+			s.sealActive()
+			segments = s.sealedSegments()
+		}
+		if len(segments) == 0 {
+			s.backlog.Store(false)
+		} else {
+			s.replaying = segments[0]
+		}
+		s.mu.Unlock()
+
+		if len(segments) == 0 {
+			return true
+		}
+		err := s.replaySegment(segments[0])
+		s.mu.Lock()
+		s.replaying = ""
+		s.mu.Unlock()
+		if err != nil {
+			internalLog.Printf("Spool replay paused: %v", err)
+			return false
+		}
+
+		select {
+		case <-s.stop:
+			return true
+		default:
+		}
+	}
+}
+
+func (s *diskSpool) replaySegment(name string) error {
+	records, err := readSpoolSegment(name)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		select {
+		case <-s.stop:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	for sent := 0; sent < len(records); sent += spoolReplayBatchSize {
+		end := min(sent+spoolReplayBatchSize, len(records))
+		batch := make([]kafka.Message, 0, end-sent)
+		for _, record := range records[sent:end] {
+			batch = append(batch, kafka.Message{
+				Key:     record.Key,
+				Value:   record.Value,
+				Headers: record.Headers,
+				Time:    record.Time,
+			})
+		}
+
+		if err := s.writer.WriteMessages(ctx, batch...); err != nil {
+			if sent > 0 {
+				s.rewriteSegment(name, records[sent:])
+			}
+			return fmt.Errorf("failed to replay %s: %w", name, err)
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := os.Remove(name); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to remove replayed spool segment %s: %w", name, err)
+	}
+	return nil
+}
+
+// rewriteSegment atomically replaces a partially replayed segment with the
+// records that are still outstanding.
+func (s *diskSpool) rewriteSegment(name string, remaining []spoolRecord) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// The segment may have been evicted while it was being replayed.
+	if _, err := os.Stat(name); err != nil {
+		return
+	}
+
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	for _, record := range remaining {
+		encoder.Encode(record)
+	}
+
+	tmp := name + ".tmp"
+	if err := os.WriteFile(tmp, buf.Bytes(), 0644); err != nil {
+		internalLog.Printf("Failed to rewrite spool segment %s: %v", name, err)
+		return
+	}
+	if err := os.Rename(tmp, name); err != nil {
+		internalLog.Printf("Failed to rewrite spool segment %s: %v", name, err)
+	}
+}
+
+// readSpoolSegment decodes a segment. A truncated trailing line, left by a
+// crash mid-write, is skipped.
+func readSpoolSegment(name string) ([]spoolRecord, error) {
+	file, err := os.Open(name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open spool segment %s: %w", name, err)
+	}
+	defer file.Close()
+
+	var records []spoolRecord
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16<<20)
+	for scanner.Scan() {
+		var record spoolRecord
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			internalLog.Printf("Skipping corrupt record in spool segment %s: %v", name, err)
+			continue
+		}
+		records = append(records, record)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read spool segment %s: %w", name, err)
+	}
+	return records, nil
+}
+
+// stopReplay stops the replayer, so the writer can be closed while the
+// spool still accepts the failures it reports on the way.
+func (s *diskSpool) stopReplay() {
+	select {
+	case <-s.stop:
+	default:
+		close(s.stop)
+	}
+	<-s.done
+}
+
+// close stops the replayer and closes the active segment. Spooled events stay
+// on disk for the next process.
+func (s *diskSpool) close() {
+	s.stopReplay()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.sealActive()
+	s.closed = true
+}
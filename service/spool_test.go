@@ -0,0 +1,274 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"kafka-logger/mocks"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+)
+
+func TestSpool(t *testing.T) {
+	t.Parallel()
+
+	t.Run("Failed writes are spooled and replayed in order", func(t *testing.T) {
+		t.Parallel()
+		var brokerDown atomic.Bool
+		brokerDown.Store(true)
+		mockWriter := &mocks.MockMessageWriter{
+			WriteFunc: func(ctx context.Context, msgs ...kafka.Message) error {
+				if brokerDown.Load() {
+					return errors.New("broker unreachable")
+				}
+				return nil
+			},
+		}
+		logger := newKafkaLogger(mockWriter, "test-service", WithSpool(SpoolConfig{
+			Dir:            t.TempDir(),
+			ReplayInterval: 5 * time.Millisecond,
+		}))
+
+		checkNoError(t, logger.Error("first", nil))
+		checkNoError(t, logger.Error("second", nil))
+		checkNoError(t, logger.Error("third", nil))
+
+		if len(mockWriter.Written()) != 0 {
+			t.Fatal("Expected nothing to be written while the broker is down")
+		}
+
+		brokerDown.Store(false)
+		waitForMessages(t, mockWriter, 3)
+		checkNoError(t, logger.Close())
+
+		assertMessages(t, mockWriter, "first", "second", "third")
+		assertSpoolEmpty(t, logger.spool.cfg.Dir)
+	})
+
+	t.Run("New events queue behind spooled ones", func(t *testing.T) {
+		t.Parallel()
+		var brokerDown atomic.Bool
+		brokerDown.Store(true)
+		mockWriter := &mocks.MockMessageWriter{
+			WriteFunc: func(ctx context.Context, msgs ...kafka.Message) error {
+				if brokerDown.Load() {
+					return errors.New("broker unreachable")
+				}
+				return nil
+			},
+		}
+		logger := newKafkaLogger(mockWriter, "test-service", WithSpool(SpoolConfig{
+			Dir:            t.TempDir(),
+			ReplayInterval: time.Hour,
+		}))
+
+		checkNoError(t, logger.Error("during the outage", nil))
+		brokerDown.Store(false)
+		checkNoError(t, logger.Info("after recovery", nil))
+		checkNoError(t, logger.Info("later still", nil))
+
+		waitForMessages(t, mockWriter, 3)
+		checkNoError(t, logger.Close())
+
+		assertMessages(t, mockWriter, "during the outage", "after recovery", "later still")
+		assertSpoolEmpty(t, logger.spool.cfg.Dir)
+	})
+
+	t.Run("Spool survives restart", func(t *testing.T) {
+		t.Parallel()
+		dir := t.TempDir()
+
+		failing := &mocks.MockMessageWriter{WriteErr: errors.New("broker unreachable")}
+		logger := newKafkaLogger(failing, "test-service", WithSpool(SpoolConfig{
+			Dir:            dir,
+			ReplayInterval: time.Hour,
+		}))
+		checkNoError(t, logger.Warn("from the outage", nil))
+		checkNoError(t, logger.Close())
+
+		mockWriter := &mocks.MockMessageWriter{}
+		restarted := newKafkaLogger(mockWriter, "test-service", WithSpool(SpoolConfig{
+			Dir:            dir,
+			ReplayInterval: 5 * time.Millisecond,
+		}))
+		waitForMessages(t, mockWriter, 1)
+		checkNoError(t, restarted.Close())
+
+		assertMessages(t, mockWriter, "from the outage")
+		assertSpoolEmpty(t, dir)
+	})
+
+	t.Run("Async batches are spooled on failure", func(t *testing.T) {
+		t.Parallel()
+		dir := t.TempDir()
+		failing := &mocks.MockMessageWriter{WriteErr: errors.New("broker unreachable")}
+		logger := newKafkaLogger(failing, "test-service",
+			WithSpool(SpoolConfig{Dir: dir, ReplayInterval: time.Hour}),
+			WithAsync(AsyncConfig{BatchSize: 2, FlushInterval: time.Hour}),
+		)
+
+		checkNoError(t, logger.Info("one", nil))
+		checkNoError(t, logger.Info("two", nil))
+		checkNoError(t, logger.Close())
+
+		if logger.Dropped() != 0 {
+			t.Errorf("Expected no dropped events, got %d", logger.Dropped())
+		}
+
+		segments, _ := filepath.Glob(filepath.Join(dir, "*"+spoolSuffix))
+		if len(segments) != 1 {
+			t.Fatalf("Expected 1 spool segment, got %d", len(segments))
+		}
+		records, err := readSpoolSegment(segments[0])
+		if err != nil {
+			t.Fatalf("Failed to read spool segment: %v", err)
+		}
+		if len(records) != 2 {
+			t.Errorf("Expected 2 spooled records, got %d", len(records))
+		}
+	})
+
+	t.Run("Size cap evicts oldest segments", func(t *testing.T) {
+		t.Parallel()
+		dir := t.TempDir()
+		failing := &mocks.MockMessageWriter{WriteErr: errors.New("broker unreachable")}
+		logger := newKafkaLogger(failing, "test-service", WithSpool(SpoolConfig{
+			Dir:            dir,
+			MaxBytes:       2000,
+			SegmentBytes:   1,
+			ReplayInterval: time.Hour,
+		}))
+
+		for range 10 {
+			checkNoError(t, logger.Error("filling up the spool", nil))
+		}
+
+		err := logger.Close()
+		if !errors.Is(err, ErrEventDropped) {
+			t.Fatalf("Expected ErrEventDropped, got: %v", err)
+		}
+
+		segments, _ := filepath.Glob(filepath.Join(dir, "*"+spoolSuffix))
+		if int(logger.Dropped())+len(segments) != 10 {
+			t.Errorf("Expected evicted and remaining to add up to 10, got %d evicted and %d remaining",
+				logger.Dropped(), len(segments))
+		}
+		if len(segments) == 0 || len(segments) == 10 {
+			t.Errorf("Expected some segments to be evicted, %d remain", len(segments))
+		}
+	})
+
+	t.Run("Segment being replayed is not evicted", func(t *testing.T) {
+		t.Parallel()
+		var brokerDown atomic.Bool
+		brokerDown.Store(true)
+		replaying := make(chan struct{}, 1)
+		release := make(chan struct{})
+		mockWriter := &mocks.MockMessageWriter{
+			WriteFunc: func(ctx context.Context, msgs ...kafka.Message) error {
+				if brokerDown.Load() {
+					return errors.New("broker unreachable")
+				}
+				select {
+				case replaying <- struct{}{}:
+				default:
+				}
+				<-release
+				return nil
+			},
+		}
+		logger := newKafkaLogger(mockWriter, "test-service", WithSpool(SpoolConfig{
+			Dir:            t.TempDir(),
+			MaxBytes:       1,
+			ReplayInterval: time.Hour,
+		}))
+
+		checkNoError(t, logger.Error("first", nil))
+		brokerDown.Store(false)
+		checkNoError(t, logger.Error("second", nil))
+		<-replaying
+
+		// Each of these fills a new segment past MaxBytes.
+		logger.spool.mu.Lock()
+		logger.spool.cfg.SegmentBytes = 1
+		logger.spool.mu.Unlock()
+		for range 3 {
+			checkNoError(t, logger.Error("evicted", nil))
+		}
+		close(release)
+
+		waitForMessages(t, mockWriter, 2)
+		logger.Close()
+		assertMessages(t, mockWriter, "first", "second")
+		if logger.Dropped() != 3 {
+			t.Errorf("Expected only the 3 evicted events to be dropped, got %d", logger.Dropped())
+		}
+	})
+
+	t.Run("Age cap evicts old segments", func(t *testing.T) {
+		t.Parallel()
+		dir := t.TempDir()
+		old := filepath.Join(dir, "00000000000000000001"+spoolSuffix)
+		if err := os.WriteFile(old, []byte("{\"value\":\"e30=\"}\n"), 0644); err != nil {
+			t.Fatalf("Failed to write segment: %v", err)
+		}
+
+		spool := newDiskSpool(&mocks.MockMessageWriter{}, SpoolConfig{
+			Dir:            dir,
+			MaxAge:         time.Minute,
+			ReplayInterval: time.Hour,
+		})
+		defer spool.close()
+
+		spool.mu.Lock()
+		spool.enforceLimits()
+		spool.mu.Unlock()
+
+		if _, err := os.Stat(old); !os.IsNotExist(err) {
+			t.Error("Expected old segment to be evicted")
+		}
+		if spool.evicted.Load() != 1 {
+			t.Errorf("Expected 1 evicted event, got %d", spool.evicted.Load())
+		}
+	})
+
+	t.Run("Truncated trailing record is skipped", func(t *testing.T) {
+		t.Parallel()
+		name := filepath.Join(t.TempDir(), "1"+spoolSuffix)
+		data := "{\"key\":\"a2V5\",\"value\":\"e30=\"}\n{\"key\":\"a2V5\",\"va"
+		if err := os.WriteFile(name, []byte(data), 0644); err != nil {
+			t.Fatalf("Failed to write segment: %v", err)
+		}
+
+		records, err := readSpoolSegment(name)
+		if err != nil {
+			t.Fatalf("Expected no error, got: %v", err)
+		}
+		if len(records) != 1 || string(records[0].Key) != "key" {
+			t.Errorf("Expected 1 complete record, got %v", records)
+		}
+	})
+}
+
+func waitForMessages(t *testing.T, mockWriter *mocks.MockMessageWriter, count int) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for len(mockWriter.Written()) < count {
+		if time.Now().After(deadline) {
+			t.Fatalf("Timed out waiting for %d messages, got %d", count, len(mockWriter.Written()))
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func assertSpoolEmpty(t *testing.T, dir string) {
+	t.Helper()
+	segments, _ := filepath.Glob(filepath.Join(dir, "*"+spoolSuffix))
+	if len(segments) != 0 {
+		t.Errorf("Expected spool to be empty, found %v", segments)
+	}
+}
@@ -0,0 +1,225 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"regexp"
+	"runtime"
+	"strings"
+	"sync"
+
+	"github.com/segmentio/kafka-go"
+)
+
+// internalLog reports the logger's own problems. It writes to stderr
+// directly rather than through the standard logger, which may be redirected
+// to a LineWriter and would then feed failures back into the logger.
+var internalLog = log.New(os.Stderr, "", log.LstdFlags)
+
+// maxLineLength bounds the partial line a LineWriter buffers. Longer lines
+// are published in pieces.
+const maxLineLength = 64 << 10
+
+// LineWriter is an io.Writer that publishes every line written to it as an
+// event, for libraries that log through the standard log package or to a
+// writer. Lines may arrive in pieces or several per write; a trailing
+// partial line is held until its newline arrives or Flush is called.
+//
+// A level prefix such as "ERROR:", "[WARN]" or "level=debug" sets the
+// event's level and is removed from the message, as is a leading timestamp
+// in the standard log format. Other lines get the writer's default level.
+// Lines that cannot be published are written to the fallback writer,
+// including lines an async logger accepted but later gave up on.
+type LineWriter struct {
+	logger *KafkaLogger
+	level  LogLevel
+
+	// fallbackMu guards fallback, which async delivery failures are
+	// written to from the logger's background goroutines.
+	fallbackMu sync.Mutex
+	fallback   io.Writer
+
+	mu      sync.Mutex
+	partial []byte
+}
+
+// Writer returns a LineWriter that publishes lines without a level prefix
+// at level and falls back to stderr.
+func (kl *KafkaLogger) Writer(level LogLevel) *LineWriter {
+	return &LineWriter{logger: kl, level: level, fallback: os.Stderr}
+}
+
+// WithFallback sets where lines go when they cannot be published. A nil
+// writer discards them.
+func (w *LineWriter) WithFallback(fallback io.Writer) *LineWriter {
+	w.fallbackMu.Lock()
+	defer w.fallbackMu.Unlock()
+	w.fallback = fallback
+	return w
+}
+
+// Write publishes each complete line in p. It always reports the whole of p
+// as written: lines that fail to publish go to the fallback writer instead,
+// and callers such as the log package have no use for the error.
+func (w *LineWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.partial = append(w.partial, p...)
+	for {
+		i := bytes.IndexByte(w.partial, '\n')
+		if i < 0 {
+			break
+		}
+		w.publish(w.partial[:i])
+		w.partial = w.partial[i+1:]
+	}
+	for len(w.partial) >= maxLineLength {
+		w.publish(w.partial[:maxLineLength])
+		w.partial = w.partial[maxLineLength:]
+	}
+	if len(w.partial) == 0 {
+		w.partial = nil
+	}
+	return len(p), nil
+}
+
+// Flush publishes a buffered partial line, if any.
+func (w *LineWriter) Flush() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if len(w.partial) > 0 {
+		w.publish(w.partial)
+		w.partial = nil
+	}
+}
+
+func (w *LineWriter) publish(line []byte) {
+	text := strings.TrimRight(string(line), "\r")
+	if strings.TrimSpace(text) == "" {
+		return
+	}
+	level, message := parseLinePrefix(text, w.level)
+
+	logger := w.logger
+	if !logger.Enabled(level) {
+		return
+	}
+	failed := &failedLine{writer: w, service: logger.service, level: level, message: message}
+	ctx := context.WithValue(context.Background(), failedLineKey{}, failed)
+	if err := logger.publish(ctx, logger.newEvent(level, message, nil, externalCaller())); err != nil {
+		failed.report(err)
+	}
+}
+
+// failedLineKey marks the context of events published by a LineWriter.
+type failedLineKey struct{}
+
+// failedLine is carried in the WriterData of a LineWriter's messages, so a
+// failure reported after publish has returned, as in async mode, still
+// reaches the writer's fallback.
+type failedLine struct {
+	writer  *LineWriter
+	service string
+	level   LogLevel
+	message string
+}
+
+func (l *failedLine) report(err error) {
+	w := l.writer
+	w.fallbackMu.Lock()
+	defer w.fallbackMu.Unlock()
+	if w.fallback != nil {
+		fmt.Fprintf(w.fallback, "%s [%s] %s (kafka: %v)\n", l.service, l.level, l.message, err)
+	}
+}
+
+// reportFailedLines writes the lines among messages that were given up on
+// to the fallback of the LineWriter that published them.
+func reportFailedLines(messages []kafka.Message, err error) {
+	for _, message := range messages {
+		if failed, ok := message.WriterData.(*failedLine); ok {
+			failed.report(err)
+		}
+	}
+}
+
+var (
+	stdlogTimestamp = regexp.MustCompile(`^(\d{4}/\d{2}/\d{2} )?\d{2}:\d{2}:\d{2}(\.\d+)? `)
+	linePrefix      = regexp.MustCompile(`^(?i)(?:\[([a-z]+)\]:?|([a-z]+):|level=([a-z]+))\s*`)
+)
+
+var prefixLevels = map[string]LogLevel{
+	"TRACE":    DEBUG,
+	"DEBUG":    DEBUG,
+	"INFO":     INFO,
+	"NOTICE":   INFO,
+	"WARN":     WARN,
+	"WARNING":  WARN,
+	"ERR":      ERROR,
+	"ERROR":    ERROR,
+	"CRIT":     ERROR,
+	"CRITICAL": ERROR,
+	"FATAL":    ERROR,
+	"PANIC":    ERROR,
+}
+
+// parseLinePrefix strips a standard log timestamp and a recognized level
+// prefix from line. Unrecognized prefixes are left in the message.
+func parseLinePrefix(line string, fallback LogLevel) (LogLevel, string) {
+	line = stdlogTimestamp.ReplaceAllString(line, "")
+	match := linePrefix.FindStringSubmatch(line)
+	if match == nil {
+		return fallback, line
+	}
+	name := match[1] + match[2] + match[3]
+	level, ok := prefixLevels[strings.ToUpper(name)]
+	if !ok {
+		return fallback, line
+	}
+	return level, line[len(match[0]):]
+}
+
+// externalCaller returns the first frame outside the log, fmt and io
+// packages and this writer, which is the code that logged the line.
+func externalCaller() *Caller {
+	pcs := make([]uintptr, maxStackDepth)
+	n := runtime.Callers(3, pcs)
+	frames := runtime.CallersFrames(pcs[:n])
+	for {
+		frame, more := frames.Next()
+		if !isWriterFrame(frame.Function) {
+			return &Caller{File: frame.File, Line: frame.Line, Function: frame.Function}
+		}
+		if !more {
+			return nil
+		}
+	}
+}
+
+func isWriterFrame(function string) bool {
+	for _, prefix := range []string{"log.", "fmt.", "io.", "kafka-logger/service.(*LineWriter)."} {
+		if strings.HasPrefix(function, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// RedirectStdLog sends the standard logger's output to logger, with lines
+// lacking a level prefix published at level. The standard logger's
+// timestamp is turned off, since events carry their own. The returned
+// function restores the previous output and flags.
+func RedirectStdLog(logger *KafkaLogger, level LogLevel) (restore func()) {
+	previousOutput, previousFlags := log.Writer(), log.Flags()
+	log.SetOutput(logger.Writer(level))
+	log.SetFlags(0)
+	return func() {
+		log.SetOutput(previousOutput)
+		log.SetFlags(previousFlags)
+	}
+}
@@ -0,0 +1,111 @@
+package service
+
+import (
+	"bytes"
+	"errors"
+	"kafka-logger/mocks"
+	"log"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+)
+
+func TestLineWriter(t *testing.T) {
+	t.Run("Splits writes into lines", func(t *testing.T) {
+		mockWriter := &mocks.MockMessageWriter{}
+		w := newKafkaLogger(mockWriter, "test-service").Writer(INFO)
+
+		w.Write([]byte("first\nsec"))
+		w.Write([]byte("ond\r\n\nthird"))
+		assertMessages(t, mockWriter, "first", "second")
+
+		w.Flush()
+		assertMessages(t, mockWriter, "first", "second", "third")
+	})
+
+	t.Run("Detects level prefixes", func(t *testing.T) {
+		tests := []struct {
+			line    string
+			level   LogLevel
+			message string
+		}{
+			{"ERROR: disk full", ERROR, "disk full"},
+			{"[WARN] slow query", WARN, "slow query"},
+			{"[warning]: retrying", WARN, "retrying"},
+			{"level=debug cache miss", DEBUG, "cache miss"},
+			{"2024/01/15 10:30:45 FATAL: cannot bind", ERROR, "cannot bind"},
+			{"10:30:45.123456 info: ready", INFO, "ready"},
+			{"http: TLS handshake error", WARN, "http: TLS handshake error"},
+			{"plain line", WARN, "plain line"},
+		}
+		for _, tt := range tests {
+			level, message := parseLinePrefix(tt.line, WARN)
+			if level != tt.level || message != tt.message {
+				t.Errorf("parseLinePrefix(%q) = %s %q, expected %s %q", tt.line, level, message, tt.level, tt.message)
+			}
+		}
+	})
+
+	t.Run("Standard logger redirect", func(t *testing.T) {
+		mockWriter := &mocks.MockMessageWriter{}
+		logger := newKafkaLogger(mockWriter, "test-service", WithLevel(NewLevelVar(INFO)))
+
+		restore := RedirectStdLog(logger, INFO)
+		log.Print("[DEBUG] filtered")
+		log.Printf("ERROR: upstream returned %d", 502)
+		log.Print("multi\nline")
+		restore()
+		log.SetOutput(&bytes.Buffer{})
+		log.Print("after restore")
+		restore()
+
+		assertMessages(t, mockWriter, "upstream returned 502", "multi", "line")
+		event := decodeLogEvent(t, mockWriter.Messages[0])
+		if event.Level != ERROR {
+			t.Errorf("Expected ERROR, got %s", event.Level)
+		}
+		assertCaller(t, event.Caller, "writer_test.go", "TestLineWriter.func3")
+		if !strings.HasPrefix(event.Stack, event.Caller.Function+"\n") {
+			t.Errorf("Expected the stack to start at the log call, got:\n%s", event.Stack)
+		}
+	})
+
+	t.Run("Falls back when Kafka fails", func(t *testing.T) {
+		mockWriter := &mocks.MockMessageWriter{WriteErr: errors.New("broker unreachable")}
+		var fallback bytes.Buffer
+		w := newKafkaLogger(mockWriter, "test-service").Writer(INFO).WithFallback(&fallback)
+
+		n, err := w.Write([]byte("WARN: lost?\n"))
+		if n != 12 || err != nil {
+			t.Errorf("Expected the write to succeed, got %d, %v", n, err)
+		}
+		if got := fallback.String(); got != "test-service [WARN] lost? (kafka: broker unreachable)\n" {
+			t.Errorf("Unexpected fallback output %q", got)
+		}
+	})
+
+	t.Run("Falls back when an async batch fails", func(t *testing.T) {
+		mockWriter := &mocks.MockMessageWriter{WriteErr: errors.New("broker unreachable")}
+		logger := newKafkaLogger(mockWriter, "test-service", WithAsync(AsyncConfig{
+			FlushInterval: time.Hour,
+			OnError:       func(error, []kafka.Message) {},
+		}))
+		var fallback bytes.Buffer
+		w := logger.Writer(INFO).WithFallback(&fallback)
+
+		w.Write([]byte("ERROR: lost?\n"))
+		checkNoError(t, logger.Info("not a line", nil))
+		if got := fallback.String(); got != "" {
+			t.Errorf("Expected nothing in the fallback before the batch fails, got %q", got)
+		}
+
+		if err := logger.Close(); !errors.Is(err, ErrEventDropped) {
+			t.Errorf("Expected ErrEventDropped, got: %v", err)
+		}
+		if got := fallback.String(); got != "test-service [ERROR] lost? (kafka: broker unreachable)\n" {
+			t.Errorf("Unexpected fallback output %q", got)
+		}
+	})
+}